@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -56,17 +57,8 @@ func (h *ChartHandler) GenerateChart(w http.ResponseWriter, r *http.Request) {
 		handleError(w, "missing date parameter", nil, http.StatusBadRequest)
 		return
 	}
-	result, err := h.repository.GetTestData(date, testType)
-	if err != nil {
-		handleError(w, "error retrieving data", err, http.StatusInternalServerError)
-		return
-	}
-	if result == nil {
-		handleError(w, "nil data returned", err, http.StatusInternalServerError)
-		return
-	}
 
-	chartPath, err := h.generateAndSaveCharts(result, testType)
+	chartPath, err := h.chartsForDate(testType, date)
 	if err != nil {
 		handleError(w, "Chart generation failed", err, http.StatusInternalServerError)
 		return
@@ -90,19 +82,11 @@ func (h *ChartHandler) GenerateHistoricChart(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	start := time.Now().AddDate(0, 0, -days)
-
-	results, err := h.repository.GetTestDataInRange(start, time.Now(), testType)
-	if err != nil {
-		handleError(w, "error retrieving data", err, http.StatusInternalServerError)
-		return
-	}
-	if len(results) == 0 {
-		handleError(w, fmt.Sprintf("no %s test data found in the last %d days", testType, days), nil, http.StatusNotFound)
+	chartPath, err := h.chartsForRecentDays(testType, days)
+	if errors.Is(err, errNoChartData) {
+		handleError(w, err.Error(), nil, http.StatusNotFound)
 		return
 	}
-
-	chartPath, err := h.generateAndSaveHistoricCharts(results, testType)
 	if err != nil {
 		handleError(w, "error while generating charts", err, http.StatusInternalServerError)
 		return
@@ -113,6 +97,38 @@ func (h *ChartHandler) GenerateHistoricChart(w http.ResponseWriter, r *http.Requ
 	w.Write([]byte(chartPath))
 }
 
+// errNoChartData is returned when there are no results to chart.
+var errNoChartData = errors.New("nothing to chart")
+
+// chartsForDate generates and saves the charts for testType's results on
+// date, returning their space-separated paths.
+func (h *ChartHandler) chartsForDate(testType, date string) (string, error) {
+	result, err := h.repository.GetTestData(date, testType)
+	if err != nil {
+		return "", fmt.Errorf("error retrieving data: %w", err)
+	}
+	if result == nil {
+		return "", fmt.Errorf("%w: no %s results on %s", errNoChartData, testType, date)
+	}
+	return h.generateAndSaveCharts(result, testType)
+}
+
+// chartsForRecentDays generates and saves the historic charts for
+// testType's results over the last days days, returning their
+// space-separated paths.
+func (h *ChartHandler) chartsForRecentDays(testType string, days int) (string, error) {
+	start := time.Now().AddDate(0, 0, -days)
+
+	results, err := h.repository.GetTestDataInRange(start, time.Now(), testType)
+	if err != nil {
+		return "", fmt.Errorf("error retrieving data: %w", err)
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("%w: no %s test data found in the last %d days", errNoChartData, testType, days)
+	}
+	return h.generateAndSaveHistoricCharts(results, testType)
+}
+
 // marshalSourceData renders the data fed into a historic chart as indented
 // JSON, so it can be stored alongside the chart and shown in the dashboard's
 // raw-data view later.
@@ -128,7 +144,7 @@ func (h *ChartHandler) generateAndSaveHistoricCharts(results []*networkTesting.T
 	chartPath := ""
 	switch testType {
 	case "icmp":
-		icmpResults := make([]*networkTesting.ICMPTestResult, len(results))
+		icmpResults := make([]*networkTesting.MultiHostICMPResult, len(results))
 		for i, r := range results {
 			icmpResults[i] = r.ICMP
 		}
@@ -144,6 +160,15 @@ func (h *ChartHandler) generateAndSaveHistoricCharts(results []*networkTesting.T
 		if err != nil {
 			return "", fmt.Errorf("failed to save icmp chart: %w", err)
 		}
+		mosLine, err := h.charts.GenerateHistoricICMPMOSChart(icmpResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate icmp MOS chart: %w", err)
+		}
+		chartPath2, err := h.repository.SaveChart(mosLine, "icmp", "mos_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save icmp MOS chart: %w", err)
+		}
+		chartPath = chartPath + " " + chartPath2
 	case "download":
 		downloadResults := make([]*networkTesting.AverageSpeedTestResult, len(results))
 		for i, r := range results {
@@ -212,12 +237,21 @@ func (h *ChartHandler) generateAndSaveHistoricCharts(results []*networkTesting.T
 		if err != nil {
 			return "", fmt.Errorf("failed to save latency chart: %w", err)
 		}
+		mosLine, err := h.charts.GenerateHistoricLatencyMOSChart(latencyResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate latency MOS chart: %w", err)
+		}
+		chartPath2, err := h.repository.SaveChart(mosLine, "latency", "mos_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save latency MOS chart: %w", err)
+		}
+		chartPath = chartPath + " " + chartPath2
 	case "bandwidth":
 		bandwidthResult := make([]*networkTesting.BandwidthTestResult, len(results))
 		for i, r := range results {
 			bandwidthResult[i] = r.Bandwidth
 		}
-		speedBar, durationBar, err := h.charts.GenerateHistoricBandwidthAnalysisCharts(bandwidthResult)
+		capacityLine, speedBar, err := h.charts.GenerateHistoricBandwidthAnalysisCharts(bandwidthResult)
 		if err != nil {
 			return "", fmt.Errorf("failed to generate bandwidth chart: %w", err)
 		}
@@ -225,15 +259,134 @@ func (h *ChartHandler) generateAndSaveHistoricCharts(results []*networkTesting.T
 		if err != nil {
 			return "", err
 		}
-		chartPath, err = h.repository.SaveChart(speedBar, "bandwidth", "bandwidth_speed_ot", 0, sourceData)
+		chartPath, err = h.repository.SaveChart(capacityLine, "bandwidth", "bandwidth_capacity_ot", 0, sourceData)
 		if err != nil {
-			return "", fmt.Errorf("failed to save bandwidth speed chart: %w", err)
+			return "", fmt.Errorf("failed to save bandwidth capacity chart: %w", err)
 		}
-		chartPath2, err := h.repository.SaveChart(durationBar, "bandwidth", "bandwidth_duration_ot", 0, sourceData)
+		chartPath2, err := h.repository.SaveChart(speedBar, "bandwidth", "bandwidth_speed_ot", 0, sourceData)
 		if err != nil {
-			return "", fmt.Errorf("failed to save bandwidth duration chart: %w", err)
+			return "", fmt.Errorf("failed to save bandwidth speed chart: %w", err)
 		}
 		chartPath = chartPath + " " + chartPath2
+	case "dns":
+		dnsResults := make([]*networkTesting.DNSTestResult, len(results))
+		for i, r := range results {
+			dnsResults[i] = r.DNS
+		}
+		line, err := h.charts.GenerateHistoricDNSAnalysisCharts(dnsResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate dns chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(dnsResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(line, "dns", "latency_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save dns chart: %w", err)
+		}
+	case "tcp":
+		tcpResults := make([]*networkTesting.TCPTestResult, len(results))
+		for i, r := range results {
+			tcpResults[i] = r.TCP
+		}
+		chart, err := h.charts.GenerateHistoricTCPAnalysisCharts(tcpResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate tcp chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(tcpResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(chart, "tcp", "connect_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save tcp chart: %w", err)
+		}
+	case "http":
+		httpResults := make([]*networkTesting.HTTPTestResult, len(results))
+		for i, r := range results {
+			httpResults[i] = r.HTTP
+		}
+		chart, err := h.charts.GenerateHistoricHTTPAnalysisCharts(httpResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate http chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(httpResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(chart, "http", "phases_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save http chart: %w", err)
+		}
+	case "tls":
+		tlsResults := make([]*networkTesting.TLSTestResult, len(results))
+		for i, r := range results {
+			tlsResults[i] = r.TLS
+		}
+		chart, err := h.charts.GenerateHistoricTLSAnalysisCharts(tlsResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate tls chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(tlsResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(chart, "tls", "expiry_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save tls chart: %w", err)
+		}
+	case "mtu":
+		mtuResults := make([]*networkTesting.MTUTestResult, len(results))
+		for i, r := range results {
+			mtuResults[i] = r.MTU
+		}
+		chart, err := h.charts.GenerateHistoricMTUAnalysisCharts(mtuResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate mtu chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(mtuResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(chart, "mtu", "mtu_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save mtu chart: %w", err)
+		}
+	case "loaded-latency":
+		loadedLatencyResults := make([]*networkTesting.LoadedLatencyTestResult, len(results))
+		for i, r := range results {
+			loadedLatencyResults[i] = r.LoadedLatency
+		}
+		chart, err := h.charts.GenerateHistoricLoadedLatencyAnalysisCharts(loadedLatencyResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate loaded-latency chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(loadedLatencyResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(chart, "loaded-latency", "loaded_latency_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save loaded-latency chart: %w", err)
+		}
+	case "udp":
+		udpResults := make([]*networkTesting.UDPTestResult, len(results))
+		for i, r := range results {
+			udpResults[i] = r.UDP
+		}
+		chart, err := h.charts.GenerateHistoricUDPAnalysisCharts(udpResults)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate udp chart: %w", err)
+		}
+		sourceData, err := marshalSourceData(udpResults)
+		if err != nil {
+			return "", err
+		}
+		chartPath, err = h.repository.SaveChart(chart, "udp", "delivery_ot", 0, sourceData)
+		if err != nil {
+			return "", fmt.Errorf("failed to save udp chart: %w", err)
+		}
 	default:
 		return "", fmt.Errorf("unsupported test type: %s", testType)
 	}
@@ -261,6 +414,17 @@ func (h *ChartHandler) generateAndSaveCharts(result *networkTesting.TestResult,
 		if err != nil {
 			return "", fmt.Errorf("failed to save download chart: %w", err)
 		}
+		if result.Download.Sampled() {
+			line, err := h.charts.GenerateDownloadThroughputChart(result.Download)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate download throughput chart: %w", err)
+			}
+			chartPath2, err := h.repository.SaveChart(line, "download", "throughput", 0)
+			if err != nil {
+				return "", fmt.Errorf("failed to save download throughput chart: %w", err)
+			}
+			chartPath = chartPath + " " + chartPath2
+		}
 	case "upload":
 		bar, err := h.charts.GenerateUploadAnalysisCharts(result.Upload)
 		if err != nil {
@@ -270,6 +434,17 @@ func (h *ChartHandler) generateAndSaveCharts(result *networkTesting.TestResult,
 		if err != nil {
 			return "", fmt.Errorf("failed to save upload chart: %w", err)
 		}
+		if result.Upload.Sampled() {
+			line, err := h.charts.GenerateUploadThroughputChart(result.Upload)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate upload throughput chart: %w", err)
+			}
+			chartPath2, err := h.repository.SaveChart(line, "upload", "throughput", 0)
+			if err != nil {
+				return "", fmt.Errorf("failed to save upload throughput chart: %w", err)
+			}
+			chartPath = chartPath + " " + chartPath2
+		}
 	case "route":
 		lineChart, err := h.charts.GenerateRouteAnalysisCharts(result.Route)
 		if err != nil {
@@ -289,19 +464,82 @@ func (h *ChartHandler) generateAndSaveCharts(result *networkTesting.TestResult,
 			return "", fmt.Errorf("failed to save latency chart: %w", err)
 		}
 	case "bandwidth":
-		bar3dSpeed, bar3dDuration, err := h.charts.GenerateBandwidthAnalysisCharts(result.Bandwidth)
+		capacityLine, bar3dSpeed, err := h.charts.GenerateBandwidthAnalysisCharts(result.Bandwidth)
 		if err != nil {
 			return "", fmt.Errorf("failed to generate bandwidth charts: %w", err)
 		}
-		chartPath, err = h.repository.SaveChart(bar3dSpeed, "bandwidth", "speed", 0)
+		chartPath, err = h.repository.SaveChart(capacityLine, "bandwidth", "capacity", 0)
 		if err != nil {
-			return "", fmt.Errorf("failed to save bandwidth speed chart: %w", err)
+			return "", fmt.Errorf("failed to save bandwidth capacity chart: %w", err)
 		}
-		chartPath2, err := h.repository.SaveChart(bar3dDuration, "bandwidth", "duration", 0)
+		chartPath2, err := h.repository.SaveChart(bar3dSpeed, "bandwidth", "speed", 0)
 		if err != nil {
-			return "", fmt.Errorf("failed to save bandwidth duration chart: %w", err)
+			return "", fmt.Errorf("failed to save bandwidth speed chart: %w", err)
 		}
 		chartPath = chartPath + " " + chartPath2
+	case "dns":
+		bar, err := h.charts.GenerateDNSAnalysisCharts(result.DNS)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate DNS chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(bar, "dns", "latency", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save DNS chart: %w", err)
+		}
+	case "tcp":
+		chart, err := h.charts.GenerateTCPAnalysisCharts(result.TCP)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate TCP chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(chart, "tcp", "connect", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save TCP chart: %w", err)
+		}
+	case "http":
+		chart, err := h.charts.GenerateHTTPAnalysisCharts(result.HTTP)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate HTTP chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(chart, "http", "phases", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save HTTP chart: %w", err)
+		}
+	case "tls":
+		chart, err := h.charts.GenerateTLSAnalysisCharts(result.TLS)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate TLS chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(chart, "tls", "expiry", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save TLS chart: %w", err)
+		}
+	case "mtu":
+		chart, err := h.charts.GenerateMTUAnalysisCharts(result.MTU)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate MTU chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(chart, "mtu", "mtu", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save MTU chart: %w", err)
+		}
+	case "loaded-latency":
+		chart, err := h.charts.GenerateLoadedLatencyAnalysisCharts(result.LoadedLatency)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate loaded latency chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(chart, "loaded-latency", "loaded_latency", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save loaded latency chart: %w", err)
+		}
+	case "udp":
+		chart, err := h.charts.GenerateUDPAnalysisCharts(result.UDP)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate UDP chart: %w", err)
+		}
+		chartPath, err = h.repository.SaveChart(chart, "udp", "delivery", 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to save UDP chart: %w", err)
+		}
 	default:
 		return "", fmt.Errorf("unsupported test type: %s", testType)
 	}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/oshaw1/go-net-test/internal/dataManagement"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
 	"github.com/oshaw1/go-net-test/internal/pageGeneration"
 	"github.com/oshaw1/go-net-test/internal/scheduler"
 )
@@ -13,9 +14,10 @@ type DashboardHandler struct {
 	repository *dataManagement.Repository
 	generator  *pageGeneration.PageGenerator
 	scheduler  *scheduler.Scheduler
+	running    *networkTesting.RunningTests
 }
 
-func NewDashboardHandler(repo *dataManagement.Repository, templatePath string, scheduler *scheduler.Scheduler) *DashboardHandler {
+func NewDashboardHandler(repo *dataManagement.Repository, templatePath string, scheduler *scheduler.Scheduler, running *networkTesting.RunningTests) *DashboardHandler {
 	if repo == nil {
 		log.Fatalf("Repository cannot be nil")
 	}
@@ -29,6 +31,7 @@ func NewDashboardHandler(repo *dataManagement.Repository, templatePath string, s
 		repository: repo,
 		generator:  generator,
 		scheduler:  scheduler,
+		running:    running,
 	}
 }
 
@@ -109,6 +112,19 @@ func (h *DashboardHandler) ServeSchedule(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// ServeQueue renders the queued and running tests, scheduled and manual,
+// for the scheduler panel to poll.
+func (h *DashboardHandler) ServeQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.generator.RenderQueue(w, h.running.List()); err != nil {
+		handleError(w, "Error rendering queue", err, 500)
+	}
+}
+
 func (h *DashboardHandler) ServeDashboard(w http.ResponseWriter, r *http.Request) {
 	if err := h.generator.RenderDashboard(w); err != nil {
 		handleError(w, "Error rendering dashboard", err, 500)
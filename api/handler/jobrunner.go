@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/oshaw1/go-net-test/internal/dataManagement"
+	"github.com/oshaw1/go-net-test/internal/scheduler"
+)
+
+// JobRunner runs scheduled tasks in-process, through the same test and
+// chart pipeline as the /networktest and /charts handlers, and keeps their
+// run history in the repository.
+type JobRunner struct {
+	tests      *NetworkTestHandler
+	charts     *ChartHandler
+	repository *dataManagement.Repository
+}
+
+func NewJobRunner(tests *NetworkTestHandler, charts *ChartHandler, repository *dataManagement.Repository) *JobRunner {
+	return &JobRunner{tests: tests, charts: charts, repository: repository}
+}
+
+// RunTest runs and saves a test, charts included, once it has a turn in
+// the same queue as manual runs. A cancelled test still returns the ID of
+// its saved partial result.
+func (j *JobRunner) RunTest(ctx context.Context, testType, source string) (int64, error) {
+	_, resultID, err := j.tests.runAndSaveTest(ctx, testType, source, "")
+	return resultID, err
+}
+
+func (j *JobRunner) GenerateCharts(testType string, date time.Time) (string, error) {
+	return j.charts.chartsForDate(testType, date.Format(dateFormat))
+}
+
+func (j *JobRunner) GenerateHistoricCharts(testType string, days int) (string, error) {
+	return j.charts.chartsForRecentDays(testType, days)
+}
+
+func (j *JobRunner) RecordRun(taskID, taskName string, result scheduler.JobResult) error {
+	_, err := j.repository.SaveTaskRun(&dataManagement.TaskRun{
+		TaskID:    taskID,
+		TaskName:  taskName,
+		StartedAt: result.StartedAt,
+		EndedAt:   result.StartedAt.Add(result.Duration),
+		Attempt:   result.Attempt,
+		Status:    result.Status,
+		Error:     result.Error,
+		ResultID:  result.TestID,
+		Charts:    result.Charts,
+	})
+	return err
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -19,13 +21,15 @@ type NetworkTestHandler struct {
 	tester     *networkTesting.NetworkTester
 	repository *dataManagement.Repository
 	charts     *charting.Generator
+	running    *networkTesting.RunningTests
 }
 
-func NewNetworkTestHandler(tester *networkTesting.NetworkTester, repo *dataManagement.Repository) *NetworkTestHandler {
+func NewNetworkTestHandler(tester *networkTesting.NetworkTester, repo *dataManagement.Repository, running *networkTesting.RunningTests) *NetworkTestHandler {
 	return &NetworkTestHandler{
 		tester:     tester,
 		repository: repo,
 		charts:     charting.NewGenerator(),
+		running:    running,
 	}
 }
 
@@ -36,8 +40,16 @@ func (h *NetworkTestHandler) HandleNetworkTest(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	result, err := h.runAndSaveTest(testType)
-	if err != nil {
+	// run is a token the dashboard makes up per click, so its cancel button
+	// can find this exact test in /networktest/running.
+	run := r.URL.Query().Get("run")
+
+	result, _, err := h.runAndSaveTest(context.Background(), testType, "manual", run)
+	if errors.Is(err, networkTesting.ErrTestCancelled) && result == nil {
+		http.Error(w, "Test cancelled before it started", http.StatusConflict)
+		return
+	}
+	if err != nil && !errors.Is(err, networkTesting.ErrTestCancelled) {
 		handleError(w, "test execution", err, http.StatusInternalServerError)
 		return
 	}
@@ -89,19 +101,31 @@ func (h *NetworkTestHandler) getResultsRange(w http.ResponseWriter, testType, st
 	writeJSONResponse(w, results)
 }
 
-func (h *NetworkTestHandler) runAndSaveTest(testType string) (interface{}, error) {
-	result, err := h.tester.RunTest(testType)
+// runAndSaveTest waits for the test's turn in the running-tests queue, runs
+// it and returns the result along with its saved ID. The test isn't tied to
+// a request's context, so a client hanging up doesn't stop it; only a
+// cancel or ctx does. A cancelled run's partial result is still saved, and
+// comes back with ErrTestCancelled; one cancelled while queued returns just
+// the error.
+func (h *NetworkTestHandler) runAndSaveTest(ctx context.Context, testType, source, run string) (interface{}, int64, error) {
+	ctx, _, done, err := h.running.Start(ctx, testType, source, run)
 	if err != nil {
-		return nil, fmt.Errorf("test execution failed: %w", err)
+		return nil, 0, err
+	}
+	defer done()
+
+	result, runErr := h.tester.RunTest(ctx, testType)
+	if runErr != nil && !(errors.Is(runErr, networkTesting.ErrTestCancelled) && result != nil) {
+		return nil, 0, fmt.Errorf("test execution failed: %w", runErr)
 	}
 
 	if result == nil {
-		return nil, fmt.Errorf("no test results returned")
+		return nil, 0, fmt.Errorf("no test results returned")
 	}
 
 	resultID, err := h.repository.SaveTestResult(result, testType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save test result: %w", err)
+		return nil, 0, fmt.Errorf("failed to save test result: %w", err)
 	}
 
 	// Generated synchronously (not fire-and-forget) so the chart is already
@@ -112,7 +136,7 @@ func (h *NetworkTestHandler) runAndSaveTest(testType string) (interface{}, error
 		log.Printf("Chart generation failed: %v", err)
 	}
 
-	return result, nil
+	return result, resultID, runErr
 }
 
 func (h *NetworkTestHandler) extractResultParams(r *http.Request) (string, string, error) {
@@ -136,7 +160,7 @@ func (h *NetworkTestHandler) extractResultParams(r *http.Request) (string, strin
 func (h *NetworkTestHandler) generateAndSaveCharts(result interface{}, testType string, resultID int64) error {
 	switch testType {
 	case "icmp":
-		if icmpResult, ok := result.(*networkTesting.ICMPTestResult); ok {
+		if icmpResult, ok := result.(*networkTesting.MultiHostICMPResult); ok {
 			pieChart, err := h.charts.GenerateICMPAnalysisCharts(icmpResult)
 			if err != nil {
 				return fmt.Errorf("failed to generate ICMP chart: %w", err)
@@ -154,6 +178,15 @@ func (h *NetworkTestHandler) generateAndSaveCharts(result interface{}, testType
 			if _, err := h.repository.SaveChart(bar, "download", "speed", resultID); err != nil {
 				log.Printf("Failed to save download chart: %v", err)
 			}
+			if downloadResult.Sampled() {
+				line, err := h.charts.GenerateDownloadThroughputChart(downloadResult)
+				if err != nil {
+					return fmt.Errorf("failed to generate download throughput chart: %w", err)
+				}
+				if _, err := h.repository.SaveChart(line, "download", "throughput", resultID); err != nil {
+					log.Printf("Failed to save download throughput chart: %v", err)
+				}
+			}
 		}
 	case "upload":
 		if uploadResult, ok := result.(*networkTesting.AverageSpeedTestResult); ok {
@@ -164,6 +197,15 @@ func (h *NetworkTestHandler) generateAndSaveCharts(result interface{}, testType
 			if _, err := h.repository.SaveChart(bar, "upload", "speed", resultID); err != nil {
 				log.Printf("Failed to save upload chart: %v", err)
 			}
+			if uploadResult.Sampled() {
+				line, err := h.charts.GenerateUploadThroughputChart(uploadResult)
+				if err != nil {
+					return fmt.Errorf("failed to generate upload throughput chart: %w", err)
+				}
+				if _, err := h.repository.SaveChart(line, "upload", "throughput", resultID); err != nil {
+					log.Printf("Failed to save upload throughput chart: %v", err)
+				}
+			}
 		}
 	case "route":
 		if routeResult, ok := result.(*networkTesting.RouteTestResult); ok {
@@ -187,15 +229,85 @@ func (h *NetworkTestHandler) generateAndSaveCharts(result interface{}, testType
 		}
 	case "bandwidth":
 		if bandwidthResult, ok := result.(*networkTesting.BandwidthTestResult); ok {
-			bar3dSpeed, bar3dDuration, err := h.charts.GenerateBandwidthAnalysisCharts(bandwidthResult)
+			capacityLine, bar3dSpeed, err := h.charts.GenerateBandwidthAnalysisCharts(bandwidthResult)
 			if err != nil {
 				return fmt.Errorf("failed to generate bandwidth charts: %w", err)
 			}
+			if _, err := h.repository.SaveChart(capacityLine, "bandwidth", "capacity", resultID); err != nil {
+				log.Printf("Failed to save bandwidth capacity chart: %v", err)
+			}
 			if _, err := h.repository.SaveChart(bar3dSpeed, "bandwidth", "speed", resultID); err != nil {
 				log.Printf("Failed to save bandwidth speed chart: %v", err)
 			}
-			if _, err := h.repository.SaveChart(bar3dDuration, "bandwidth", "duration", resultID); err != nil {
-				log.Printf("Failed to save bandwidth duration chart: %v", err)
+		}
+	case "dns":
+		if dnsResult, ok := result.(*networkTesting.DNSTestResult); ok {
+			bar, err := h.charts.GenerateDNSAnalysisCharts(dnsResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate DNS chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(bar, "dns", "latency", resultID); err != nil {
+				log.Printf("Failed to save DNS chart: %v", err)
+			}
+		}
+	case "tcp":
+		if tcpResult, ok := result.(*networkTesting.TCPTestResult); ok {
+			chart, err := h.charts.GenerateTCPAnalysisCharts(tcpResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate TCP chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(chart, "tcp", "connect", resultID); err != nil {
+				log.Printf("Failed to save TCP chart: %v", err)
+			}
+		}
+	case "http":
+		if httpResult, ok := result.(*networkTesting.HTTPTestResult); ok {
+			chart, err := h.charts.GenerateHTTPAnalysisCharts(httpResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate HTTP chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(chart, "http", "phases", resultID); err != nil {
+				log.Printf("Failed to save HTTP chart: %v", err)
+			}
+		}
+	case "tls":
+		if tlsResult, ok := result.(*networkTesting.TLSTestResult); ok {
+			chart, err := h.charts.GenerateTLSAnalysisCharts(tlsResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate TLS chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(chart, "tls", "expiry", resultID); err != nil {
+				log.Printf("Failed to save TLS chart: %v", err)
+			}
+		}
+	case "mtu":
+		if mtuResult, ok := result.(*networkTesting.MTUTestResult); ok {
+			chart, err := h.charts.GenerateMTUAnalysisCharts(mtuResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate MTU chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(chart, "mtu", "mtu", resultID); err != nil {
+				log.Printf("Failed to save MTU chart: %v", err)
+			}
+		}
+	case "loaded-latency":
+		if loadedLatencyResult, ok := result.(*networkTesting.LoadedLatencyTestResult); ok {
+			chart, err := h.charts.GenerateLoadedLatencyAnalysisCharts(loadedLatencyResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate loaded latency chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(chart, "loaded-latency", "loaded_latency", resultID); err != nil {
+				log.Printf("Failed to save loaded latency chart: %v", err)
+			}
+		}
+	case "udp":
+		if udpResult, ok := result.(*networkTesting.UDPTestResult); ok {
+			chart, err := h.charts.GenerateUDPAnalysisCharts(udpResult)
+			if err != nil {
+				return fmt.Errorf("failed to generate UDP chart: %w", err)
+			}
+			if _, err := h.repository.SaveChart(chart, "udp", "delivery", resultID); err != nil {
+				log.Printf("Failed to save UDP chart: %v", err)
 			}
 		}
 	default:
@@ -204,6 +316,30 @@ func (h *NetworkTestHandler) generateAndSaveCharts(result interface{}, testType
 	return nil
 }
 
+func (h *NetworkTestHandler) HandleRunningTests(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, h.running.List())
+}
+
+func (h *NetworkTestHandler) HandleCancelTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Missing or invalid id property", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.running.Cancel(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *NetworkTestHandler) HandleDeleteTests(w http.ResponseWriter, r *http.Request) {
 	date := r.URL.Query().Get("date")
 	if date == "" {
@@ -4,17 +4,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/oshaw1/go-net-test/internal/dataManagement"
 	"github.com/oshaw1/go-net-test/internal/scheduler"
 )
 
+// defaultHistoryLimit is how many runs /schedule/history returns when no
+// limit is asked for.
+const defaultHistoryLimit = 50
+
 type SchedulerHandler struct {
-	scheduler *scheduler.Scheduler
+	scheduler  *scheduler.Scheduler
+	repository *dataManagement.Repository
 }
 
-func NewSchedulerHandler(scheduler *scheduler.Scheduler) *SchedulerHandler {
-	return &SchedulerHandler{scheduler: scheduler}
+func NewSchedulerHandler(scheduler *scheduler.Scheduler, repository *dataManagement.Repository) *SchedulerHandler {
+	return &SchedulerHandler{scheduler: scheduler, repository: repository}
 }
 
 func (h *SchedulerHandler) HandleCreateSchedule(w http.ResponseWriter, r *http.Request) {
@@ -29,12 +36,18 @@ func (h *SchedulerHandler) HandleCreateSchedule(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	if err := task.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := fmt.Sprintf("%d", time.Now().UnixNano())
 	task.CreatedOn = time.Now()
 
-	h.scheduler.Mu.Lock()
-	h.scheduler.Schedule[id] = &task
-	h.scheduler.Mu.Unlock()
+	if err := h.scheduler.AddTask(id, &task); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	response := map[string]*scheduler.Task{id: &task}
 	w.WriteHeader(http.StatusCreated)
@@ -128,6 +141,10 @@ func (h *SchedulerHandler) HandleEditSchedule(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if err := updatedTask.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	editedTask, err := h.scheduler.EditTask(id, updatedTask)
 	if err != nil {
@@ -138,3 +155,36 @@ func (h *SchedulerHandler) HandleEditSchedule(w http.ResponseWriter, r *http.Req
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(editedTask)
 }
+
+// HandleTaskHistory lists a task's most recent runs, newest first. History
+// outlives the task, so a deleted task's ID still returns its runs.
+func (h *SchedulerHandler) HandleTaskHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Missing task ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	runs, err := h.repository.GetTaskRuns(id, limit)
+	if err != nil {
+		handleError(w, "retrieving task history", err, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, runs)
+}
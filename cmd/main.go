@@ -1,9 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/oshaw1/go-net-test/api/handler"
@@ -25,9 +29,71 @@ func printBanner() {
 	fmt.Println(banner)
 }
 
+// runReflector serves the far end of the UDP test until interrupted. It
+// needs no config file or database, so it can run on a bare host at the
+// other site.
+func runReflector(args []string) {
+	flags := flag.NewFlagSet("reflector", flag.ExitOnError)
+	listen := flags.String("listen", ":7100", "UDP address to listen on")
+	flags.Parse(args)
+
+	reflector, err := networkTesting.ListenUDPReflector(*listen)
+	if err != nil {
+		log.Fatalf("Failed to start UDP reflector: %v", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		reflector.Close()
+	}()
+
+	log.Printf("UDP reflector listening on %s\n", reflector.Addr())
+	if err := reflector.Serve(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runSpeedTestServer serves only the speed-test endpoints, for a host that
+// should be a download/upload target without running tests of its own.
+func runSpeedTestServer(args []string) {
+	flags := flag.NewFlagSet("speedtest-server", flag.ExitOnError)
+	listen := flags.String("listen", ":7001", "HTTP address to listen on")
+	maxMB := flags.Int64("max-mb", networkTesting.DefaultSpeedTestMaxBytes/(1024*1024), "largest single download or upload, in MB")
+	flags.Parse(args)
+
+	speedTestServer, err := networkTesting.NewSpeedTestServer(*maxMB * 1024 * 1024)
+	if err != nil {
+		log.Fatalf("Failed to start speed-test server: %v", err)
+	}
+
+	server := &http.Server{
+		Handler:     speedTestServer.Handler(),
+		Addr:        *listen,
+		IdleTimeout: 120 * time.Second,
+	}
+
+	log.Printf("Speed-test server accessible on http://%s/speedtest/download and /speedtest/upload\n", *listen)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func main() {
 	printBanner()
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reflector":
+			runReflector(os.Args[2:])
+			return
+		case "speedtest-server":
+			runSpeedTestServer(os.Args[2:])
+			return
+		}
+	}
+
 	conf, err := config.NewConfig("config/config.json")
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
@@ -41,13 +107,16 @@ func main() {
 
 	repository := dataManagement.NewRepository(db, conf)
 	tester := networkTesting.NewNetworkTester(conf)
-	scheduler := scheduler.NewScheduler("http://"+conf.Ip+conf.Port, conf.Scheduler.Schedule)
 
-	schedulerHandler := handler.NewSchedulerHandler(scheduler)
-	networkTestHandler := handler.NewNetworkTestHandler(tester, repository)
+	running := networkTesting.NewRunningTests(conf.Scheduler.MaxConcurrentTests)
+
+	networkTestHandler := handler.NewNetworkTestHandler(tester, repository, running)
 	chartHandler := handler.NewChartHandler(repository, conf)
+	scheduler := scheduler.NewScheduler(handler.NewJobRunner(networkTestHandler, chartHandler, repository), conf.Scheduler.Schedule)
+
+	schedulerHandler := handler.NewSchedulerHandler(scheduler, repository)
 	utilHandler := &handler.UtilHandler{}
-	dashboardHandler := handler.NewDashboardHandler(repository, "internal/pageGeneration/templates/*.gohtml", scheduler)
+	dashboardHandler := handler.NewDashboardHandler(repository, "internal/pageGeneration/templates/*.gohtml", scheduler, running)
 	configHandler := handler.NewConfigHandler(conf, "config/config.json")
 
 	mux := middleware.NewRouteMux()
@@ -64,12 +133,15 @@ func main() {
 	mux.HandleFunc("/dashboard/tests/dates", middleware.LoggingMiddleware(dashboardHandler.ServeTestDatesSidebar))
 	mux.HandleFunc("/dashboard/tests/full", middleware.LoggingMiddleware(dashboardHandler.ServeTestQuadrantFull))
 	mux.HandleFunc("/dashboard/schedule", middleware.LoggingMiddleware(dashboardHandler.ServeSchedule))
+	mux.HandleFunc("/dashboard/queue", middleware.LoggingMiddleware(dashboardHandler.ServeQueue))
 
 	mux.HandleFunc("/networktest", middleware.LoggingMiddleware(networkTestHandler.HandleNetworkTest))
 	mux.HandleFunc("/networktest/delete", middleware.LoggingMiddleware(networkTestHandler.HandleDeleteTests))
 	mux.HandleFunc("/networktest/delete-result", middleware.LoggingMiddleware(networkTestHandler.HandleDeleteTestResult))
 	mux.HandleFunc("/networktest/delete-chart", middleware.LoggingMiddleware(networkTestHandler.HandleDeleteCharts))
 	mux.HandleFunc("/networktest/test-results", middleware.LoggingMiddleware(networkTestHandler.GetResults))
+	mux.HandleFunc("/networktest/running", middleware.LoggingMiddleware(networkTestHandler.HandleRunningTests))
+	mux.HandleFunc("/networktest/cancel", middleware.LoggingMiddleware(networkTestHandler.HandleCancelTest))
 
 	mux.HandleFunc("/charts/view", middleware.LoggingMiddleware(chartHandler.ServeChart))
 	mux.HandleFunc("/charts/generate", middleware.LoggingMiddleware(chartHandler.GenerateChart))
@@ -81,9 +153,19 @@ func main() {
 	mux.HandleFunc("/schedule/import", middleware.LoggingMiddleware(schedulerHandler.HandleImportSchedule))
 	mux.HandleFunc("/schedule/delete", middleware.LoggingMiddleware(schedulerHandler.HandleDeleteSchedule))
 	mux.HandleFunc("/schedule/edit", middleware.LoggingMiddleware(schedulerHandler.HandleEditSchedule))
+	mux.HandleFunc("/schedule/history", middleware.LoggingMiddleware(schedulerHandler.HandleTaskHistory))
 
 	mux.HandleFunc("/config", middleware.LoggingMiddleware(configHandler.ServeHTTP))
 
+	if conf.SpeedTestServer.Enabled {
+		speedTestServer, err := networkTesting.NewSpeedTestServer(int64(conf.SpeedTestServer.MaxMB) * 1024 * 1024)
+		if err != nil {
+			log.Fatalf("Failed to start speed-test server: %v", err)
+		}
+		mux.HandleFunc("/speedtest/download", middleware.LoggingMiddleware(speedTestServer.ServeDownload))
+		mux.HandleFunc("/speedtest/upload", middleware.LoggingMiddleware(speedTestServer.ServeUpload))
+	}
+
 	server := &http.Server{
 		Handler:      mux,
 		Addr:         conf.Port,
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 )
 
@@ -26,6 +27,9 @@ type Config struct {
 
 	// Scheduler Config
 	Scheduler SchedulerConfig `json:"scheduler"`
+
+	// Speed-test endpoints served to other instances
+	SpeedTestServer SpeedTestServerConfig `json:"speedTestServer"`
 }
 
 type DashboardSettings struct {
@@ -33,45 +37,178 @@ type DashboardSettings struct {
 }
 
 type TestConfigs struct {
-	ICMP          ICMPConfig      `json:"icmp"`
-	SpeedTestURLs SpeedTestURLs   `json:"speedTestURLs"`
-	RouteTest     RouteConfig     `json:"routeTest"`
-	LatencyTest   LatencyConfig   `json:"jitterTest"`
-	Bandwidth     BandwidthConfig `json:"bandwidth"`
+	ICMP          ICMPConfig          `json:"icmp"`
+	SpeedTestURLs SpeedTestURLs       `json:"speedTestURLs"`
+	RouteTest     RouteConfig         `json:"routeTest"`
+	LatencyTest   LatencyConfig       `json:"jitterTest"`
+	Bandwidth     BandwidthConfig     `json:"bandwidth"`
+	DNS           DNSConfig           `json:"dns"`
+	TCP           TCPConfig           `json:"tcp"`
+	HTTP          HTTPConfig          `json:"http"`
+	TLS           TLSConfig           `json:"tls"`
+	MTU           MTUConfig           `json:"mtu"`
+	LoadedLatency LoadedLatencyConfig `json:"loadedLatency"`
+	UDP           UDPConfig           `json:"udp"`
+}
+
+// SpeedTestServerConfig opts in to serving /speedtest/download and
+// /speedtest/upload on the dashboard's port, for other instances to test
+// against. They are unauthenticated, so anyone who can reach the dashboard
+// can fill the link with them; MaxMB caps a single transfer.
+type SpeedTestServerConfig struct {
+	Enabled bool `json:"enabled"`
+	MaxMB   int  `json:"maxMB"`
 }
 
 type SchedulerConfig struct {
 	Schedule string `json:"path_to_schedule"`
+	// MaxConcurrentTests caps how many tests, scheduled or manual, run at
+	// once; the rest queue. Zero uses the default of 2.
+	MaxConcurrentTests int `json:"max_concurrent_tests"`
 }
 
+// Targets are hostnames or IP addresses; each is pinged concurrently.
+// Family is "v4" (the default), "v6" or "both", which reports every target
+// once per family; a literal address has to be of the family pinged. Mode
+// picks the socket used by every ICMP-based test: "raw" needs root or
+// CAP_NET_RAW, "udp" uses unprivileged ping sockets, and "auto" tries raw
+// then falls back to udp.
 type ICMPConfig struct {
-	PacketCount    int `json:"packetCount"`
-	TimeoutSeconds int `json:"timeoutSeconds"`
+	Targets        []string `json:"targets"`
+	Mode           string   `json:"mode"`
+	Family         string   `json:"family"`
+	PacketCount    int      `json:"packetCount"`
+	TimeoutSeconds int      `json:"timeoutSeconds"`
 }
 
+// Mode "file", the default, transfers each URL once, whole, and divides its
+// size by the time taken. Mode "duration" is opt-in: it keeps Streams
+// parallel transfers to each URL running for DurationSeconds, samples
+// throughput every SampleIntervalMs and reports the steady-state rate after
+// the first WarmupSeconds, so connection setup and slow start don't count
+// and a slow link can't make a run drag on.
+// Uploads are UploadSizeMB each, generated as they are sent.
 type SpeedTestURLs struct {
-	DownloadURLs []string `json:"downloadUrls"`
-	UploadURLs   []string `json:"uploadUrls"`
+	DownloadURLs     []string `json:"downloadUrls"`
+	UploadURLs       []string `json:"uploadUrls"`
+	UploadSizeMB     int      `json:"uploadSizeMB"`
+	Mode             string   `json:"mode"`
+	Streams          int      `json:"streams"`
+	DurationSeconds  int      `json:"durationSeconds"`
+	WarmupSeconds    int      `json:"warmupSeconds"`
+	SampleIntervalMs int      `json:"sampleIntervalMs"`
 }
 
 type RouteConfig struct {
 	Target         string `json:"target"`
+	Family         string `json:"family"`
 	MaxHops        int    `json:"maxHops"`
+	ProbesPerHop   int    `json:"probesPerHop"`
+	Protocol       string `json:"protocol"`
+	Port           int    `json:"port"`
+	ASNFile        string `json:"asnFile"`
 	TimeoutSeconds int    `json:"timeoutSeconds"`
 }
 
+// Pings go out every IntervalMs without waiting for the previous reply, so
+// a slow or lost one doesn't delay the rest; TimeoutSeconds is how long
+// each one waits for its reply.
 type LatencyConfig struct {
 	Target         string `json:"target"`
+	Family         string `json:"family"`
 	PacketCount    int    `json:"packetCount"`
+	IntervalMs     int    `json:"intervalMs"`
 	TimeoutSeconds int    `json:"timeoutSeconds"`
 }
 
+// Each step downloads from DownloadURL on that many connections for
+// StepSeconds, restarting any download that finishes, and measures the
+// aggregate rate after the first WarmupSeconds. The test stops once the
+// aggregate falls DropThreshold percent below the best step so far.
+//
+// LegacyFailThreshold is the old failThreshold key, which measured the drop
+// in per-connection speed. That number means something else for the
+// aggregate, so it is never carried over: NewConfig warns about it and
+// drops it, and DropThreshold takes its default instead.
 type BandwidthConfig struct {
-	InitialConnections int     `json:"initialConnections"`
-	MaxConnections     int     `json:"maxConnections"`
-	StepSize           int     `json:"rampUpStep"`
-	FailThreshold      float64 `json:"failThreshold"`
-	DownloadURL        string  `json:"downloadUrl"`
+	InitialConnections  int     `json:"initialConnections"`
+	MaxConnections      int     `json:"maxConnections"`
+	StepSize            int     `json:"rampUpStep"`
+	StepSeconds         int     `json:"stepSeconds"`
+	WarmupSeconds       int     `json:"warmupSeconds"`
+	DropThreshold       float64 `json:"dropThreshold"`
+	LegacyFailThreshold float64 `json:"failThreshold,omitempty"`
+	DownloadURL         string  `json:"downloadUrl"`
+}
+
+// Resolvers are either "system" (the OS resolver) or an explicit server as
+// "ip" or "ip:port".
+type DNSConfig struct {
+	Hostnames      []string `json:"hostnames"`
+	Resolvers      []string `json:"resolvers"`
+	QueryType      string   `json:"queryType"`
+	TimeoutSeconds int      `json:"timeoutSeconds"`
+}
+
+// Targets are "host:port" pairs; each is dialled Count times.
+type TCPConfig struct {
+	Targets        []string `json:"targets"`
+	Count          int      `json:"count"`
+	TimeoutSeconds int      `json:"timeoutSeconds"`
+}
+
+// Each URL gets one GET on a fresh connection so DNS, TCP and TLS setup
+// are all included in the timing.
+type HTTPConfig struct {
+	URLs           []string `json:"urls"`
+	TimeoutSeconds int      `json:"timeoutSeconds"`
+}
+
+// Endpoints are "host:port" (port defaults to 443). A run is marked
+// PARTIAL when any chain fails validation or expires within
+// ExpiryWarningDays.
+type TLSConfig struct {
+	Endpoints         []string `json:"endpoints"`
+	TimeoutSeconds    int      `json:"timeoutSeconds"`
+	ExpiryWarningDays int      `json:"expiryWarningDays"`
+}
+
+// MTUConfig sizes are whole IP packets, headers included. A zero MinMTU
+// starts the search at the smallest MTU the address family allows.
+type MTUConfig struct {
+	Targets        []string `json:"targets"`
+	Family         string   `json:"family"`
+	MinMTU         int      `json:"minMTU"`
+	MaxMTU         int      `json:"maxMTU"`
+	Attempts       int      `json:"attempts"`
+	TimeoutSeconds int      `json:"timeoutSeconds"`
+}
+
+// LoadedLatencyConfig pings the latency test's target (over IPv4 when its
+// family is "both") while idle, then while DownloadURL and UploadURL keep the
+// link busy. Each phase sends PingCount pings IntervalMs apart, starting
+// WarmupSeconds into the load so queues have time to fill.
+type LoadedLatencyConfig struct {
+	DownloadURL    string `json:"downloadUrl"`
+	UploadURL      string `json:"uploadUrl"`
+	PingCount      int    `json:"pingCount"`
+	IntervalMs     int    `json:"intervalMs"`
+	WarmupSeconds  int    `json:"warmupSeconds"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+}
+
+// UDPConfig streams PacketSize-byte datagrams (UDP payload, header
+// included) at BitrateKbps for DurationSeconds to a reflector at Target,
+// which is another GoNetTest started with the "reflector" subcommand.
+// Target has no default, as there's no reflector to aim at until one is
+// started somewhere.
+// TimeoutSeconds bounds each wait for the reflector to answer.
+type UDPConfig struct {
+	Target          string `json:"target"`
+	BitrateKbps     int    `json:"bitrateKbps"`
+	PacketSize      int    `json:"packetSize"`
+	DurationSeconds int    `json:"durationSeconds"`
+	TimeoutSeconds  int    `json:"timeoutSeconds"`
 }
 
 func NewConfig(filepath string) (*Config, error) {
@@ -96,6 +233,19 @@ func NewConfig(filepath string) (*Config, error) {
 		config.Dash.RecentDays = 7 // Default to showing last 7 days
 	}
 
+	if config.SpeedTestServer.MaxMB <= 0 {
+		config.SpeedTestServer.MaxMB = 1024
+	}
+
+	if len(config.Tests.ICMP.Targets) == 0 {
+		config.Tests.ICMP.Targets = []string{"8.8.8.8", "1.1.1.1"}
+	}
+	if config.Tests.ICMP.Mode == "" {
+		config.Tests.ICMP.Mode = "auto"
+	}
+	if config.Tests.ICMP.Family == "" {
+		config.Tests.ICMP.Family = "v4"
+	}
 	if config.Tests.ICMP.PacketCount == 0 {
 		config.Tests.ICMP.PacketCount = 4
 	}
@@ -111,21 +261,54 @@ func NewConfig(filepath string) (*Config, error) {
 		}
 	}
 	if len(config.Tests.SpeedTestURLs.UploadURLs) == 0 {
-		config.Tests.SpeedTestURLs.DownloadURLs = []string{
+		config.Tests.SpeedTestURLs.UploadURLs = []string{
 			"https://httpbin.org/post",
 			"https://httpbin.org/anything",
 			"https://catbox.moe",
 		}
 	}
+	if config.Tests.SpeedTestURLs.UploadSizeMB == 0 {
+		config.Tests.SpeedTestURLs.UploadSizeMB = 10
+	}
+	if config.Tests.SpeedTestURLs.Mode == "" {
+		config.Tests.SpeedTestURLs.Mode = "file"
+	}
+	if config.Tests.SpeedTestURLs.Streams == 0 {
+		config.Tests.SpeedTestURLs.Streams = 4
+	}
+	if config.Tests.SpeedTestURLs.DurationSeconds == 0 {
+		config.Tests.SpeedTestURLs.DurationSeconds = 10
+	}
+	if config.Tests.SpeedTestURLs.WarmupSeconds == 0 {
+		config.Tests.SpeedTestURLs.WarmupSeconds = 2
+	}
+	if config.Tests.SpeedTestURLs.SampleIntervalMs == 0 {
+		config.Tests.SpeedTestURLs.SampleIntervalMs = 100
+	}
+	if config.Tests.RouteTest.Family == "" {
+		config.Tests.RouteTest.Family = "v4"
+	}
 	if config.Tests.RouteTest.MaxHops == 0 {
 		config.Tests.RouteTest.MaxHops = 30
 	}
+	if config.Tests.RouteTest.Protocol == "" {
+		config.Tests.RouteTest.Protocol = "icmp"
+	}
+	if config.Tests.RouteTest.ProbesPerHop == 0 {
+		config.Tests.RouteTest.ProbesPerHop = 3
+	}
 	if config.Tests.RouteTest.TimeoutSeconds == 0 {
 		config.Tests.RouteTest.TimeoutSeconds = 5
 	}
+	if config.Tests.LatencyTest.Family == "" {
+		config.Tests.LatencyTest.Family = "v4"
+	}
 	if config.Tests.LatencyTest.PacketCount == 0 {
 		config.Tests.LatencyTest.PacketCount = 10
 	}
+	if config.Tests.LatencyTest.IntervalMs == 0 {
+		config.Tests.LatencyTest.IntervalMs = 50
+	}
 	if config.Tests.LatencyTest.TimeoutSeconds == 0 {
 		config.Tests.LatencyTest.TimeoutSeconds = 5
 	}
@@ -139,13 +322,112 @@ func NewConfig(filepath string) (*Config, error) {
 	if config.Tests.Bandwidth.StepSize == 0 {
 		config.Tests.Bandwidth.StepSize = 2
 	}
-	if config.Tests.Bandwidth.FailThreshold == 0 {
-		config.Tests.Bandwidth.FailThreshold = 70 // bandwidth falls off fast with most isps
+	if config.Tests.Bandwidth.StepSeconds == 0 {
+		config.Tests.Bandwidth.StepSeconds = 10
+	}
+	if config.Tests.Bandwidth.WarmupSeconds == 0 {
+		config.Tests.Bandwidth.WarmupSeconds = 2
+	}
+	if config.Tests.Bandwidth.LegacyFailThreshold != 0 {
+		log.Printf("Ignoring tests.bandwidth.failThreshold (%g): it measured the per-connection drop; set dropThreshold, the percent the aggregate may fall below the best step, instead",
+			config.Tests.Bandwidth.LegacyFailThreshold)
+		config.Tests.Bandwidth.LegacyFailThreshold = 0
+	}
+	if config.Tests.Bandwidth.DropThreshold == 0 {
+		config.Tests.Bandwidth.DropThreshold = 30
 	}
 	if len(config.Tests.Bandwidth.DownloadURL) == 0 {
 		config.Tests.Bandwidth.DownloadURL = "http://ipv4.download.thinkbroadband.com/100MB.zip"
 	}
 
+	if len(config.Tests.DNS.Hostnames) == 0 {
+		config.Tests.DNS.Hostnames = []string{"google.com", "cloudflare.com", "github.com"}
+	}
+	if len(config.Tests.DNS.Resolvers) == 0 {
+		config.Tests.DNS.Resolvers = []string{"system", "1.1.1.1:53", "8.8.8.8:53"}
+	}
+	if config.Tests.DNS.QueryType == "" {
+		config.Tests.DNS.QueryType = "A"
+	}
+	if config.Tests.DNS.TimeoutSeconds == 0 {
+		config.Tests.DNS.TimeoutSeconds = 2
+	}
+
+	if len(config.Tests.TCP.Targets) == 0 {
+		config.Tests.TCP.Targets = []string{"1.1.1.1:443", "8.8.8.8:443"}
+	}
+	if config.Tests.TCP.Count == 0 {
+		config.Tests.TCP.Count = 10
+	}
+	if config.Tests.TCP.TimeoutSeconds == 0 {
+		config.Tests.TCP.TimeoutSeconds = 3
+	}
+
+	if len(config.Tests.HTTP.URLs) == 0 {
+		config.Tests.HTTP.URLs = []string{"https://www.google.com", "https://www.cloudflare.com", "https://github.com"}
+	}
+	if config.Tests.HTTP.TimeoutSeconds == 0 {
+		config.Tests.HTTP.TimeoutSeconds = 10
+	}
+
+	if len(config.Tests.TLS.Endpoints) == 0 {
+		config.Tests.TLS.Endpoints = []string{"www.google.com:443", "github.com:443"}
+	}
+	if config.Tests.TLS.TimeoutSeconds == 0 {
+		config.Tests.TLS.TimeoutSeconds = 5
+	}
+	if config.Tests.TLS.ExpiryWarningDays == 0 {
+		config.Tests.TLS.ExpiryWarningDays = 14
+	}
+
+	if len(config.Tests.MTU.Targets) == 0 {
+		config.Tests.MTU.Targets = []string{"8.8.8.8", "1.1.1.1"}
+	}
+	if config.Tests.MTU.Family == "" {
+		config.Tests.MTU.Family = "v4"
+	}
+	if config.Tests.MTU.MaxMTU == 0 {
+		config.Tests.MTU.MaxMTU = 1500
+	}
+	if config.Tests.MTU.Attempts == 0 {
+		config.Tests.MTU.Attempts = 2
+	}
+	if config.Tests.MTU.TimeoutSeconds == 0 {
+		config.Tests.MTU.TimeoutSeconds = 2
+	}
+
+	if config.Tests.LoadedLatency.DownloadURL == "" {
+		config.Tests.LoadedLatency.DownloadURL = "http://ipv4.download.thinkbroadband.com/100MB.zip"
+	}
+	if config.Tests.LoadedLatency.UploadURL == "" {
+		config.Tests.LoadedLatency.UploadURL = "https://httpbin.org/post"
+	}
+	if config.Tests.LoadedLatency.PingCount == 0 {
+		config.Tests.LoadedLatency.PingCount = 20
+	}
+	if config.Tests.LoadedLatency.IntervalMs == 0 {
+		config.Tests.LoadedLatency.IntervalMs = 200
+	}
+	if config.Tests.LoadedLatency.WarmupSeconds == 0 {
+		config.Tests.LoadedLatency.WarmupSeconds = 2
+	}
+	if config.Tests.LoadedLatency.TimeoutSeconds == 0 {
+		config.Tests.LoadedLatency.TimeoutSeconds = 2
+	}
+
+	if config.Tests.UDP.BitrateKbps == 0 {
+		config.Tests.UDP.BitrateKbps = 1000
+	}
+	if config.Tests.UDP.PacketSize == 0 {
+		config.Tests.UDP.PacketSize = 1200
+	}
+	if config.Tests.UDP.DurationSeconds == 0 {
+		config.Tests.UDP.DurationSeconds = 5
+	}
+	if config.Tests.UDP.TimeoutSeconds == 0 {
+		config.Tests.UDP.TimeoutSeconds = 2
+	}
+
 	return config, nil
 }
 
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewConfigSpeedTestModeDefaultsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"tests": {"speedTestURLs": {}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewConfig(path)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if got := config.Tests.SpeedTestURLs.Mode; got != "file" {
+		t.Errorf("SpeedTestURLs.Mode = %q, want %q", got, "file")
+	}
+}
+
+func TestNewConfigSpeedTestServerIsOptIn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewConfig(path)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if config.SpeedTestServer.Enabled {
+		t.Error("SpeedTestServer.Enabled = true, want the endpoints off unless configured")
+	}
+	if got := config.SpeedTestServer.MaxMB; got != 1024 {
+		t.Errorf("SpeedTestServer.MaxMB = %d, want 1024", got)
+	}
+}
+
+func TestNewConfigIgnoresLegacyBandwidthFailThreshold(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"tests": {"bandwidth": {"failThreshold": 80}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewConfig(path)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	// 80 was a per-connection drop; as an aggregate drop it would almost
+	// never stop the test, so the new default applies instead.
+	if got := config.Tests.Bandwidth.DropThreshold; got != 30 {
+		t.Errorf("Bandwidth.DropThreshold = %v, want the default 30", got)
+	}
+	if got := config.Tests.Bandwidth.LegacyFailThreshold; got != 0 {
+		t.Errorf("Bandwidth.LegacyFailThreshold = %v, want it dropped so it isn't saved again", got)
+	}
+}
@@ -2,7 +2,10 @@ module github.com/oshaw1/go-net-test
 
 go 1.25.0
 
-require golang.org/x/net v0.28.0
+require (
+	golang.org/x/net v0.28.0
+	modernc.org/sqlite v1.52.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -13,15 +16,14 @@ require (
 	modernc.org/libc v1.72.3 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.52.0 // indirect
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/go-echarts/go-echarts/v2 v2.4.1 // indirect
+	github.com/go-echarts/go-echarts/v2 v2.4.1
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
+	github.com/stretchr/testify v1.10.0
 	golang.org/x/sys v0.42.0 // indirect
 	golang.org/x/text v0.21.0
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -2,152 +2,180 @@ package charting
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
 	"github.com/oshaw1/go-net-test/internal/networkTesting"
 )
 
-func (g *Generator) GenerateBandwidthAnalysisCharts(result *networkTesting.BandwidthTestResult) (*charts.Bar3D, *charts.Bar3D, error) {
-	bar3dSpeed, err := generateBandwidth3DBarSpeed(result)
+func (g *Generator) GenerateBandwidthAnalysisCharts(result *networkTesting.BandwidthTestResult) (*charts.Line, *charts.Bar3D, error) {
+	capacityLine, err := generateBandwidthCapacityLine(result)
 	if err != nil {
 		return nil, nil, err
 	}
-	bar3dDuration, err := generateBandwidth3DBarDuration(result)
+	bar3dSpeed, err := generateBandwidth3DBarSpeed(result)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return bar3dSpeed, bar3dDuration, nil
+	return capacityLine, bar3dSpeed, nil
 }
 
-func (g *Generator) GenerateHistoricBandwidthAnalysisCharts(results []*networkTesting.BandwidthTestResult) (*charts.Bar3D, *charts.Bar3D, error) {
+func (g *Generator) GenerateHistoricBandwidthAnalysisCharts(results []*networkTesting.BandwidthTestResult) (*charts.Line, *charts.Bar3D, error) {
 	if results == nil {
 		return nil, nil, fmt.Errorf("function called with no results")
 	}
 
-	speedBar, err := generateBandwidthSpeedOverTimeBar(results)
+	capacityLine, err := generateBandwidthCapacityOverTimeLine(results)
 	if err != nil {
 		return nil, nil, err
 	}
-	durationBar, err := generateBandwidthDurationOverTimeBar(results)
+	speedBar, err := generateBandwidthSpeedOverTimeBar(results)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return speedBar, durationBar, nil
+	return capacityLine, speedBar, nil
 }
 
-func generateBandwidthSpeedOverTimeBar(results []*networkTesting.BandwidthTestResult) (*charts.Bar3D, error) {
-	bar3d := charts.NewBar3D()
+// stepCapacity is a step's aggregate rate. Results saved before the
+// aggregate was recorded only have the per-connection average.
+func stepCapacity(step networkTesting.ConnectionStep) float64 {
+	if step.AggregateMbps > 0 {
+		return step.AggregateMbps
+	}
+	return step.AvgSpeed * float64(step.Connections)
+}
 
-	maxSteps := 0
-	maxSpeed := 0.0
-	for _, result := range results {
-		if len(result.Steps) > maxSteps {
-			maxSteps = len(result.Steps)
-		}
-		for _, step := range result.Steps {
-			if step.AvgSpeed > maxSpeed {
-				maxSpeed = step.AvgSpeed
-			}
-		}
+// capacityPoints pairs each step's connection count with a value, for a
+// line on a numeric connections axis.
+func capacityPoints(steps []networkTesting.ConnectionStep, value func(networkTesting.ConnectionStep) float64) []opts.LineData {
+	items := make([]opts.LineData, 0, len(steps))
+	for _, step := range steps {
+		items = append(items, opts.LineData{Value: []interface{}{step.Connections, value(step)}})
 	}
+	return items
+}
 
-	bar3d.SetGlobalOptions(
+// generateBandwidthCapacityLine plots what the link delivered in total as
+// connections were added, the share each connection got, and how evenly
+// it was shared.
+func generateBandwidthCapacityLine(result *networkTesting.BandwidthTestResult) (*charts.Line, error) {
+	if result == nil || len(result.Steps) == 0 {
+		return nil, fmt.Errorf("function called with no steps")
+	}
+	line := charts.NewLine()
+
+	line.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
-			Title:    "Bandwidth Speed Over Time",
-			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+			Title: "Aggregate Capacity vs Connections",
+			Subtitle: fmt.Sprintf("Peak: %.2f Mbps at %d connections  Test ran at: %v",
+				result.MaxThroughput, result.OptimalConns, result.StartTime.Format("2006-01-02 15:04:05")),
 		}),
-		charts.WithVisualMapOpts(opts.VisualMap{
-			Calculable: opts.Bool(true),
-			Max:        float32(maxSpeed + 25),
-			Min:        0,
-			InRange: &opts.VisualMapInRange{
-				Color: []string{"#a50026", "#d73027", "#f46d43", "#fdae61", "#fee090",
-					"#ffffbf", "#e0f3f8", "#abd9e9", "#74add1", "#4575b4", "#313695"},
-			},
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Connections",
+			Type: "value",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Speed (Mbps)",
+			NameLocation: "middle",
+			NameGap:      45,
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Top: "15%",
 		}),
 	)
 
-	var data []opts.Chart3DData
-	for resultIdx, result := range results {
-		for stepIdx, step := range result.Steps {
-			data = append(data, opts.Chart3DData{
-				Value: []interface{}{resultIdx, stepIdx, step.AvgSpeed},
-				Name:  fmt.Sprintf("%d connections", step.Connections),
-			})
-		}
-	}
-
-	xAxis := make([]string, len(results))
-	for i, result := range results {
-		xAxis[i] = result.StartTime.Format("2006-01-02 15:04:05")
-	}
+	// Fairness runs from 0 to 1, so it gets its own axis.
+	line.ExtendYAxis(opts.YAxis{
+		Name:         "Fairness (Jain)",
+		NameLocation: "middle",
+		NameGap:      35,
+		Min:          0,
+		Max:          1,
+	})
+
+	line.AddSeries("Aggregate (Mbps)", capacityPoints(result.Steps, stepCapacity)).
+		AddSeries("Per connection (Mbps)", capacityPoints(result.Steps, func(step networkTesting.ConnectionStep) float64 {
+			return step.AvgSpeed
+		})).
+		AddSeries("Fairness", capacityPoints(result.Steps, func(step networkTesting.ConnectionStep) float64 {
+			return step.Fairness
+		}), charts.WithLineChartOpts(opts.LineChart{YAxisIndex: 1}))
+
+	return line, nil
+}
 
-	yAxis := make([]int, maxSteps)
-	for i := range yAxis {
-		yAxis[i] = i + 1
-	}
+// generateBandwidthCapacityOverTimeLine draws each run's capacity curve,
+// so a link that saturates at fewer connections than it used to stands out.
+func generateBandwidthCapacityOverTimeLine(results []*networkTesting.BandwidthTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
 
-	bar3d.AddSeries("Bandwidth Speed", data).
-		SetSeriesOptions(
-			charts.WithBar3DChartOpts(opts.Bar3DChart{
-				Shading: "lambert",
-			}),
-		)
-
-	bar3d.SetGlobalOptions(
-		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(false)}),
-		charts.WithXAxis3DOpts(opts.XAxis3D{
-			Name: "Timestamp",
-			Data: xAxis,
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Aggregate Capacity vs Connections Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
 		}),
-		charts.WithYAxis3DOpts(opts.YAxis3D{
-			Show: opts.Bool(true),
-			Data: yAxis,
-			Max:  float32(maxSteps),
-			Min:  1,
-			Name: "Step",
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Connections",
 			Type: "value",
 		}),
-		charts.WithZAxis3DOpts(opts.ZAxis3D{
-			Name: "Speed (Mbps)",
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Aggregate (Mbps)",
+			NameLocation: "middle",
+			NameGap:      45,
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(true),
+			Type: "scroll",
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Top: "15%",
 		}),
 	)
 
-	return bar3d, nil
+	for _, result := range results {
+		line.AddSeries(result.StartTime.Format("2006-01-02 15:04:05"), capacityPoints(result.Steps, stepCapacity))
+	}
+
+	return line, nil
 }
 
-func generateBandwidthDurationOverTimeBar(results []*networkTesting.BandwidthTestResult) (*charts.Bar3D, error) {
+func generateBandwidthSpeedOverTimeBar(results []*networkTesting.BandwidthTestResult) (*charts.Bar3D, error) {
 	bar3d := charts.NewBar3D()
 
 	maxSteps := 0
-	var maxDuration time.Duration
+	maxSpeed := 0.0
 	for _, result := range results {
 		if len(result.Steps) > maxSteps {
 			maxSteps = len(result.Steps)
 		}
 		for _, step := range result.Steps {
-			if step.Duration > maxDuration {
-				maxDuration = step.Duration
-			}
+			maxSpeed = max(maxSpeed, stepCapacity(step))
 		}
 	}
 
 	bar3d.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
-			Title:    "Bandwidth Duration Over Time",
+			Title:    "Aggregate Bandwidth Over Time",
 			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
 		}),
 		charts.WithVisualMapOpts(opts.VisualMap{
 			Calculable: opts.Bool(true),
-			Max:        float32(maxDuration.Seconds()),
+			Max:        float32(maxSpeed + 25),
 			Min:        0,
 			InRange: &opts.VisualMapInRange{
-				Color: []string{"#313695", "#4575b4", "#74add1", "#abd9e9", "#e0f3f8",
-					"#ffffbf", "#fee090", "#fdae61", "#f46d43", "#d73027", "#a50026"},
+				Color: []string{"#a50026", "#d73027", "#f46d43", "#fdae61", "#fee090",
+					"#ffffbf", "#e0f3f8", "#abd9e9", "#74add1", "#4575b4", "#313695"},
 			},
 		}),
 	)
@@ -156,7 +184,7 @@ func generateBandwidthDurationOverTimeBar(results []*networkTesting.BandwidthTes
 	for resultIdx, result := range results {
 		for stepIdx, step := range result.Steps {
 			data = append(data, opts.Chart3DData{
-				Value: []interface{}{resultIdx, stepIdx, step.Duration.Seconds()},
+				Value: []interface{}{resultIdx, stepIdx, stepCapacity(step)},
 				Name:  fmt.Sprintf("%d connections", step.Connections),
 			})
 		}
@@ -172,7 +200,7 @@ func generateBandwidthDurationOverTimeBar(results []*networkTesting.BandwidthTes
 		yAxis[i] = i + 1
 	}
 
-	bar3d.AddSeries("Bandwidth Duration", data).
+	bar3d.AddSeries("Bandwidth Speed", data).
 		SetSeriesOptions(
 			charts.WithBar3DChartOpts(opts.Bar3DChart{
 				Shading: "lambert",
@@ -194,7 +222,7 @@ func generateBandwidthDurationOverTimeBar(results []*networkTesting.BandwidthTes
 			Type: "value",
 		}),
 		charts.WithZAxis3DOpts(opts.ZAxis3D{
-			Name: "Duration (s)",
+			Name: "Aggregate (Mbps)",
 		}),
 	)
 
@@ -204,13 +232,20 @@ func generateBandwidthDurationOverTimeBar(results []*networkTesting.BandwidthTes
 func generateBandwidth3DBarSpeed(result *networkTesting.BandwidthTestResult) (*charts.Bar3D, error) {
 	bar3d := charts.NewBar3D()
 
+	maxSpeed := 0.0
+	for _, step := range result.Steps {
+		for _, conn := range step.ConnResults {
+			maxSpeed = max(maxSpeed, conn.Speed)
+		}
+	}
+
 	bar3d.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
-			Title: "Bandwidth Analysis by Download Speed",
+			Title: "Bandwidth Analysis by Connection Speed",
 		}),
 		charts.WithVisualMapOpts(opts.VisualMap{
 			Calculable: opts.Bool(true),
-			Max:        float32(result.MaxThroughput + 25),
+			Max:        float32(maxSpeed + 25),
 			Min:        0,
 			InRange: &opts.VisualMapInRange{
 				Color: []string{"#a50026", "#d73027", "#f46d43", "#fdae61", "#fee090",
@@ -293,104 +328,3 @@ func generateBandwidth3DBarSpeed(result *networkTesting.BandwidthTestResult) (*c
 
 	return bar3d, nil
 }
-
-func generateBandwidth3DBarDuration(result *networkTesting.BandwidthTestResult) (*charts.Bar3D, error) {
-	bar3d := charts.NewBar3D()
-
-	var maxDuration time.Duration
-	for _, step := range result.Steps {
-		for _, conn := range step.ConnResults {
-			if conn.Duration > maxDuration {
-				maxDuration = conn.Duration
-			}
-		}
-	}
-
-	bar3d.SetGlobalOptions(
-		charts.WithTitleOpts(opts.Title{
-			Title: "Bandwidth Analysis by Download Elapse Time",
-		}),
-		charts.WithVisualMapOpts(opts.VisualMap{
-			Calculable: opts.Bool(true),
-			Max:        float32(maxDuration.Seconds()),
-			Min:        0,
-			InRange: &opts.VisualMapInRange{
-				Color: []string{"#313695", "#4575b4", "#74add1", "#abd9e9", "#e0f3f8",
-					"#ffffbf", "#fee090", "#fdae61", "#f46d43", "#d73027", "#a50026"},
-			},
-		}),
-		charts.WithYAxisOpts(opts.YAxis{
-			Name:         "Elapse Time (s)",
-			NameLocation: "middle",
-			NameGap:      35,
-			AxisLabel: &opts.AxisLabel{
-				Show:         opts.Bool(true),
-				Rotate:       90,
-				ShowMaxLabel: opts.Bool(true),
-			},
-		}),
-		charts.WithXAxisOpts(opts.XAxis{
-			AxisLabel: &opts.AxisLabel{
-				Show:         opts.Bool(true),
-				ShowMaxLabel: opts.Bool(true),
-			},
-		}),
-	)
-
-	var data []opts.Chart3DData
-
-	for stepIdx, step := range result.Steps {
-		for _, conn := range step.ConnResults {
-			data = append(data, opts.Chart3DData{
-				Value: []interface{}{
-					stepIdx,                          // X axis (step number)
-					conn.ID + 1,                      // Y axis (connection number)
-					float64(conn.Duration.Seconds()), // Z axis (duration in seconds)
-				},
-			})
-		}
-	}
-
-	xAxis := make([]int, len(result.Steps))
-	for i := range xAxis {
-		xAxis[i] = i + 1
-	}
-
-	maxConns := 0
-	for _, step := range result.Steps {
-		if step.Connections > maxConns {
-			maxConns = step.Connections
-		}
-	}
-
-	yAxis := make([]int, maxConns)
-	for i := range yAxis {
-		yAxis[i] = i
-	}
-
-	bar3d.AddSeries("Duration", data).
-		SetSeriesOptions(
-			charts.WithBar3DChartOpts(opts.Bar3DChart{
-				Shading: "lambert",
-			}),
-		)
-
-	bar3d.SetGlobalOptions(
-		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(false)}),
-		charts.WithXAxis3DOpts(opts.XAxis3D{
-			Name: "Step",
-			Data: xAxis,
-		}),
-		charts.WithYAxis3DOpts(opts.YAxis3D{
-			Name: "Connection",
-			Data: yAxis,
-			Max:  float32(maxConns),
-			Min:  1,
-		}),
-		charts.WithZAxis3DOpts(opts.ZAxis3D{
-			Name: "Duration (seconds)",
-		}),
-	)
-
-	return bar3d, nil
-}
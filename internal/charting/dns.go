@@ -0,0 +1,161 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateDNSAnalysisCharts(result *networkTesting.DNSTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateDNSAnalysisCharts called with no results")
+	}
+
+	bar, err := generateDNSLatencyBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricDNSAnalysisCharts(results []*networkTesting.DNSTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricDNSAnalysisCharts called with no results")
+	}
+
+	line, err := generateDNSOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func generateDNSLatencyBar(result *networkTesting.DNSTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	var hostnames []string
+	var resolvers []string
+	latencies := make(map[string]map[string]float64)
+	failures := 0
+
+	for _, query := range result.Queries {
+		if _, ok := latencies[query.Resolver]; !ok {
+			latencies[query.Resolver] = make(map[string]float64)
+			resolvers = append(resolvers, query.Resolver)
+		}
+		if !containsString(hostnames, query.Hostname) {
+			hostnames = append(hostnames, query.Hostname)
+		}
+		if query.Failed {
+			failures++
+			continue
+		}
+		latencies[query.Resolver][query.Hostname] = float64(query.Latency.Microseconds()) / 1000
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "DNS Resolution Latency",
+			Subtitle: fmt.Sprintf("Query type: %s  Failed queries: %d/%d  Test ran at: %v",
+				result.QueryType, failures, len(result.Queries), result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Latency (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	bar.SetXAxis(hostnames)
+	for _, resolver := range resolvers {
+		values := make([]float64, len(hostnames))
+		for i, hostname := range hostnames {
+			values[i] = latencies[resolver][hostname]
+		}
+		bar.AddSeries(resolver, generateBarItems(values))
+	}
+
+	return bar, nil
+}
+
+func generateDNSOverTimeLine(results []*networkTesting.DNSTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	var resolvers []string
+	for _, result := range results {
+		for _, summary := range result.Resolvers {
+			if !containsString(resolvers, summary.Resolver) {
+				resolvers = append(resolvers, summary.Resolver)
+			}
+		}
+	}
+
+	series := make(map[string][]float64, len(resolvers))
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+
+		avgByResolver := make(map[string]float64, len(result.Resolvers))
+		for _, summary := range result.Resolvers {
+			avgByResolver[summary.Resolver] = float64(summary.AvgLatency.Microseconds()) / 1000
+		}
+		for _, resolver := range resolvers {
+			series[resolver] = append(series[resolver], avgByResolver[resolver])
+		}
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "DNS Resolver Latency Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Average Latency (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for _, resolver := range resolvers {
+		line.AddSeries(resolver, generateLineItems(series[resolver]))
+	}
+
+	return line, nil
+}
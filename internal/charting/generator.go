@@ -6,7 +6,7 @@ import (
 )
 
 type ChartGenerator interface {
-	GenerateICMPAnalysisCharts(result *networkTesting.ICMPTestResult) (*charts.Pie, error)
+	GenerateICMPAnalysisCharts(result *networkTesting.MultiHostICMPResult) (*charts.Pie, error)
 	GenerateJitterAnalysisCharts(result *networkTesting.LatencyTestResult) (*charts.Line, error)
 	GenerateRouteAnalysisCharts(result *networkTesting.RouteTestResult) (*charts.Line, error)
 	GenerateDownloadAnalysisCharts(result *networkTesting.AverageSpeedTestResult) (*charts.Line, error)
@@ -0,0 +1,165 @@
+package charting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+var httpPhaseNames = []string{"DNS", "TCP Connect", "TLS Handshake", "TTFB", "Transfer"}
+
+func (g *Generator) GenerateHTTPAnalysisCharts(result *networkTesting.HTTPTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateHTTPAnalysisCharts called with no results")
+	}
+
+	bar, err := generateHTTPPhaseBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricHTTPAnalysisCharts(results []*networkTesting.HTTPTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricHTTPAnalysisCharts called with no results")
+	}
+
+	line, err := generateHTTPOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func httpPhaseDurations(request networkTesting.HTTPPhaseResult) []time.Duration {
+	return []time.Duration{request.DNSLookup, request.TCPConnect, request.TLSHandshake, request.TTFB, request.Transfer}
+}
+
+func generateHTTPPhaseBar(result *networkTesting.HTTPTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	var xAxis []string
+	phases := make([][]float64, len(httpPhaseNames))
+	failures := 0
+
+	for _, request := range result.Requests {
+		label := request.URL
+		if request.Failed {
+			failures++
+			label = fmt.Sprintf("%s (failed)", request.URL)
+		}
+		xAxis = append(xAxis, label)
+		for i, d := range httpPhaseDurations(request) {
+			phases[i] = append(phases[i], float64(d.Microseconds())/1000)
+		}
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "HTTP Request Phase Breakdown",
+			Subtitle: fmt.Sprintf("Failed requests: %d/%d  Test ran at: %v",
+				failures, len(result.Requests), result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Time (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	bar.SetXAxis(xAxis)
+	for i, name := range httpPhaseNames {
+		bar.AddSeries(name, generateBarItems(phases[i]),
+			charts.WithBarChartOpts(opts.BarChart{Stack: "phases"}))
+	}
+
+	return bar, nil
+}
+
+func generateHTTPOverTimeLine(results []*networkTesting.HTTPTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	series := make([][]float64, len(httpPhaseNames))
+
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+
+		// Average each phase over the requests that completed in this run.
+		totals := make([]time.Duration, len(httpPhaseNames))
+		succeeded := 0
+		for _, request := range result.Requests {
+			if request.Failed {
+				continue
+			}
+			succeeded++
+			for i, d := range httpPhaseDurations(request) {
+				totals[i] += d
+			}
+		}
+		for i := range httpPhaseNames {
+			var avg float64
+			if succeeded > 0 {
+				avg = float64((totals[i] / time.Duration(succeeded)).Microseconds()) / 1000
+			}
+			series[i] = append(series[i], avg)
+		}
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "HTTP Request Phases Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Average time (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for i, name := range httpPhaseNames {
+		line.AddSeries(name, generateLineItems(series[i]))
+	}
+
+	return line, nil
+}
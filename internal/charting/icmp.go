@@ -2,13 +2,18 @@ package charting
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
 	"github.com/oshaw1/go-net-test/internal/networkTesting"
 )
 
-func (g *Generator) GenerateICMPAnalysisCharts(result *networkTesting.ICMPTestResult) (*charts.Bar, error) {
+func (g *Generator) GenerateICMPAnalysisCharts(result *networkTesting.MultiHostICMPResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("function called with no results")
+	}
+
 	bar, err := generateICMPDistributionBar(result)
 	if err != nil {
 		return nil, err
@@ -17,7 +22,7 @@ func (g *Generator) GenerateICMPAnalysisCharts(result *networkTesting.ICMPTestRe
 	return bar, nil
 }
 
-func (g *Generator) GenerateHistoricICMPAnalysisCharts(results []*networkTesting.ICMPTestResult) (*charts.Bar, error) {
+func (g *Generator) GenerateHistoricICMPAnalysisCharts(results []*networkTesting.MultiHostICMPResult) (*charts.Bar, error) {
 	if results == nil {
 		return nil, fmt.Errorf("function called with no results")
 	}
@@ -30,14 +35,28 @@ func (g *Generator) GenerateHistoricICMPAnalysisCharts(results []*networkTesting
 	return barOverTime, nil
 }
 
-func generateICMPDistributionBar(result *networkTesting.ICMPTestResult) (*charts.Bar, error) {
+func generateICMPDistributionBar(result *networkTesting.MultiHostICMPResult) (*charts.Bar, error) {
 	bar := charts.NewBar()
 
+	var hosts []string
+	var received, lost []float64
+	for _, host := range result.Hosts {
+		label := icmpHostLabel(host)
+		if host.Error != "" {
+			label = fmt.Sprintf("%s (failed)", label)
+		} else if host.Received > 0 {
+			label = fmt.Sprintf("%s (avg %v)", label, host.AvgRTT.Round(time.Microsecond*100))
+		}
+		hosts = append(hosts, label)
+		received = append(received, float64(host.Received))
+		lost = append(lost, float64(host.Lost))
+	}
+
 	// Configure global options
 	bar.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
 			Title:    "ICMP Packet Distribution",
-			Subtitle: fmt.Sprintf("Test ran at: %v", result.Timestamp),
+			Subtitle: fmt.Sprintf("Test ran at: %v", result.Timestamp.Format("2006-01-02 15:04:05")),
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true)}),
 		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
@@ -45,11 +64,11 @@ func generateICMPDistributionBar(result *networkTesting.ICMPTestResult) (*charts
 		charts.WithColorsOpts(opts.Colors{"#4169E1", "#FF0000"}),
 	)
 
-	// Set X axis with categories
-	bar.SetXAxis([]string{"Packets"})
+	// One category per host
+	bar.SetXAxis(hosts)
 
 	// Add series for Received packets
-	bar.AddSeries("Received", []opts.BarData{{Value: result.Received}}).
+	bar.AddSeries("Received", generateBarItems(received)).
 		SetSeriesOptions(
 			charts.WithLabelOpts(opts.Label{
 				Show:      opts.Bool(true),
@@ -59,7 +78,7 @@ func generateICMPDistributionBar(result *networkTesting.ICMPTestResult) (*charts
 		)
 
 	// Add series for Lost packets
-	bar.AddSeries("Lost", []opts.BarData{{Value: result.Lost}}).
+	bar.AddSeries("Lost", generateBarItems(lost)).
 		SetSeriesOptions(
 			charts.WithLabelOpts(opts.Label{
 				Show:      opts.Bool(true),
@@ -71,17 +90,38 @@ func generateICMPDistributionBar(result *networkTesting.ICMPTestResult) (*charts
 	return bar, nil
 }
 
-func generateICMPOverTimeBar(results []*networkTesting.ICMPTestResult) (*charts.Bar, error) {
+// generateICMPOverTimeBar stacks received and lost packets per host, so each
+// timestamp shows one bar per target.
+func generateICMPOverTimeBar(results []*networkTesting.MultiHostICMPResult) (*charts.Bar, error) {
 	bar := charts.NewBar()
 
 	var xAxis []string
-	var received []float64
-	var lost []float64
+	var hosts []string
+	for _, result := range results {
+		for _, host := range result.Hosts {
+			if label := icmpHostLabel(host); !containsString(hosts, label) {
+				hosts = append(hosts, label)
+			}
+		}
+	}
 
+	received := make(map[string][]float64, len(hosts))
+	lost := make(map[string][]float64, len(hosts))
 	for _, result := range results {
 		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
-		received = append(received, float64(result.Received))
-		lost = append(lost, float64(result.Lost))
+
+		byHost := make(map[string]*networkTesting.ICMPTestResult, len(result.Hosts))
+		for _, host := range result.Hosts {
+			byHost[icmpHostLabel(host)] = host
+		}
+		for _, host := range hosts {
+			var r, l float64
+			if hostResult, ok := byHost[host]; ok {
+				r, l = float64(hostResult.Received), float64(hostResult.Lost)
+			}
+			received[host] = append(received[host], r)
+			lost[host] = append(lost[host], l)
+		}
 	}
 
 	bar.SetGlobalOptions(
@@ -101,16 +141,28 @@ func generateICMPOverTimeBar(results []*networkTesting.ICMPTestResult) (*charts.
 			},
 		}),
 		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
-		charts.WithColorsOpts(opts.Colors{"#4169E1", "#FF0000"}),
 		charts.WithGridOpts(opts.Grid{
 			Bottom: "20%",
-			Top:    "10%",
+			Top:    "15%",
 		}),
 	)
 
-	bar.SetXAxis(xAxis).
-		AddSeries("Received", generateBarItems(received)).
-		AddSeries("Lost", generateBarItems(lost))
+	bar.SetXAxis(xAxis)
+	for _, host := range hosts {
+		bar.AddSeries(fmt.Sprintf("%s Received", host), generateBarItems(received[host]),
+			charts.WithBarChartOpts(opts.BarChart{Stack: host}))
+		bar.AddSeries(fmt.Sprintf("%s Lost", host), generateBarItems(lost[host]),
+			charts.WithBarChartOpts(opts.BarChart{Stack: host}))
+	}
 
 	return bar, nil
 }
+
+// icmpHostLabel names a host's series. IPv6 runs are suffixed so a target
+// tested over both families gets two distinct series.
+func icmpHostLabel(host *networkTesting.ICMPTestResult) string {
+	if host.Family == networkTesting.FamilyV6 {
+		return host.Host + " (IPv6)"
+	}
+	return host.Host
+}
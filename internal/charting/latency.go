@@ -2,8 +2,6 @@ package charting
 
 import (
 	"fmt"
-	"math"
-	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
@@ -33,17 +31,17 @@ func (g *Generator) GenerateHistoricLatencyAnalysisCharts(results []*networkTest
 }
 
 func generateLatencyLineChart(result *networkTesting.LatencyTestResult) (*charts.Line, error) {
-	var totalTime time.Duration
-	for _, rtt := range result.RTTs {
-		totalTime += rtt
-	}
-
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
 			Title:    "Packet RTT Over Time",
-			Subtitle: fmt.Sprintf("Total Time: %.2fms  Target: %s", float64(totalTime.Microseconds())/1000, result.Target),
+			Subtitle: fmt.Sprintf("%s  Target: %s", latencySummary(result), result.Target),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
 		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
 		charts.WithYAxisOpts(opts.YAxis{
 			Name:         "Return time (Ms)",
 			NameLocation: "middle",
@@ -65,35 +63,73 @@ func generateLatencyLineChart(result *networkTesting.LatencyTestResult) (*charts
 		}),
 	)
 
-	xAxis := make([]int, len(result.RTTs))
-	rttData := make([]opts.LineData, len(result.RTTs))
-	for i, rtt := range result.RTTs {
+	packets := latencySequenceLength(result)
+	if result.IPv6 != nil {
+		packets = max(packets, latencySequenceLength(result.IPv6))
+	}
+	xAxis := make([]int, packets)
+	for i := range xAxis {
 		xAxis[i] = i + 1
-		rttData[i] = opts.LineData{Value: float64(rtt.Milliseconds())}
 	}
 
-	line.SetXAxis(xAxis).AddSeries("RTT (ms)", rttData)
+	line.SetXAxis(xAxis).AddSeries("RTT (ms)", latencyLineData(result), latencyPercentileLines(result))
+	if result.IPv6 != nil {
+		line.AddSeries("IPv6 RTT (ms)", latencyLineData(result.IPv6), latencyPercentileLines(result.IPv6))
+	}
 	return line, nil
 }
 
+// latencySummary is the one-line set of figures quoted for a run.
+func latencySummary(result *networkTesting.LatencyTestResult) string {
+	return fmt.Sprintf("p50 %.1fms  p90 %.1fms  p99 %.1fms  σ %.2fms  Jitter (RFC 3550) %.2fms  Loss %.1f%% (%d bursts, longest %d)",
+		durationMs(result.P50RTT), durationMs(result.P90RTT), durationMs(result.P99RTT),
+		durationMs(result.StdDevRTT), durationMs(result.Jitter),
+		result.PacketLoss, result.LossBursts, result.MaxBurst)
+}
+
+func latencySequenceLength(result *networkTesting.LatencyTestResult) int {
+	return len(result.RTTs) + len(result.LostPackets)
+}
+
+// latencyLineData puts each RTT back at its sequence number, leaving a gap
+// in the line wherever a packet was lost.
+func latencyLineData(result *networkTesting.LatencyTestResult) []opts.LineData {
+	data := make([]opts.LineData, latencySequenceLength(result))
+	lost := make(map[int]bool, len(result.LostPackets))
+	for _, seq := range result.LostPackets {
+		lost[seq] = true
+	}
+
+	next := 0
+	for i := range data {
+		if lost[i] {
+			data[i] = opts.LineData{Value: "-"}
+			continue
+		}
+		data[i] = opts.LineData{Value: durationMs(result.RTTs[next])}
+		next++
+	}
+	return data
+}
+
+func latencyPercentileLines(result *networkTesting.LatencyTestResult) charts.SeriesOpts {
+	return charts.WithMarkLineNameYAxisItemOpts(
+		opts.MarkLineNameYAxisItem{Name: "p50", YAxis: durationMs(result.P50RTT)},
+		opts.MarkLineNameYAxisItem{Name: "p99", YAxis: durationMs(result.P99RTT)},
+	)
+}
+
 func generateLatencyOverTimeBar(results []*networkTesting.LatencyTestResult) (*charts.Bar, error) {
 	bar := charts.NewBar()
 	var xAxis []string
-	var avgLatency []float64
-	var minLatency []float64
-	var maxLatency []float64
+	var p50, p99, jitter, avgIPDV []float64
 
 	for _, result := range results {
-		var Latencys []float64
-		for i := 1; i < len(result.RTTs); i++ {
-			Latency := math.Abs(float64(result.RTTs[i]-result.RTTs[i-1])) / 1000000
-			Latencys = append(Latencys, Latency)
-		}
-
-		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
-		avgLatency = append(avgLatency, calculateAverage(Latencys))
-		minLatency = append(minLatency, findMin(Latencys))
-		maxLatency = append(maxLatency, findMax(Latencys))
+		xAxis = append(xAxis, fmt.Sprintf("%s (%.1f%% loss)", result.Timestamp.Format("2006-01-02 15:04:05"), result.PacketLoss))
+		p50 = append(p50, durationMs(result.P50RTT))
+		p99 = append(p99, durationMs(result.P99RTT))
+		jitter = append(jitter, durationMs(result.Jitter))
+		avgIPDV = append(avgIPDV, durationMs(result.AvgLatency))
 	}
 
 	bar.SetGlobalOptions(
@@ -106,7 +142,7 @@ func generateLatencyOverTimeBar(results []*networkTesting.LatencyTestResult) (*c
 			Trigger: "axis",
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name:         "RTT (ms)",
+			Name:         "ms",
 			NameLocation: "middle",
 			NameGap:      35,
 		}),
@@ -125,9 +161,10 @@ func generateLatencyOverTimeBar(results []*networkTesting.LatencyTestResult) (*c
 	)
 
 	bar.SetXAxis(xAxis).
-		AddSeries("Min RTT", generateBarItems(minLatency)).
-		AddSeries("Average RTT", generateBarItems(avgLatency)).
-		AddSeries("Max RTT", generateBarItems(maxLatency))
+		AddSeries("p50 RTT", generateBarItems(p50)).
+		AddSeries("p99 RTT", generateBarItems(p99)).
+		AddSeries("Jitter (RFC 3550)", generateBarItems(jitter)).
+		AddSeries("Avg Packet Delay Variation", generateBarItems(avgIPDV))
 
 	return bar, nil
 }
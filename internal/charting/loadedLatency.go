@@ -0,0 +1,151 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateLoadedLatencyAnalysisCharts(result *networkTesting.LoadedLatencyTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateLoadedLatencyAnalysisCharts called with no results")
+	}
+
+	bar, err := generateLoadedLatencyPhaseBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricLoadedLatencyAnalysisCharts(results []*networkTesting.LoadedLatencyTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricLoadedLatencyAnalysisCharts called with no results")
+	}
+
+	line, err := generateLoadedLatencyOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func loadedLatencyPhaseLabel(name string, phase networkTesting.LoadedLatencyPhase) string {
+	switch {
+	case phase.Error != "":
+		return fmt.Sprintf("%s (failed)", name)
+	case phase.Mbps > 0:
+		return fmt.Sprintf("%s (%.1f Mbps)", name, phase.Mbps)
+	default:
+		return name
+	}
+}
+
+// generateLoadedLatencyPhaseBar shows min, median and p95 RTT for the idle,
+// download and upload phases side by side, so the jump under load stands out.
+func generateLoadedLatencyPhaseBar(result *networkTesting.LoadedLatencyTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	phases := []struct {
+		name  string
+		phase networkTesting.LoadedLatencyPhase
+	}{
+		{"Idle", result.Idle},
+		{"Download", result.Download},
+		{"Upload", result.Upload},
+	}
+
+	var xAxis []string
+	var minRTT, medianRTT, p95RTT []float64
+	for _, p := range phases {
+		xAxis = append(xAxis, loadedLatencyPhaseLabel(p.name, p.phase))
+		minRTT = append(minRTT, durationMs(p.phase.MinRTT))
+		medianRTT = append(medianRTT, durationMs(p.phase.MedianRTT))
+		p95RTT = append(p95RTT, durationMs(p.phase.P95RTT))
+	}
+
+	grade := result.Grade
+	if grade == "" {
+		grade = "n/a"
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "Latency Under Load",
+			Subtitle: fmt.Sprintf("Grade: %s  Target: %s  Test ran at: %v",
+				grade, result.Target, result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "RTT (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Top: "15%",
+		}),
+	)
+
+	bar.SetXAxis(xAxis).
+		AddSeries("Min RTT", generateBarItems(minRTT)).
+		AddSeries("Median RTT", generateBarItems(medianRTT)).
+		AddSeries("P95 RTT", generateBarItems(p95RTT))
+
+	return bar, nil
+}
+
+func generateLoadedLatencyOverTimeLine(results []*networkTesting.LoadedLatencyTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	var idle, download, upload []float64
+	for _, result := range results {
+		xAxis = append(xAxis, fmt.Sprintf("%s (%s)", result.Timestamp.Format("2006-01-02 15:04:05"), result.Grade))
+		idle = append(idle, durationMs(result.Idle.MedianRTT))
+		download = append(download, durationMs(result.Download.MedianRTT))
+		upload = append(upload, durationMs(result.Upload.MedianRTT))
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Latency Under Load Over Time",
+			Subtitle: fmt.Sprintf("Median RTT per phase  Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "RTT (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis).
+		AddSeries("Idle", generateLineItems(idle)).
+		AddSeries("Download", generateLineItems(download)).
+		AddSeries("Upload", generateLineItems(upload))
+
+	return line, nil
+}
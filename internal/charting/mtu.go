@@ -0,0 +1,171 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateMTUAnalysisCharts(result *networkTesting.MTUTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateMTUAnalysisCharts called with no results")
+	}
+
+	bar, err := generateMTUBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricMTUAnalysisCharts(results []*networkTesting.MTUTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricMTUAnalysisCharts called with no results")
+	}
+
+	line, err := generateMTUOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+// mtuTargetName names a target's series. IPv6 runs are suffixed so a target
+// tested over both families gets two distinct series.
+func mtuTargetName(target networkTesting.MTUTargetResult) string {
+	if target.Family == networkTesting.FamilyV6 {
+		return target.Target + " (IPv6)"
+	}
+	return target.Target
+}
+
+func mtuTargetLabel(target networkTesting.MTUTargetResult) string {
+	switch {
+	case target.Failed:
+		return fmt.Sprintf("%s (failed)", mtuTargetName(target))
+	case target.BlackHole:
+		return fmt.Sprintf("%s (black hole)", mtuTargetName(target))
+	default:
+		return mtuTargetName(target)
+	}
+}
+
+func generateMTUBar(result *networkTesting.MTUTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	var xAxis []string
+	var mtus []float64
+
+	for _, target := range result.Targets {
+		xAxis = append(xAxis, mtuTargetLabel(target))
+		mtus = append(mtus, float64(target.MTU))
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Path MTU",
+			Subtitle: fmt.Sprintf("Largest unfragmented packet per target  Test ran at: %v", result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "MTU (bytes)",
+			NameLocation: "middle",
+			NameGap:      45,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	bar.SetXAxis(xAxis).
+		AddSeries("Path MTU", generateBarItems(mtus)).
+		SetSeriesOptions(
+			charts.WithLabelOpts(opts.Label{
+				Show:      opts.Bool(true),
+				Position:  "top",
+				Formatter: "{c}",
+			}),
+		)
+
+	return bar, nil
+}
+
+func generateMTUOverTimeLine(results []*networkTesting.MTUTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	var targets []string
+	for _, result := range results {
+		for _, target := range result.Targets {
+			if name := mtuTargetName(target); !containsString(targets, name) {
+				targets = append(targets, name)
+			}
+		}
+	}
+
+	series := make(map[string][]float64, len(targets))
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+
+		mtuByTarget := make(map[string]float64, len(result.Targets))
+		for _, target := range result.Targets {
+			if !target.Failed {
+				mtuByTarget[mtuTargetName(target)] = float64(target.MTU)
+			}
+		}
+		for _, target := range targets {
+			series[target] = append(series[target], mtuByTarget[target])
+		}
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Path MTU Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "MTU (bytes)",
+			NameLocation: "middle",
+			NameGap:      45,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for _, target := range targets {
+		line.AddSeries(target, generateLineItems(series[target]))
+	}
+
+	return line, nil
+}
@@ -3,6 +3,7 @@ package charting
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
@@ -19,30 +20,102 @@ func (g *Generator) GenerateRouteAnalysisCharts(result *networkTesting.RouteTest
 	return pie, nil
 }
 
+// generateRouteRequestPathChart plots the min, mean and max RTT of each hop
+// so the spread shows where jitter enters the path. Loss is written under the
+// hop number, and each mean point is named after the hop's responders.
 func generateRouteRequestPathChart(result *networkTesting.RouteTestResult) (*charts.Line, error) {
 	line := charts.NewLine()
-	line.SetGlobalOptions(charts.WithTitleOpts(opts.Title{
-		Title:    "Route Test RTT by Hop",
-		Subtitle: fmt.Sprintf("Target: %s, Test ran at: %v", result.Target, result.Timestamp),
-	}))
-
-	xAxis := make([]string, 0)
-	rttData := make([]opts.LineData, 0)
-
-	for _, hop := range result.Hops {
-		hopLabel := fmt.Sprintf("%d", hop.Number)
-		xAxis = append(xAxis, hopLabel)
-		if hop.Lost {
-			rttData = append(rttData, opts.LineData{Value: 0.0})
-		} else {
-			rttData = append(rttData, opts.LineData{Value: float64(hop.RTT) / 1e6})
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Route Test RTT by Hop",
+			Subtitle: fmt.Sprintf("Target: %s via %s, Test ran at: %v", result.Target, routeProtocolLabel(result), result.Timestamp),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "RTT (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Hop",
+		}),
+	)
+
+	hops := len(result.Hops)
+	if result.IPv6 != nil && len(result.IPv6.Hops) > hops {
+		hops = len(result.IPv6.Hops)
+	}
+	xAxis := make([]string, hops)
+	for i := range xAxis {
+		xAxis[i] = fmt.Sprintf("%d", i+1)
+		if i < len(result.Hops) && result.Hops[i].Loss > 0 {
+			xAxis[i] += fmt.Sprintf("\n%.0f%% loss", result.Hops[i].Loss)
 		}
 	}
 
-	line.SetXAxis(xAxis).AddSeries("RTT (s)", rttData)
+	minRTT, avgRTT, maxRTT := routeSpreadData(result.Hops)
+	line.SetXAxis(xAxis).
+		AddSeries("Min RTT (ms)", minRTT).
+		AddSeries("Avg RTT (ms)", avgRTT).
+		AddSeries("Max RTT (ms)", maxRTT)
+	if result.IPv6 != nil {
+		_, avgRTT6, _ := routeSpreadData(result.IPv6.Hops)
+		line.AddSeries("IPv6 Avg RTT (ms)", avgRTT6)
+	}
 	return line, nil
 }
 
+// routeSpreadData returns per-hop min, mean and max RTT in milliseconds. Rows
+// stored before hops carried every RTT fall back to the single recorded one.
+func routeSpreadData(hops []networkTesting.RouteHop) (minRTT, avgRTT, maxRTT []opts.LineData) {
+	for _, hop := range hops {
+		var rtts []float64
+		for _, rtt := range hop.RTTs {
+			rtts = append(rtts, float64(rtt)/1e6)
+		}
+		if len(rtts) == 0 && !hop.Lost {
+			rtts = []float64{float64(hop.RTT) / 1e6}
+		}
+
+		name := routeHopLabel(hop)
+		if len(rtts) == 0 {
+			minRTT = append(minRTT, opts.LineData{Value: 0.0})
+			avgRTT = append(avgRTT, opts.LineData{Value: 0.0, Name: name})
+			maxRTT = append(maxRTT, opts.LineData{Value: 0.0})
+			continue
+		}
+		minRTT = append(minRTT, opts.LineData{Value: findMin(rtts)})
+		avgRTT = append(avgRTT, opts.LineData{Value: calculateAverage(rtts), Name: name})
+		maxRTT = append(maxRTT, opts.LineData{Value: findMax(rtts)})
+	}
+	return minRTT, avgRTT, maxRTT
+}
+
+// routeHopLabel describes who answered at a hop, e.g.
+// "ae1.example.net (192.0.2.1, AS64500) / 192.0.2.9".
+func routeHopLabel(hop networkTesting.RouteHop) string {
+	if len(hop.Responders) == 0 {
+		if hop.Address == "" {
+			return "no reply"
+		}
+		return hop.Address
+	}
+
+	labels := make([]string, len(hop.Responders))
+	for i, r := range hop.Responders {
+		label := r.Address
+		if r.ASN != 0 {
+			label = fmt.Sprintf("%s, AS%d", label, r.ASN)
+		}
+		if r.Hostname != "" {
+			label = fmt.Sprintf("%s (%s)", r.Hostname, label)
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, " / ")
+}
+
 func (g *Generator) GenerateHistoricRouteAnalysisCharts(results []*networkTesting.RouteTestResult) (*charts.Bar3D, error) {
 	if results == nil {
 		return nil, fmt.Errorf("function called with no results")
@@ -56,9 +129,30 @@ func (g *Generator) GenerateHistoricRouteAnalysisCharts(results []*networkTestin
 	return bar3d, nil
 }
 
+// generateRoute3DBar only plots traces made the same way as the newest one,
+// since UDP, TCP and ICMP probes can take different paths and see different
+// filtering.
 func generateRoute3DBar(results []*networkTesting.RouteTestResult) (*charts.Bar3D, error) {
 	bar3d := charts.NewBar3D()
 
+	var protocol string
+	if len(results) > 0 {
+		newest := results[0]
+		for _, result := range results {
+			if result.Timestamp.After(newest.Timestamp) {
+				newest = result
+			}
+		}
+		protocol = routeProtocolLabel(newest)
+		matching := make([]*networkTesting.RouteTestResult, 0, len(results))
+		for _, result := range results {
+			if routeProtocolLabel(result) == protocol {
+				matching = append(matching, result)
+			}
+		}
+		results = matching
+	}
+
 	hopNumbers := make(map[int]bool)
 	var maxRTT time.Duration
 	for _, result := range results {
@@ -80,7 +174,7 @@ func generateRoute3DBar(results []*networkTesting.RouteTestResult) (*charts.Bar3
 	bar3d.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{
 			Title:    "Route Latency Analysis Over Time",
-			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+			Subtitle: fmt.Sprintf("Test data from: %v Days, %s probes", len(results), protocol),
 		}),
 		charts.WithVisualMapOpts(opts.VisualMap{
 			Calculable: opts.Bool(true),
@@ -150,3 +244,14 @@ func generateRoute3DBar(results []*networkTesting.RouteTestResult) (*charts.Bar3
 
 	return bar3d, nil
 }
+
+// routeProtocolLabel names how a trace was probed, e.g. "TCP/443". Rows from
+// before the protocol was recorded were always ICMP.
+func routeProtocolLabel(result *networkTesting.RouteTestResult) string {
+	switch result.Protocol {
+	case networkTesting.RouteProtocolUDP, networkTesting.RouteProtocolTCP:
+		return fmt.Sprintf("%s/%d", strings.ToUpper(result.Protocol), result.Port)
+	default:
+		return "ICMP"
+	}
+}
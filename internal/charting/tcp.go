@@ -0,0 +1,148 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateTCPAnalysisCharts(result *networkTesting.TCPTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateTCPAnalysisCharts called with no results")
+	}
+
+	bar, err := generateTCPConnectBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricTCPAnalysisCharts(results []*networkTesting.TCPTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricTCPAnalysisCharts called with no results")
+	}
+
+	line, err := generateTCPOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func generateTCPConnectBar(result *networkTesting.TCPTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	var xAxis []string
+	var minTimes, avgTimes, p95Times, maxTimes []float64
+
+	for _, target := range result.Targets {
+		xAxis = append(xAxis, fmt.Sprintf("%s (%d/%d)", target.Target, target.Connected, target.Attempts))
+		minTimes = append(minTimes, float64(target.MinTime.Microseconds())/1000)
+		avgTimes = append(avgTimes, float64(target.AvgTime.Microseconds())/1000)
+		p95Times = append(p95Times, float64(target.P95Time.Microseconds())/1000)
+		maxTimes = append(maxTimes, float64(target.MaxTime.Microseconds())/1000)
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "TCP Connect Time by Target",
+			Subtitle: fmt.Sprintf("Connected/attempted shown per target  Test ran at: %v", result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Connect time (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	bar.SetXAxis(xAxis).
+		AddSeries("Min", generateBarItems(minTimes)).
+		AddSeries("Average", generateBarItems(avgTimes)).
+		AddSeries("P95", generateBarItems(p95Times)).
+		AddSeries("Max", generateBarItems(maxTimes))
+
+	return bar, nil
+}
+
+func generateTCPOverTimeLine(results []*networkTesting.TCPTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	var targets []string
+	for _, result := range results {
+		for _, target := range result.Targets {
+			if !containsString(targets, target.Target) {
+				targets = append(targets, target.Target)
+			}
+		}
+	}
+
+	series := make(map[string][]float64, len(targets))
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+
+		avgByTarget := make(map[string]float64, len(result.Targets))
+		for _, target := range result.Targets {
+			avgByTarget[target.Target] = float64(target.AvgTime.Microseconds()) / 1000
+		}
+		for _, target := range targets {
+			series[target] = append(series[target], avgByTarget[target])
+		}
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "TCP Connect Time Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Average connect time (ms)",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for _, target := range targets {
+		line.AddSeries(target, generateLineItems(series[target]))
+	}
+
+	return line, nil
+}
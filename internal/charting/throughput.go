@@ -0,0 +1,96 @@
+package charting
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateDownloadThroughputChart(result *networkTesting.AverageSpeedTestResult) (*charts.Line, error) {
+	if result == nil || !result.Sampled() {
+		return nil, fmt.Errorf("GenerateDownloadThroughputChart called with no sampled results")
+	}
+
+	return generateThroughputSamplesLine(result, "Download"), nil
+}
+
+func (g *Generator) GenerateUploadThroughputChart(result *networkTesting.AverageSpeedTestResult) (*charts.Line, error) {
+	if result == nil || !result.Sampled() {
+		return nil, fmt.Errorf("GenerateUploadThroughputChart called with no sampled results")
+	}
+
+	return generateThroughputSamplesLine(result, "Upload"), nil
+}
+
+// generateThroughputSamplesLine plots each URL's sampled rate across a
+// duration-bounded run, shading the warm-up that was left out of the
+// reported speed.
+func generateThroughputSamplesLine(result *networkTesting.AverageSpeedTestResult, direction string) *charts.Line {
+	line := charts.NewLine()
+
+	var urls []string
+	var interval time.Duration
+	var samples, warmup int
+	for url, test := range result.TestedURLs {
+		if len(test.Samples) == 0 {
+			continue
+		}
+		urls = append(urls, url)
+		interval = test.SampleInterval
+		samples = max(samples, len(test.Samples))
+		warmup = max(warmup, test.WarmupSamples)
+	}
+	sort.Strings(urls)
+
+	xAxis := make([]string, samples)
+	for i := range xAxis {
+		xAxis[i] = fmt.Sprintf("%.1fs", (time.Duration(i+1) * interval).Seconds())
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: fmt.Sprintf("%s Throughput During Test", direction),
+			Subtitle: fmt.Sprintf("Steady state: %.2f Mbps  Sampled every %v  Test ran at: %v",
+				result.AverageMbps, interval, result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Speed (Mbps)",
+			NameLocation: "middle",
+			NameGap:      45,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name: "Elapsed",
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Top: "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for i, url := range urls {
+		seriesOpts := []charts.SeriesOpts{charts.WithLineChartOpts(opts.LineChart{ShowSymbol: opts.Bool(false)})}
+		if i == 0 && warmup > 0 {
+			seriesOpts = append(seriesOpts,
+				charts.WithMarkAreaData([]opts.MarkAreaData{
+					{Name: "Warm-up", XAxis: xAxis[0]},
+					{XAxis: xAxis[min(warmup, samples)-1]},
+				}),
+				charts.WithMarkAreaStyleOpts(opts.MarkAreaStyle{
+					ItemStyle: &opts.ItemStyle{Color: "rgba(128, 128, 128, 0.15)"},
+				}),
+			)
+		}
+		line.AddSeries(url, generateLineItems(result.TestedURLs[url].Samples), seriesOpts...)
+	}
+
+	return line
+}
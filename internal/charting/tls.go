@@ -0,0 +1,155 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateTLSAnalysisCharts(result *networkTesting.TLSTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateTLSAnalysisCharts called with no results")
+	}
+
+	bar, err := generateTLSExpiryBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricTLSAnalysisCharts(results []*networkTesting.TLSTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricTLSAnalysisCharts called with no results")
+	}
+
+	line, err := generateTLSExpiryOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+func tlsEndpointLabel(endpoint networkTesting.TLSEndpointResult) string {
+	switch {
+	case endpoint.Failed:
+		return fmt.Sprintf("%s (failed)", endpoint.Endpoint)
+	case !endpoint.Valid:
+		return fmt.Sprintf("%s (%s, invalid)", endpoint.Endpoint, endpoint.Version)
+	default:
+		return fmt.Sprintf("%s (%s)", endpoint.Endpoint, endpoint.Version)
+	}
+}
+
+func generateTLSExpiryBar(result *networkTesting.TLSTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	var xAxis []string
+	var days []float64
+
+	for _, endpoint := range result.Endpoints {
+		xAxis = append(xAxis, tlsEndpointLabel(endpoint))
+		days = append(days, float64(endpoint.DaysUntilExpiry))
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "TLS Certificate Expiry",
+			Subtitle: fmt.Sprintf("Earliest expiry in each served chain  Test ran at: %v", result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Days until expiry",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	bar.SetXAxis(xAxis).
+		AddSeries("Days Until Expiry", generateBarItems(days))
+
+	return bar, nil
+}
+
+func generateTLSExpiryOverTimeLine(results []*networkTesting.TLSTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	var endpoints []string
+	for _, result := range results {
+		for _, endpoint := range result.Endpoints {
+			if !containsString(endpoints, endpoint.Endpoint) {
+				endpoints = append(endpoints, endpoint.Endpoint)
+			}
+		}
+	}
+
+	series := make(map[string][]float64, len(endpoints))
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+
+		daysByEndpoint := make(map[string]float64, len(result.Endpoints))
+		for _, endpoint := range result.Endpoints {
+			if !endpoint.Failed {
+				daysByEndpoint[endpoint.Endpoint] = float64(endpoint.DaysUntilExpiry)
+			}
+		}
+		for _, endpoint := range endpoints {
+			series[endpoint] = append(series[endpoint], daysByEndpoint[endpoint])
+		}
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "TLS Certificate Expiry Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Days until expiry",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for _, endpoint := range endpoints {
+		line.AddSeries(endpoint, generateLineItems(series[endpoint]))
+	}
+
+	return line, nil
+}
@@ -0,0 +1,133 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateUDPAnalysisCharts(result *networkTesting.UDPTestResult) (*charts.Bar, error) {
+	if result == nil {
+		return nil, fmt.Errorf("GenerateUDPAnalysisCharts called with no results")
+	}
+
+	bar, err := generateUDPDeliveryBar(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return bar, nil
+}
+
+func (g *Generator) GenerateHistoricUDPAnalysisCharts(results []*networkTesting.UDPTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricUDPAnalysisCharts called with no results")
+	}
+
+	line, err := generateUDPOverTimeLine(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return line, nil
+}
+
+// generateUDPDeliveryBar breaks the stream down by what became of each
+// datagram, with the rates and jitter in the subtitle.
+func generateUDPDeliveryBar(result *networkTesting.UDPTestResult) (*charts.Bar, error) {
+	bar := charts.NewBar()
+
+	xAxis := []string{"Sent", "Received", "Lost", "Reordered", "Duplicates"}
+	counts := []float64{
+		float64(result.Sent),
+		float64(result.Received),
+		float64(result.Lost),
+		float64(result.Reordered),
+		float64(result.Duplicates),
+	}
+
+	bar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title: "UDP Stream Delivery",
+			Subtitle: fmt.Sprintf("%s  Offered: %.2f Mbps  Received: %.2f Mbps  Loss: %.2f%%  Jitter: %.2fms  Test ran at: %v",
+				result.Target, float64(result.BitrateKbps)/1000, result.ThroughputMbps, result.LossPercent,
+				durationMs(result.Jitter), result.Timestamp.Format("2006-01-02 15:04:05")),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Datagrams",
+			NameLocation: "middle",
+			NameGap:      45,
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Top: "15%",
+		}),
+	)
+
+	bar.SetXAxis(xAxis).
+		AddSeries(fmt.Sprintf("%d-byte datagrams", result.PacketSize), generateBarItems(counts))
+
+	return bar, nil
+}
+
+func generateUDPOverTimeLine(results []*networkTesting.UDPTestResult) (*charts.Line, error) {
+	line := charts.NewLine()
+
+	var xAxis []string
+	var throughput, loss, jitter []float64
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+		throughput = append(throughput, result.ThroughputMbps)
+		loss = append(loss, result.LossPercent)
+		jitter = append(jitter, durationMs(result.Jitter))
+	}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "UDP Throughput, Loss and Jitter Over Time",
+			Subtitle: fmt.Sprintf("Test data from: %v Days", len(results)),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Mbps",
+			NameLocation: "middle",
+			NameGap:      35,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	// Loss and jitter share a second axis; they're both small numbers
+	// that would be flattened against throughput.
+	line.ExtendYAxis(opts.YAxis{
+		Name:         "Loss (%) / Jitter (ms)",
+		NameLocation: "middle",
+		NameGap:      35,
+	})
+
+	line.SetXAxis(xAxis).
+		AddSeries("Throughput (Mbps)", generateLineItems(throughput)).
+		AddSeries("Loss (%)", generateLineItems(loss), charts.WithLineChartOpts(opts.LineChart{YAxisIndex: 1})).
+		AddSeries("Jitter (ms)", generateLineItems(jitter), charts.WithLineChartOpts(opts.LineChart{YAxisIndex: 1}))
+
+	return line, nil
+}
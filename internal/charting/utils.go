@@ -1,6 +1,10 @@
 package charting
 
-import "github.com/go-echarts/go-echarts/v2/opts"
+import (
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
 
 func generateBarItems(speeds []float64) []opts.BarData {
 	items := make([]opts.BarData, len(speeds))
@@ -45,3 +49,16 @@ func findMax(values []float64) float64 {
 	}
 	return max
 }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
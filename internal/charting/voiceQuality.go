@@ -0,0 +1,152 @@
+package charting
+
+import (
+	"fmt"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+func (g *Generator) GenerateHistoricLatencyMOSChart(results []*networkTesting.LatencyTestResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricLatencyMOSChart called with no results")
+	}
+
+	var xAxis []string
+	series := map[string][]float64{}
+	names := []string{"MOS"}
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+		series["MOS"] = append(series["MOS"], result.MOS)
+		if result.IPv6 != nil && !containsString(names, "IPv6 MOS") {
+			names = append(names, "IPv6 MOS")
+		}
+	}
+	if len(names) > 1 {
+		for _, result := range results {
+			var mos float64
+			if result.IPv6 != nil {
+				mos = result.IPv6.MOS
+			}
+			series["IPv6 MOS"] = append(series["IPv6 MOS"], mos)
+		}
+	}
+
+	return generateMOSOverTimeLine(xAxis, names, series, len(results)), nil
+}
+
+func (g *Generator) GenerateHistoricICMPMOSChart(results []*networkTesting.MultiHostICMPResult) (*charts.Line, error) {
+	if results == nil {
+		return nil, fmt.Errorf("GenerateHistoricICMPMOSChart called with no results")
+	}
+
+	var xAxis []string
+	var hosts []string
+	for _, result := range results {
+		for _, host := range result.Hosts {
+			if label := icmpHostLabel(host); !containsString(hosts, label) {
+				hosts = append(hosts, label)
+			}
+		}
+	}
+
+	series := make(map[string][]float64, len(hosts))
+	for _, result := range results {
+		xAxis = append(xAxis, result.Timestamp.Format("2006-01-02 15:04:05"))
+
+		mosByHost := make(map[string]float64, len(result.Hosts))
+		for _, host := range result.Hosts {
+			mosByHost[icmpHostLabel(host)] = host.MOS
+		}
+		for _, host := range hosts {
+			series[host] = append(series[host], mosByHost[host])
+		}
+	}
+
+	return generateMOSOverTimeLine(xAxis, hosts, series, len(results)), nil
+}
+
+// mosBands are the ITU-T G.109 user satisfaction categories, as MOS ranges.
+var mosBands = []struct {
+	name   string
+	lo, hi float64
+	color  string
+}{
+	{"Best", 4.34, 4.5, "rgba(46, 204, 113, 0.15)"},
+	{"High", 4.03, 4.34, "rgba(52, 152, 219, 0.15)"},
+	{"Medium", 3.60, 4.03, "rgba(241, 196, 15, 0.15)"},
+	{"Low", 3.10, 3.60, "rgba(230, 126, 34, 0.15)"},
+	{"Poor", 2.58, 3.10, "rgba(231, 76, 60, 0.15)"},
+	{"Not recommended", 1, 2.58, "rgba(127, 140, 141, 0.15)"},
+}
+
+// mosBandEdge is one corner of a mark area. opts.MarkAreaData can't be used
+// here: it serialises its y bound as "YAxis", which echarts ignores.
+type mosBandEdge struct {
+	Name      string          `json:"name,omitempty"`
+	YAxis     float64         `json:"yAxis"`
+	ItemStyle *opts.ItemStyle `json:"itemStyle,omitempty"`
+}
+
+func withMOSBands() charts.SeriesOpts {
+	return func(s *charts.SingleSeries) {
+		areas := &opts.MarkAreas{
+			MarkAreaStyle: opts.MarkAreaStyle{
+				Label: &opts.Label{Show: opts.Bool(true), Position: "insideLeft"},
+			},
+		}
+		for _, band := range mosBands {
+			areas.Data = append(areas.Data, []mosBandEdge{
+				{Name: band.name, YAxis: band.lo, ItemStyle: &opts.ItemStyle{Color: band.color}},
+				{YAxis: band.hi},
+			})
+		}
+		s.MarkAreas = areas
+	}
+}
+
+func generateMOSOverTimeLine(xAxis, names []string, series map[string][]float64, days int) *charts.Line {
+	line := charts.NewLine()
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Estimated Call Quality (MOS) Over Time",
+			Subtitle: fmt.Sprintf("E-model estimate for a G.711 call  Test data from: %v Days", days),
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "MOS",
+			NameLocation: "middle",
+			NameGap:      35,
+			Min:          1,
+			Max:          4.5,
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			AxisLabel: &opts.AxisLabel{
+				Show:         opts.Bool(true),
+				Rotate:       45,
+				ShowMaxLabel: opts.Bool(true),
+			},
+		}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+		charts.WithGridOpts(opts.Grid{
+			Bottom: "20%",
+			Top:    "15%",
+		}),
+	)
+
+	line.SetXAxis(xAxis)
+	for i, name := range names {
+		if i == 0 {
+			line.AddSeries(name, generateLineItems(series[name]), withMOSBands())
+			continue
+		}
+		line.AddSeries(name, generateLineItems(series[name]))
+	}
+
+	return line
+}
@@ -45,6 +45,20 @@ func initSchema(db *sql.DB) error {
 		CREATE INDEX IF NOT EXISTS idx_charts_result ON charts(result_id);
 		CREATE INDEX IF NOT EXISTS idx_charts_type_time ON charts(test_type, timestamp);
 
+		CREATE TABLE IF NOT EXISTS task_runs (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id    TEXT     NOT NULL,
+			task_name  TEXT     NOT NULL,
+			started_at DATETIME NOT NULL,
+			ended_at   DATETIME NOT NULL,
+			attempt    INTEGER  NOT NULL DEFAULT 1,
+			status     TEXT     NOT NULL,
+			error      TEXT,
+			result_id  INTEGER REFERENCES test_results(id) ON DELETE SET NULL,
+			charts     TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id, started_at);
+
 		PRAGMA foreign_keys = ON;
 	`)
 	if err != nil {
@@ -58,6 +72,10 @@ func initSchema(db *sql.DB) error {
 		!strings.Contains(err.Error(), "duplicate column") {
 		return fmt.Errorf("failed to migrate charts table: %w", err)
 	}
+	if _, err := db.Exec(`ALTER TABLE task_runs ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1`); err != nil &&
+		!strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("failed to migrate task_runs table: %w", err)
+	}
 
 	return nil
 }
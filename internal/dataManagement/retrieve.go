@@ -196,6 +196,70 @@ func (r *Repository) MapTestsByTimestamp(date, testType string) (map[string]*Tes
 	return records, historicRows.Err()
 }
 
+// taskRunTimeFormat is how task_runs stores its times, in UTC and to the
+// millisecond; it matches what strftime's %f gives back.
+const taskRunTimeFormat = "2006-01-02 15:04:05.000"
+
+// TaskRun is one run of a scheduled task.
+type TaskRun struct {
+	ID        int64     `json:"id"`
+	TaskID    string    `json:"task_id"`
+	TaskName  string    `json:"task_name"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Attempt   int       `json:"attempt"` // 1 for a scheduled run, counting up through its retries
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	ResultID  int64     `json:"result_id,omitempty"` // test_results.id, for test tasks
+	Charts    string    `json:"charts,omitempty"`    // space-separated /charts/view paths, for chart tasks
+}
+
+// GetTaskRuns returns a task's most recent runs, newest first. A limit of
+// zero or less returns them all.
+func (r *Repository) GetTaskRuns(taskID string, limit int) ([]TaskRun, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := r.db.Query(`
+		SELECT id, task_id, task_name,
+		       strftime('%Y-%m-%d %H:%M:%f', started_at), strftime('%Y-%m-%d %H:%M:%f', ended_at),
+		       attempt, status, error, result_id, charts
+		FROM task_runs
+		WHERE task_id = ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, taskID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []TaskRun{}
+	for rows.Next() {
+		var (
+			run              TaskRun
+			startedAt, ended string
+			errText, charts  sql.NullString
+			resultID         sql.NullInt64
+		)
+		if err := rows.Scan(&run.ID, &run.TaskID, &run.TaskName, &startedAt, &ended,
+			&run.Attempt, &run.Status, &errText, &resultID, &charts); err != nil {
+			return nil, err
+		}
+		if run.StartedAt, err = time.Parse(taskRunTimeFormat, startedAt); err != nil {
+			return nil, fmt.Errorf("malformed start time for task run %d: %w", run.ID, err)
+		}
+		if run.EndedAt, err = time.Parse(taskRunTimeFormat, ended); err != nil {
+			return nil, fmt.Errorf("malformed end time for task run %d: %w", run.ID, err)
+		}
+		run.Error = errText.String
+		run.ResultID = resultID.Int64
+		run.Charts = charts.String
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
 func (r *Repository) GetChartHTML(id int64) (string, error) {
 	var html string
 	err := r.db.QueryRow(`SELECT html_content FROM charts WHERE id = ?`, id).Scan(&html)
@@ -209,11 +273,11 @@ func unmarshalTestResult(data []byte, testType string) (*networkTesting.TestResu
 	result := &networkTesting.TestResult{}
 	switch testType {
 	case "icmp":
-		var v networkTesting.ICMPTestResult
-		if err := json.Unmarshal(data, &v); err != nil {
+		v, err := unmarshalICMPResult(data)
+		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal ICMP JSON: %w", err)
 		}
-		result.ICMP = &v
+		result.ICMP = v
 	case "download":
 		var v networkTesting.AverageSpeedTestResult
 		if err := json.Unmarshal(data, &v); err != nil {
@@ -244,8 +308,78 @@ func unmarshalTestResult(data []byte, testType string) (*networkTesting.TestResu
 			return nil, fmt.Errorf("failed to unmarshal route JSON: %w", err)
 		}
 		result.Route = &v
+	case "dns":
+		var v networkTesting.DNSTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DNS JSON: %w", err)
+		}
+		result.DNS = &v
+	case "tcp":
+		var v networkTesting.TCPTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal TCP JSON: %w", err)
+		}
+		result.TCP = &v
+	case "http":
+		var v networkTesting.HTTPTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal HTTP JSON: %w", err)
+		}
+		result.HTTP = &v
+	case "tls":
+		var v networkTesting.TLSTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal TLS JSON: %w", err)
+		}
+		result.TLS = &v
+	case "mtu":
+		var v networkTesting.MTUTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal MTU JSON: %w", err)
+		}
+		result.MTU = &v
+	case "loaded-latency":
+		var v networkTesting.LoadedLatencyTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal loaded latency JSON: %w", err)
+		}
+		result.LoadedLatency = &v
+	case "udp":
+		var v networkTesting.UDPTestResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal UDP JSON: %w", err)
+		}
+		result.UDP = &v
 	default:
 		return nil, fmt.Errorf("unsupported test type: %s", testType)
 	}
 	return result, nil
 }
+
+// unmarshalICMPResult accepts both the multi-host format and rows saved
+// before ICMP took a list of targets, which hold a bare ICMPTestResult.
+func unmarshalICMPResult(data []byte) (*networkTesting.MultiHostICMPResult, error) {
+	var probe struct {
+		Hosts json.RawMessage
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	if probe.Hosts != nil {
+		var v networkTesting.MultiHostICMPResult
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return &v, nil
+	}
+
+	var legacy networkTesting.ICMPTestResult
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	return &networkTesting.MultiHostICMPResult{
+		Timestamp: legacy.Timestamp,
+		Hosts:     []*networkTesting.ICMPTestResult{&legacy},
+	}, nil
+}
@@ -40,7 +40,7 @@ func TestGetTestData(t *testing.T) {
 			date:     today,
 			testType: "icmp",
 			validateRes: func(res *networkTesting.TestResult) bool {
-				return res != nil && res.ICMP != nil && res.ICMP.AvgRTT == 20
+				return res != nil && res.ICMP != nil && len(res.ICMP.Hosts) == 1 && res.ICMP.Hosts[0].AvgRTT == 20
 			},
 		},
 		{
@@ -141,3 +141,72 @@ func TestMapTestsByTimestamp(t *testing.T) {
 		assert.Contains(t, rec.ChartPaths["distribution"], "/charts/view?id=")
 	}
 }
+
+func TestUnmarshalICMPResult(t *testing.T) {
+	t.Run("legacy single host", func(t *testing.T) {
+		data := []byte(`{"Host":"8.8.8.8","Timestamp":"2024-01-02T03:04:05Z","Sent":4,"Received":3,"Lost":1,"AvgRTT":20}`)
+
+		res, err := unmarshalTestResult(data, "icmp")
+		require.NoError(t, err)
+		require.NotNil(t, res.ICMP)
+		require.Len(t, res.ICMP.Hosts, 1)
+		assert.Equal(t, "8.8.8.8", res.ICMP.Hosts[0].Host)
+		assert.Equal(t, 3, res.ICMP.Hosts[0].Received)
+		assert.Equal(t, res.ICMP.Hosts[0].Timestamp, res.ICMP.Timestamp)
+	})
+
+	t.Run("multi host", func(t *testing.T) {
+		data := []byte(`{"Timestamp":"2024-01-02T03:04:05Z","Hosts":[{"Host":"8.8.8.8","Received":4},{"Host":"1.1.1.1","Error":"timeout"}]}`)
+
+		res, err := unmarshalTestResult(data, "icmp")
+		require.NoError(t, err)
+		require.Len(t, res.ICMP.Hosts, 2)
+		assert.Equal(t, "1.1.1.1", res.ICMP.Hosts[1].Host)
+		assert.Equal(t, "timeout", res.ICMP.Hosts[1].Error)
+	})
+}
+
+func TestGetTaskRuns(t *testing.T) {
+	repo := newTestRepo(t)
+
+	resultID, err := repo.SaveTestResult(&networkTesting.ICMPTestResult{AvgRTT: 20}, "icmp")
+	require.NoError(t, err)
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 250*int(time.Millisecond), time.UTC)
+	runs := []*TaskRun{
+		{TaskID: "1", TaskName: "ping", StartedAt: start, EndedAt: start.Add(2 * time.Second), Status: "SUCCESS", ResultID: resultID},
+		{TaskID: "1", TaskName: "ping", StartedAt: start.Add(time.Hour), EndedAt: start.Add(time.Hour + time.Second), Attempt: 2, Status: "FAILED", Error: "test execution failed: boom"},
+		{TaskID: "2", TaskName: "charts", StartedAt: start, EndedAt: start, Status: "SUCCESS", Charts: "/charts/view?id=3 /charts/view?id=4"},
+	}
+	for _, run := range runs {
+		id, err := repo.SaveTaskRun(run)
+		require.NoError(t, err)
+		assert.Equal(t, id, run.ID)
+	}
+
+	got, err := repo.GetTaskRuns("1", 0)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "FAILED", got[0].Status, "newest run first")
+	assert.Equal(t, "test execution failed: boom", got[0].Error)
+	assert.Zero(t, got[0].ResultID)
+	assert.Equal(t, 2, got[0].Attempt)
+	assert.Equal(t, resultID, got[1].ResultID)
+	assert.Equal(t, 1, got[1].Attempt, "attempt defaults to the first")
+	assert.True(t, start.Equal(got[1].StartedAt), "start time kept to the millisecond, got %s", got[1].StartedAt)
+	assert.True(t, start.Add(2*time.Second).Equal(got[1].EndedAt))
+
+	got, err = repo.GetTaskRuns("1", 1)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "FAILED", got[0].Status)
+
+	got, err = repo.GetTaskRuns("2", 10)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "/charts/view?id=3 /charts/view?id=4", got[0].Charts)
+
+	got, err = repo.GetTaskRuns("missing", 10)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
@@ -70,3 +70,33 @@ func (r *Repository) SaveChart(chart render.Renderer, testType, chartType string
 	log.Printf("Chart saved (id=%d, type=%s/%s)", id, testType, chartType)
 	return path, nil
 }
+
+// SaveTaskRun records one run of a scheduled task, whatever its outcome.
+func (r *Repository) SaveTaskRun(run *TaskRun) (int64, error) {
+	var rid, errText, charts interface{}
+	if run.ResultID > 0 {
+		rid = run.ResultID
+	}
+	if run.Error != "" {
+		errText = run.Error
+	}
+	if run.Charts != "" {
+		charts = run.Charts
+	}
+
+	res, err := r.db.Exec(
+		`INSERT INTO task_runs (task_id, task_name, started_at, ended_at, attempt, status, error, result_id, charts) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.TaskID, run.TaskName, run.StartedAt.UTC().Format(taskRunTimeFormat), run.EndedAt.UTC().Format(taskRunTimeFormat),
+		max(run.Attempt, 1), run.Status, errText, rid, charts,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save task run: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	run.ID = id
+	return id, nil
+}
@@ -0,0 +1,96 @@
+package networkTesting
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// asnRange is one row of an IP-to-ASN table: every address from start to end
+// inclusive is announced by asn.
+type asnRange struct {
+	start netip.Addr
+	end   netip.Addr
+	asn   uint32
+	name  string
+}
+
+// asnTable answers offline IP-to-ASN lookups from a file in the iptoasn.com
+// TSV layout (range_start, range_end, AS_number, country_code,
+// AS_description), which covers IPv4 and IPv6 in one file.
+type asnTable struct {
+	ranges []asnRange // sorted by start, non-overlapping
+}
+
+func loadASNTable(path string) (*asnTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ASN file: %w", err)
+	}
+	defer f.Close()
+
+	table := &asnTable{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("ASN file line %d: expected at least 3 tab-separated fields", line)
+		}
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("ASN file line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("ASN file line %d: %w", line, err)
+		}
+		asn, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ASN file line %d: invalid AS number %q", line, fields[2])
+		}
+		// AS 0 marks unrouted space; leaving it out makes those lookups miss.
+		if asn == 0 {
+			continue
+		}
+		r := asnRange{start: start.Unmap(), end: end.Unmap(), asn: uint32(asn)}
+		if len(fields) >= 5 {
+			r.name = fields[4]
+		}
+		table.ranges = append(table.ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ASN file: %w", err)
+	}
+
+	sort.Slice(table.ranges, func(i, j int) bool {
+		return table.ranges[i].start.Less(table.ranges[j].start)
+	})
+	return table, nil
+}
+
+// lookup finds the range containing ip. IPv4 and IPv6 ranges sort apart, so
+// a v4 address can never match a v6 row.
+func (t *asnTable) lookup(ip netip.Addr) (asnRange, bool) {
+	ip = ip.Unmap()
+	i := sort.Search(len(t.ranges), func(i int) bool {
+		return ip.Less(t.ranges[i].start)
+	})
+	if i == 0 {
+		return asnRange{}, false
+	}
+	r := t.ranges[i-1]
+	if r.start.BitLen() != ip.BitLen() || r.end.Less(ip) {
+		return asnRange{}, false
+	}
+	return r, true
+}
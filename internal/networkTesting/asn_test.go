@@ -0,0 +1,68 @@
+package networkTesting
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeASNFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ip2asn.tsv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestASNTableLookup(t *testing.T) {
+	path := writeASNFile(t, "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n"+
+		"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n"+
+		"10.0.0.0\t10.255.255.255\t0\tNone\tNot routed\n"+
+		"2001:4860::\t2001:4860:ffff:ffff:ffff:ffff:ffff:ffff\t15169\tUS\tGOOGLE\n")
+
+	table, err := loadASNTable(path)
+	if err != nil {
+		t.Fatalf("loadASNTable returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		asn  uint32
+		name string
+		ok   bool
+	}{
+		{"8.8.8.8", 15169, "GOOGLE", true},
+		{"1.0.0.1", 13335, "CLOUDFLARENET", true},
+		{"::ffff:8.8.4.0", 0, "", false},
+		{"::ffff:8.8.8.1", 15169, "GOOGLE", true},
+		{"2001:4860:4860::8888", 15169, "GOOGLE", true},
+		{"10.1.2.3", 0, "", false},
+		{"9.9.9.9", 0, "", false},
+		{"0.0.0.1", 0, "", false},
+		{"2606:4700::1111", 0, "", false},
+	}
+	for _, tt := range tests {
+		r, ok := table.lookup(netip.MustParseAddr(tt.ip))
+		if ok != tt.ok || r.asn != tt.asn || r.name != tt.name {
+			t.Errorf("lookup(%s) = AS%d %q %v, want AS%d %q %v", tt.ip, r.asn, r.name, ok, tt.asn, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestLoadASNTableRejectsBadRows(t *testing.T) {
+	for _, content := range []string{
+		"1.0.0.0\t1.0.0.255\n",
+		"not-an-ip\t1.0.0.255\t13335\n",
+		"1.0.0.0\t1.0.0.255\tAS13335\n",
+	} {
+		if _, err := loadASNTable(writeASNFile(t, content)); err == nil {
+			t.Errorf("expected error loading %q", content)
+		}
+	}
+
+	if _, err := loadASNTable(filepath.Join(t.TempDir(), "missing.tsv")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
@@ -5,39 +5,55 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// bandwidthSampleInterval is how often each step samples the aggregate and
+// per-connection throughput.
+const bandwidthSampleInterval = time.Second
+
 type BandwidthTestResult struct {
 	StartTime     time.Time
 	EndTime       time.Time
 	Steps         []ConnectionStep
 	OptimalConns  int
-	MaxThroughput float64
+	MaxThroughput float64 // Best aggregate Mbps across the steps
 	FailurePoint  int
 	TotalData     int64
+	Status        string `json:",omitempty"` // Only set when the run was cancelled
 }
 
+// ConnectionStep is one connection count held for a fixed duration.
+// AggregateMbps is the link capacity the connections reached together after
+// the warm-up, AvgSpeed the share each got on average, and Fairness Jain's
+// index of those shares.
 type ConnectionStep struct {
-	Connections int
-	ConnResults []ConnectionResult
-	TotalBytes  int64
-	AvgSpeed    float64
-	Duration    time.Duration `json:"duration,string"`
-	Failed      bool
+	Connections   int
+	ConnResults   []ConnectionResult
+	TotalBytes    int64
+	AvgSpeed      float64
+	AggregateMbps float64       `json:",omitempty"`
+	Fairness      float64       `json:",omitempty"`
+	WarmupSamples int           `json:",omitempty"`
+	Samples       []float64     `json:",omitempty"` // Aggregate Mbps each second
+	Duration      time.Duration `json:"duration,string"`
+	Failed        bool
 }
 
 type ConnectionResult struct {
 	ID        int
 	BytesRecv int64
 	Duration  time.Duration
-	Speed     float64 // Store individual connection speed in Mbps
-	Error     error
+	Speed     float64   // Store individual connection speed in Mbps
+	Samples   []float64 `json:",omitempty"`
+	Error     string    `json:",omitempty"`
 }
 
-func (t *NetworkTester) RunBandwidthTest() (*BandwidthTestResult, error) {
+// RunBandwidthTest steps up the connection count until the aggregate drops
+// off or MaxConnections is reached. A step cut short by ctx is kept, but
+// doesn't count towards the peak.
+func (t *NetworkTester) RunBandwidthTest(ctx context.Context) (*BandwidthTestResult, error) {
 	fmt.Printf("Starting bandwidth test\n")
 	result := &BandwidthTestResult{
 		StartTime: time.Now(),
@@ -48,170 +64,214 @@ func (t *NetworkTester) RunBandwidthTest() (*BandwidthTestResult, error) {
 	if testURL == "" {
 		return nil, fmt.Errorf("no download URL configured")
 	}
+	duration, warmup := t.bandwidthStepSettings()
 
-	for users := t.config.Tests.Bandwidth.InitialConnections; users <= t.config.Tests.Bandwidth.MaxConnections; users += t.config.Tests.Bandwidth.StepSize {
-		totalTestMB := users * 100 //100MB per user
-
-		fmt.Printf("\nTesting bandwidth with %d concurrent users (%dMB total)\n", users, totalTestMB)
-		step := t.runStep(users, testURL)
+	for conns := t.config.Tests.Bandwidth.InitialConnections; conns <= t.config.Tests.Bandwidth.MaxConnections; conns += max(t.config.Tests.Bandwidth.StepSize, 1) {
+		fmt.Printf("\nTesting bandwidth with %d concurrent connections for %s\n", conns, formatDuration(duration))
+		step := t.runStep(ctx, conns, testURL, duration, warmup)
+		if ctx.Err() != nil {
+			if len(step.Samples) > 0 {
+				result.Steps = append(result.Steps, step)
+				result.TotalData += step.TotalBytes
+			}
+			break
+		}
 		result.Steps = append(result.Steps, step)
 		result.TotalData += step.TotalBytes
 
-		if step.AvgSpeed > result.MaxThroughput {
-			result.MaxThroughput = step.AvgSpeed
-			result.OptimalConns = users
-			fmt.Printf("New max user bandwidth: %.2f Mbps (%.2f MB/s) with %d users\n",
-				step.AvgSpeed,
-				step.AvgSpeed/8,
-				users)
+		if step.AggregateMbps > result.MaxThroughput {
+			result.MaxThroughput = step.AggregateMbps
+			result.OptimalConns = conns
+			fmt.Printf("New max aggregate bandwidth: %.2f Mbps (%.2f MB/s) with %d connections\n",
+				step.AggregateMbps,
+				step.AggregateMbps/8,
+				conns)
 		}
 
 		if t.shouldStopTest(step, result) {
-			result.FailurePoint = users
-			fmt.Printf("Bandwidth degradation detected at %d users (%dMB)\n", users, totalTestMB)
+			result.FailurePoint = conns
+			fmt.Printf("Bandwidth degradation detected at %d connections\n", conns)
 			break
 		}
 	}
 
 	result.EndTime = time.Now()
 	fmt.Printf("\nBandwidth test completed in %s\n", result.EndTime.Sub(result.StartTime).Round(time.Second))
-	fmt.Printf("Optimal user count: %d (%dMB)\n",
-		result.OptimalConns,
-		result.OptimalConns*100)
-	fmt.Printf("Maximum user bandwidth: %.2f Mbps (%.2f MB/s)\n",
+	fmt.Printf("Optimal connection count: %d\n", result.OptimalConns)
+	fmt.Printf("Maximum aggregate bandwidth: %.2f Mbps (%.2f MB/s)\n",
 		result.MaxThroughput,
 		result.MaxThroughput/8)
 	return result, nil
 }
 
-func (t *NetworkTester) runStep(users int, url string) ConnectionStep {
-	stepStart := time.Now()
+// runStep keeps conns downloads of url running for duration and samples
+// every connection once a second.
+func (t *NetworkTester) runStep(ctx context.Context, conns int, url string, duration, warmup time.Duration) ConnectionStep {
+	transfer := runTimedTransfer(ctx, conns, duration, bandwidthSampleInterval,
+		func(ctx context.Context, _ int, counted *atomic.Int64) error {
+			return t.downloadCounting(ctx, url, counted)
+		})
+
+	step := bandwidthStep(conns, transfer, warmup)
+	for _, conn := range step.ConnResults {
+		if conn.Error != "" {
+			fmt.Printf("Connection %d error: %s\n", conn.ID, conn.Error)
+			continue
+		}
+		fmt.Printf("Connection %d bandwidth: %.2f Mbps (%s)\n", conn.ID, conn.Speed, formatBytes(conn.BytesRecv))
+	}
+	fmt.Printf("\nStep complete: %.2f Mbps aggregate, %.2f Mbps per connection, fairness %.2f\n",
+		step.AggregateMbps,
+		step.AvgSpeed,
+		step.Fairness)
+
+	return step
+}
+
+// bandwidthStep works out a step's rates from its samples. Only samples
+// after the warm-up count towards the rates, but at least one always does.
+func bandwidthStep(conns int, transfer timedTransfer, warmup time.Duration) ConnectionStep {
 	step := ConnectionStep{
-		Connections: users,
-		ConnResults: make([]ConnectionResult, 0),
-	}
-
-	var wg sync.WaitGroup
-	results := make(chan ConnectionResult, users)
-
-	for i := 0; i < users; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			connStart := time.Now()
-			downloaded, err := t.downloadWithProgress(url)
-			duration := time.Since(connStart)
-			speed := calculateMbps(downloaded, duration)
-
-			results <- ConnectionResult{
-				ID:        id,
-				BytesRecv: downloaded,
-				Duration:  duration,
-				Speed:     speed,
-				Error:     err,
+		Connections:   conns,
+		ConnResults:   make([]ConnectionResult, conns),
+		WarmupSamples: max(min(int(warmup/bandwidthSampleInterval), len(transfer.samples)-1), 0),
+		Samples:       make([]float64, len(transfer.samples)),
+	}
+
+	steadyBytes := make([]int64, conns)
+	var steadyTime time.Duration
+	for i, sample := range transfer.samples {
+		step.Samples[i] = megabitsPerSecond(sample.bytes, sample.elapsed)
+		step.TotalBytes += sample.bytes
+		step.Duration += sample.elapsed
+		if i >= step.WarmupSamples {
+			steadyTime += sample.elapsed
+		}
+		for c, bytes := range sample.streamBytes {
+			conn := &step.ConnResults[c]
+			conn.BytesRecv += bytes
+			conn.Samples = append(conn.Samples, megabitsPerSecond(bytes, sample.elapsed))
+			if i >= step.WarmupSamples {
+				steadyBytes[c] += bytes
 			}
-		}(i)
-	}
-
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	successfulUsers := 0
-	for result := range results {
-		if result.BytesRecv > 0 {
-			step.ConnResults = append(step.ConnResults, result)
-			step.TotalBytes += result.BytesRecv
-			successfulUsers++
-
-			fmt.Printf("User %d bandwidth: %.2f Mbps (duration: %s)\n",
-				result.ID,
-				result.Speed,
-				result.Duration.Round(time.Millisecond))
-		} else if result.Error != nil {
-			fmt.Printf("User %d error: %v\n", result.ID, result.Error)
 		}
 	}
 
-	step.Duration = time.Since(stepStart).Round(time.Millisecond)
-
-	if successfulUsers > 0 {
-		step.AvgSpeed = calculateMbps(step.TotalBytes/int64(successfulUsers), step.Duration)
-		fmt.Printf("\nStep complete: %d/%d users successful\n", successfulUsers, users)
-		fmt.Printf("Average bandwidth per user: %.2f Mbps (%.2f MB/s)\n",
-			step.AvgSpeed,
-			step.AvgSpeed/8)
+	speeds := make([]float64, conns)
+	for c := range step.ConnResults {
+		conn := &step.ConnResults[c]
+		conn.ID = c
+		conn.Duration = step.Duration
+		conn.Speed = megabitsPerSecond(steadyBytes[c], steadyTime)
+		if c < len(transfer.streamErrs) && transfer.streamErrs[c] != nil {
+			conn.Error = transfer.streamErrs[c].Error()
+		}
+		speeds[c] = conn.Speed
+		step.AggregateMbps += conn.Speed
 	}
 
+	step.AvgSpeed = step.AggregateMbps / float64(conns)
+	step.Fairness = jainsIndex(speeds)
+	step.Failed = step.TotalBytes == 0
 	return step
 }
+
+// jainsIndex is Jain's fairness index, (Σx)² / (n·Σx²): 1 when every
+// connection gets the same share, down to 1/n when one takes it all.
+func jainsIndex(shares []float64) float64 {
+	var sum, sumSquares float64
+	for _, share := range shares {
+		sum += share
+		sumSquares += share * share
+	}
+	if sumSquares == 0 {
+		return 0
+	}
+	return sum * sum / (float64(len(shares)) * sumSquares)
+}
+
+// bandwidthStepSettings falls back to the defaults for a step length saved
+// as zero from the settings page.
+func (t *NetworkTester) bandwidthStepSettings() (duration, warmup time.Duration) {
+	cfg := t.config.Tests.Bandwidth
+	duration = time.Duration(cfg.StepSeconds) * time.Second
+	if duration <= 0 {
+		duration = 10 * time.Second
+	}
+	return duration, time.Duration(cfg.WarmupSeconds) * time.Second
+}
+
+// shouldStopTest compares the step's aggregate against the best so far. A
+// step where every connection failed counts as a full drop.
 func (t *NetworkTester) shouldStopTest(step ConnectionStep, result *BandwidthTestResult) bool {
 	if len(result.Steps) <= 1 {
 		return false
 	}
 
 	//compare against the maximum throughput
-	if result.MaxThroughput == 0 || step.AvgSpeed == 0 {
+	if result.MaxThroughput == 0 {
 		return false
 	}
 
-	dropPct := (result.MaxThroughput - step.AvgSpeed) / result.MaxThroughput * 100
+	dropPct := (result.MaxThroughput - step.AggregateMbps) / result.MaxThroughput * 100
 	fmt.Printf("Bandwidth change: %.1f%% from peak\n", -dropPct)
 
 	fmt.Printf("MaxThroughput: %.2f, Current: %.2f, Drop%%: %.1f, Threshold: %.1f, Will Stop: %v\n",
 		result.MaxThroughput,
-		step.AvgSpeed,
+		step.AggregateMbps,
 		dropPct,
-		t.config.Tests.Bandwidth.FailThreshold,
-		dropPct >= t.config.Tests.Bandwidth.FailThreshold)
+		t.config.Tests.Bandwidth.DropThreshold,
+		dropPct >= t.config.Tests.Bandwidth.DropThreshold)
+
+	return dropPct >= t.config.Tests.Bandwidth.DropThreshold
+}
 
-	return dropPct >= t.config.Tests.Bandwidth.FailThreshold
+// downloadWithProgress fetches url to the end, or until ctx is done, and
+// returns how many bytes arrived either way.
+func (t *NetworkTester) downloadWithProgress(ctx context.Context, url string) (int64, error) {
+	var received atomic.Int64
+	err := t.downloadCounting(ctx, url, &received)
+	return received.Load(), err
 }
 
-func (t *NetworkTester) downloadWithProgress(url string) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+// downloadCounting GETs url and discards the body, adding to received as
+// each read lands so a sampler can watch the transfer in flight.
+func (t *NetworkTester) downloadCounting(ctx context.Context, url string, received *atomic.Int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	client, req, err := t.setupClient(url, nil, "GET")
 	if err != nil {
-		return 0, fmt.Errorf("failed to setup request: %v", err)
+		return fmt.Errorf("failed to setup request: %v", err)
 	}
 
 	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to execute request: %v", err)
+		return fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	var received int64
 	buf := make([]byte, 256*1024)
 
 	for {
 		if ctx.Err() != nil {
-			return received, ctx.Err()
+			return ctx.Err()
 		}
 
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
-			atomic.AddInt64(&received, int64(n))
+			received.Add(int64(n))
 		}
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return received, err
+			return err
 		}
 	}
-
-	return received, nil
-}
-
-func calculateMbps(bytes int64, duration time.Duration) float64 {
-	return float64(bytes*8) / (1024 * 1024) / duration.Seconds()
 }
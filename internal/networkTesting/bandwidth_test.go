@@ -1,6 +1,9 @@
 package networkTesting
 
 import (
+	"context"
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -15,13 +18,13 @@ func TestBandwidthTest(t *testing.T) {
 				MaxConnections:     4,
 				StepSize:           1,
 				DownloadURL:        "http://ipv4.download.thinkbroadband.com/10MB.zip",
-				FailThreshold:      50,
+				DropThreshold:      50,
 			},
 		},
 	}
 	tester := NewNetworkTester(cfg)
 
-	result, err := tester.RunBandwidthTest()
+	result, err := tester.RunBandwidthTest(context.Background())
 	if err != nil {
 		t.Fatalf("RunBandwidthTest returned error: %v", err)
 	}
@@ -66,7 +69,7 @@ func TestBandwidthTest(t *testing.T) {
 			// Check for reasonable bandwidth degradation
 			if i > 0 && !step.Failed {
 				dropPct := (lastSpeed - step.AvgSpeed) / lastSpeed * 100
-				if dropPct > float64(cfg.Tests.Bandwidth.FailThreshold*2) {
+				if dropPct > float64(cfg.Tests.Bandwidth.DropThreshold*2) {
 					t.Errorf("Excessive bandwidth drop: %.2f%%", dropPct)
 				}
 			}
@@ -91,3 +94,103 @@ func TestBandwidthTest(t *testing.T) {
 		}
 	}
 }
+
+func TestJainsIndex(t *testing.T) {
+	tests := []struct {
+		shares []float64
+		want   float64
+	}{
+		{[]float64{10, 10, 10, 10}, 1},
+		{[]float64{40, 0, 0, 0}, 0.25},
+		{[]float64{30, 10}, 0.8},
+		{[]float64{0, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := jainsIndex(tt.shares); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("jainsIndex(%v) = %v, want %v", tt.shares, got, tt.want)
+		}
+	}
+}
+
+func TestBandwidthStep(t *testing.T) {
+	// Two connections: a one-second warm-up, then one gets 75 Mbps and the
+	// other 25 Mbps for two seconds. The third connection never connected.
+	transfer := timedTransfer{streams: 3, streamErrs: []error{nil, nil, errors.New("connection refused")}}
+	for _, perConn := range [][]int64{{125_000, 125_000, 0}, {9_375_000, 3_125_000, 0}, {9_375_000, 3_125_000, 0}} {
+		transfer.samples = append(transfer.samples, transferSample{
+			bytes:       perConn[0] + perConn[1],
+			streamBytes: perConn,
+			elapsed:     time.Second,
+		})
+	}
+
+	step := bandwidthStep(3, transfer, time.Second)
+
+	if math.Abs(step.AggregateMbps-100) > 1e-9 {
+		t.Errorf("AggregateMbps = %v, want 100 with the warm-up left out", step.AggregateMbps)
+	}
+	if math.Abs(step.AvgSpeed-100.0/3) > 1e-9 {
+		t.Errorf("AvgSpeed = %v, want the aggregate shared over 3 connections", step.AvgSpeed)
+	}
+	if want := 100.0 * 100 / (3 * (75*75 + 25*25)); math.Abs(step.Fairness-want) > 1e-9 {
+		t.Errorf("Fairness = %v, want %v", step.Fairness, want)
+	}
+	if step.WarmupSamples != 1 || len(step.Samples) != 3 || step.Samples[0] != 2 {
+		t.Errorf("got %d warm-up samples of %v, want 1 of 3 starting at 2 Mbps", step.WarmupSamples, step.Samples)
+	}
+	if step.TotalBytes != 25_250_000 || step.Duration != 3*time.Second {
+		t.Errorf("TotalBytes = %d over %v, want every sample counted", step.TotalBytes, step.Duration)
+	}
+	if conn := step.ConnResults[0]; conn.Speed != 75 || len(conn.Samples) != 3 || conn.BytesRecv != 18_875_000 {
+		t.Errorf("connection 0 = %.2f Mbps, %d samples, %d bytes", conn.Speed, len(conn.Samples), conn.BytesRecv)
+	}
+	if conn := step.ConnResults[2]; conn.Error != "connection refused" || conn.Speed != 0 {
+		t.Errorf("connection 2 = %.2f Mbps, error %q, want the failure recorded", conn.Speed, conn.Error)
+	}
+
+	// A warm-up as long as the step still leaves the last sample to measure.
+	if step := bandwidthStep(3, transfer, 10*time.Second); step.WarmupSamples != 2 || step.AggregateMbps != 100 {
+		t.Errorf("got %d warm-up samples and %.2f Mbps, want the last sample kept", step.WarmupSamples, step.AggregateMbps)
+	}
+}
+
+func TestRunBandwidthTestFixedDuration(t *testing.T) {
+	server := newSpeedTestServer(t, DefaultSpeedTestMaxBytes)
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			Bandwidth: config.BandwidthConfig{
+				InitialConnections: 1,
+				MaxConnections:     3,
+				StepSize:           2,
+				StepSeconds:        1,
+				DropThreshold:      100,
+				DownloadURL:        server.URL + "/speedtest/download?size=100MB",
+			},
+		},
+	})
+
+	start := time.Now()
+	result, err := tester.RunBandwidthTest(context.Background())
+	if err != nil {
+		t.Fatalf("RunBandwidthTest returned error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("took %v, want two 1s steps rather than whole 100MB downloads", elapsed)
+	}
+	if len(result.Steps) != 2 {
+		t.Fatalf("ran %d steps, want 2", len(result.Steps))
+	}
+	for _, step := range result.Steps {
+		if step.AggregateMbps <= 0 || step.Fairness <= 0 || len(step.ConnResults) != step.Connections {
+			t.Errorf("step with %d connections: %.2f Mbps, fairness %.2f, %d results",
+				step.Connections, step.AggregateMbps, step.Fairness, len(step.ConnResults))
+		}
+		if len(step.Samples) == 0 || step.Samples[0] <= 0 {
+			t.Errorf("step with %d connections took samples %v, want once a second", step.Connections, step.Samples)
+		}
+	}
+	if result.MaxThroughput <= 0 || result.OptimalConns == 0 {
+		t.Errorf("MaxThroughput = %.2f at %d connections, want the best aggregate", result.MaxThroughput, result.OptimalConns)
+	}
+}
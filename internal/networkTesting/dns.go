@@ -0,0 +1,268 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const systemResolver = "system"
+
+type DNSTestResult struct {
+	Timestamp time.Time            `json:"timestamp"`
+	QueryType string               `json:"query_type"`
+	Queries   []DNSQueryResult     `json:"queries"`
+	Resolvers []DNSResolverSummary `json:"resolvers"`
+	Status    string               `json:"status"`
+	Error     string               `json:"error,omitempty"`
+}
+
+type DNSQueryResult struct {
+	Resolver string        `json:"resolver"`
+	Hostname string        `json:"hostname"`
+	Latency  time.Duration `json:"latency"`
+	Rcode    string        `json:"rcode,omitempty"`
+	Answers  int           `json:"answers"`
+	Failed   bool          `json:"failed"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type DNSResolverSummary struct {
+	Resolver   string        `json:"resolver"`
+	Queries    int           `json:"queries"`
+	Failures   int           `json:"failures"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	MaxLatency time.Duration `json:"max_latency"`
+}
+
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+func (t *NetworkTester) RunDNSTest(ctx context.Context) (*DNSTestResult, error) {
+	cfg := t.config.Tests.DNS
+	if len(cfg.Hostnames) == 0 {
+		return nil, fmt.Errorf("no DNS hostnames configured")
+	}
+	if len(cfg.Resolvers) == 0 {
+		return nil, fmt.Errorf("no DNS resolvers configured")
+	}
+
+	qtype, err := parseDNSQueryType(cfg.QueryType)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+
+	result := &DNSTestResult{
+		Timestamp: time.Now(),
+		QueryType: strings.TrimPrefix(qtype.String(), "Type"),
+		Queries:   make([]DNSQueryResult, 0, len(cfg.Hostnames)*len(cfg.Resolvers)),
+	}
+
+	failures := 0
+	for _, resolver := range cfg.Resolvers {
+		if ctx.Err() != nil {
+			break
+		}
+		summary := DNSResolverSummary{Resolver: resolver}
+		var totalLatency time.Duration
+
+		for _, hostname := range cfg.Hostnames {
+			var query DNSQueryResult
+			if resolver == systemResolver {
+				query = querySystemResolver(ctx, hostname, qtype, timeout)
+			} else {
+				query = queryDNSServer(ctx, resolver, hostname, qtype, timeout)
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			result.Queries = append(result.Queries, query)
+
+			summary.Queries++
+			if query.Failed {
+				summary.Failures++
+				failures++
+				continue
+			}
+			totalLatency += query.Latency
+			if query.Latency > summary.MaxLatency {
+				summary.MaxLatency = query.Latency
+			}
+		}
+
+		if answered := summary.Queries - summary.Failures; answered > 0 {
+			summary.AvgLatency = totalLatency / time.Duration(answered)
+		}
+		result.Resolvers = append(result.Resolvers, summary)
+	}
+
+	switch {
+	case failures == len(result.Queries):
+		result.Status = "FAILED"
+		result.Error = "all DNS queries failed"
+		return result, errors.New(result.Error)
+	case failures > 0:
+		result.Status = "PARTIAL"
+	default:
+		result.Status = "SUCCESS"
+	}
+
+	return result, nil
+}
+
+func parseDNSQueryType(queryType string) (dnsmessage.Type, error) {
+	switch strings.ToUpper(queryType) {
+	case "", "A":
+		return dnsmessage.TypeA, nil
+	case "AAAA":
+		return dnsmessage.TypeAAAA, nil
+	default:
+		return 0, fmt.Errorf("unsupported DNS query type: %s", queryType)
+	}
+}
+
+// querySystemResolver goes through the OS resolver, so there's no raw
+// response to read an rcode from — it's inferred from the lookup error.
+func querySystemResolver(ctx context.Context, hostname string, qtype dnsmessage.Type, timeout time.Duration) DNSQueryResult {
+	query := DNSQueryResult{Resolver: systemResolver, Hostname: hostname}
+
+	network := "ip4"
+	if qtype == dnsmessage.TypeAAAA {
+		network = "ip6"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, hostname)
+	query.Latency = time.Since(start)
+
+	if err != nil {
+		query.Failed = true
+		query.Error = err.Error()
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			switch {
+			case dnsErr.IsNotFound:
+				query.Rcode = "NXDOMAIN"
+			case !dnsErr.IsTimeout:
+				query.Rcode = "SERVFAIL"
+			}
+		}
+		return query
+	}
+
+	query.Rcode = "NOERROR"
+	query.Answers = len(ips)
+	return query
+}
+
+func queryDNSServer(ctx context.Context, server, hostname string, qtype dnsmessage.Type, timeout time.Duration) DNSQueryResult {
+	query := DNSQueryResult{Resolver: server, Hostname: hostname}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	name, err := dnsmessage.NewName(toFQDN(hostname))
+	if err != nil {
+		query.Failed = true
+		query.Error = fmt.Sprintf("invalid hostname: %v", err)
+		return query
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		query.Failed = true
+		query.Error = fmt.Sprintf("failed to pack DNS query: %v", err)
+		return query
+	}
+
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "udp", server)
+	if err != nil {
+		query.Failed = true
+		query.Error = fmt.Sprintf("failed to dial resolver: %v", err)
+		return query
+	}
+	defer conn.Close()
+	// Closing the socket unblocks the read below if ctx ends first.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		query.Failed = true
+		query.Error = fmt.Sprintf("failed to set deadline: %v", err)
+		return query
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		query.Failed = true
+		query.Error = fmt.Sprintf("failed to send DNS query: %v", err)
+		return query
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			query.Latency = time.Since(start)
+			query.Failed = true
+			query.Error = fmt.Sprintf("failed to read DNS response: %v", err)
+			return query
+		}
+
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil || resp.ID != id || !resp.Response {
+			// Stray or malformed datagram — keep waiting for our answer
+			// until the deadline.
+			continue
+		}
+
+		query.Latency = time.Since(start)
+		query.Rcode = rcodeName(resp.RCode)
+		query.Answers = len(resp.Answers)
+		if resp.RCode != dnsmessage.RCodeSuccess {
+			query.Failed = true
+			query.Error = fmt.Sprintf("resolver returned %s", query.Rcode)
+		}
+		return query
+	}
+}
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return rcode.String()
+}
+
+func toFQDN(hostname string) string {
+	if strings.HasSuffix(hostname, ".") {
+		return hostname
+	}
+	return hostname + "."
+}
@@ -0,0 +1,170 @@
+package networkTesting
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startDNSStub runs a minimal UDP DNS server on localhost. Names in records
+// get a single A answer, names in rcodes get an empty response with that
+// rcode, and anything else is silently dropped so the client times out.
+func startDNSStub(t *testing.T, records map[string]bool, rcodes map[string]dnsmessage.RCode) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start DNS stub: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var req dnsmessage.Message
+			if err := req.Unpack(buf[:n]); err != nil || len(req.Questions) == 0 {
+				continue
+			}
+			q := req.Questions[0]
+			name := q.Name.String()
+
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, RecursionAvailable: true},
+				Questions: req.Questions,
+			}
+			switch {
+			case records[name]:
+				resp.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+				}}
+			case rcodes[name] != 0:
+				resp.RCode = rcodes[name]
+			default:
+				continue
+			}
+
+			packed, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestQueryDNSServer(t *testing.T) {
+	server := startDNSStub(t,
+		map[string]bool{"ok.test.": true},
+		map[string]dnsmessage.RCode{"missing.test.": dnsmessage.RCodeNameError},
+	)
+
+	tests := []struct {
+		name        string
+		hostname    string
+		wantFailed  bool
+		wantRcode   string
+		wantAnswers int
+	}{
+		{name: "resolves", hostname: "ok.test", wantRcode: "NOERROR", wantAnswers: 1},
+		{name: "nxdomain", hostname: "missing.test", wantFailed: true, wantRcode: "NXDOMAIN"},
+		{name: "timeout", hostname: "silent.test", wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := queryDNSServer(context.Background(), server, tt.hostname, dnsmessage.TypeA, 300*time.Millisecond)
+
+			if query.Failed != tt.wantFailed {
+				t.Errorf("Failed = %v, want %v (error: %s)", query.Failed, tt.wantFailed, query.Error)
+			}
+			if query.Rcode != tt.wantRcode {
+				t.Errorf("Rcode = %q, want %q", query.Rcode, tt.wantRcode)
+			}
+			if query.Answers != tt.wantAnswers {
+				t.Errorf("Answers = %d, want %d", query.Answers, tt.wantAnswers)
+			}
+			if !query.Failed && query.Latency <= 0 {
+				t.Errorf("Expected latency > 0, got %v", query.Latency)
+			}
+		})
+	}
+}
+
+func TestRunDNSTest(t *testing.T) {
+	server := startDNSStub(t,
+		map[string]bool{"ok.test.": true},
+		map[string]dnsmessage.RCode{"broken.test.": dnsmessage.RCodeServerFailure},
+	)
+
+	cfg := &config.Config{
+		Tests: config.TestConfigs{
+			DNS: config.DNSConfig{
+				Hostnames:      []string{"ok.test", "broken.test"},
+				Resolvers:      []string{server},
+				TimeoutSeconds: 1,
+			},
+		},
+	}
+	tester := NewNetworkTester(cfg)
+
+	result, err := tester.RunDNSTest(context.Background())
+	if err != nil {
+		t.Fatalf("RunDNSTest returned unexpected error: %v", err)
+	}
+
+	if result.Status != "PARTIAL" {
+		t.Errorf("Status = %q, want PARTIAL", result.Status)
+	}
+	if result.QueryType != "A" {
+		t.Errorf("QueryType = %q, want A", result.QueryType)
+	}
+	if len(result.Queries) != 2 {
+		t.Fatalf("Expected 2 queries, got %d", len(result.Queries))
+	}
+	if len(result.Resolvers) != 1 {
+		t.Fatalf("Expected 1 resolver summary, got %d", len(result.Resolvers))
+	}
+
+	summary := result.Resolvers[0]
+	if summary.Queries != 2 || summary.Failures != 1 {
+		t.Errorf("Summary queries/failures = %d/%d, want 2/1", summary.Queries, summary.Failures)
+	}
+	if summary.AvgLatency <= 0 {
+		t.Errorf("Expected average latency > 0, got %v", summary.AvgLatency)
+	}
+}
+
+func TestRunDNSTestAllFailed(t *testing.T) {
+	server := startDNSStub(t, nil, nil)
+
+	cfg := &config.Config{
+		Tests: config.TestConfigs{
+			DNS: config.DNSConfig{
+				Hostnames:      []string{"silent.test"},
+				Resolvers:      []string{server},
+				TimeoutSeconds: 1,
+			},
+		},
+	}
+	tester := NewNetworkTester(cfg)
+
+	result, err := tester.RunDNSTest(context.Background())
+	if err == nil {
+		t.Fatal("Expected error when every query fails")
+	}
+	if result == nil || result.Status != "FAILED" {
+		t.Errorf("Expected FAILED result, got %+v", result)
+	}
+}
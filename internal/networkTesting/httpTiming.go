@@ -0,0 +1,154 @@
+package networkTesting
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+type HTTPTestResult struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Requests  []HTTPPhaseResult `json:"requests"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// HTTPPhaseResult splits a single GET into consecutive phases. TTFB runs
+// from having a connection ready to the first response byte, so it covers
+// writing the request plus server think time; Transfer is the body read.
+// DNS, TCP and TLS are zero when they didn't happen (IP literal, plain
+// HTTP).
+type HTTPPhaseResult struct {
+	URL          string        `json:"url"`
+	StatusCode   int           `json:"status_code"`
+	DNSLookup    time.Duration `json:"dns_lookup"`
+	TCPConnect   time.Duration `json:"tcp_connect"`
+	TLSHandshake time.Duration `json:"tls_handshake"`
+	TTFB         time.Duration `json:"ttfb"`
+	Transfer     time.Duration `json:"transfer"`
+	Total        time.Duration `json:"total"`
+	Bytes        int64         `json:"bytes"`
+	Failed       bool          `json:"failed"`
+	Error        string        `json:"error,omitempty"`
+}
+
+func (t *NetworkTester) RunHTTPTest(ctx context.Context) (*HTTPTestResult, error) {
+	cfg := t.config.Tests.HTTP
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("no HTTP URLs configured")
+	}
+
+	// Keep-alives off so every URL pays for its own DNS, TCP and TLS setup
+	// rather than reusing a connection from a previous request.
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DisableKeepAlives: true,
+		ForceAttemptHTTP2: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	result := &HTTPTestResult{
+		Timestamp: time.Now(),
+		Requests:  make([]HTTPPhaseResult, 0, len(cfg.URLs)),
+	}
+
+	failures := 0
+	for _, url := range cfg.URLs {
+		phases := t.measureHTTPPhases(ctx, transport, url, timeout)
+		if ctx.Err() != nil {
+			break
+		}
+		if phases.Failed {
+			failures++
+		}
+		result.Requests = append(result.Requests, phases)
+	}
+
+	switch {
+	case failures == len(result.Requests):
+		result.Status = "FAILED"
+		result.Error = "all HTTP requests failed"
+		return result, errors.New(result.Error)
+	case failures > 0:
+		result.Status = "PARTIAL"
+	default:
+		result.Status = "SUCCESS"
+	}
+
+	return result, nil
+}
+
+func (t *NetworkTester) measureHTTPPhases(ctx context.Context, transport http.RoundTripper, url string, timeout time.Duration) HTTPPhaseResult {
+	phases := HTTPPhaseResult{URL: url}
+
+	client, req, err := t.setupClient(url, nil, "GET")
+	if err != nil {
+		phases.Failed = true
+		phases.Error = fmt.Sprintf("failed to create request: %v", err)
+		return phases
+	}
+	client.Transport = transport
+	client.Timeout = timeout
+
+	var dnsStart, connectStart, tlsStart, connReady, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			phases.DNSLookup = time.Since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			// Happy Eyeballs can start several dials; time the first.
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			if phases.TCPConnect == 0 {
+				phases.TCPConnect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			phases.TLSHandshake = time.Since(tlsStart)
+		},
+		GotConn:              func(httptrace.GotConnInfo) { connReady = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		phases.Total = time.Since(start)
+		phases.Failed = true
+		phases.Error = err.Error()
+		return phases
+	}
+	defer resp.Body.Close()
+
+	phases.StatusCode = resp.StatusCode
+	phases.Bytes, err = io.Copy(io.Discard, resp.Body)
+	end := time.Now()
+
+	phases.Total = end.Sub(start)
+	if !firstByte.IsZero() {
+		phases.TTFB = firstByte.Sub(connReady)
+		phases.Transfer = end.Sub(firstByte)
+	}
+
+	if err != nil {
+		phases.Failed = true
+		phases.Error = fmt.Sprintf("failed to read response body: %v", err)
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		phases.Failed = true
+		phases.Error = fmt.Sprintf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return phases
+}
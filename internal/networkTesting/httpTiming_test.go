@@ -0,0 +1,89 @@
+package networkTesting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func TestMeasureHTTPPhases(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(strings.Repeat("x", 4096)))
+	}))
+	defer server.Close()
+
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+	tester := NewNetworkTester(&config.Config{})
+
+	t.Run("success", func(t *testing.T) {
+		phases := tester.measureHTTPPhases(context.Background(), transport, server.URL, 5*time.Second)
+
+		if phases.Failed {
+			t.Fatalf("Expected success, got error: %s", phases.Error)
+		}
+		if phases.StatusCode != http.StatusOK {
+			t.Errorf("StatusCode = %d, want 200", phases.StatusCode)
+		}
+		if phases.Bytes != 4096 {
+			t.Errorf("Bytes = %d, want 4096", phases.Bytes)
+		}
+		if phases.TCPConnect <= 0 || phases.TLSHandshake <= 0 {
+			t.Errorf("Expected connect and TLS phases > 0, got %v / %v", phases.TCPConnect, phases.TLSHandshake)
+		}
+		if phases.TTFB < 20*time.Millisecond {
+			t.Errorf("Expected TTFB to include server delay, got %v", phases.TTFB)
+		}
+		if phases.DNSLookup != 0 {
+			t.Errorf("Expected no DNS phase for an IP literal, got %v", phases.DNSLookup)
+		}
+		sum := phases.DNSLookup + phases.TCPConnect + phases.TLSHandshake + phases.TTFB + phases.Transfer
+		if sum > phases.Total {
+			t.Errorf("Phases sum to %v, more than total %v", sum, phases.Total)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		phases := tester.measureHTTPPhases(context.Background(), transport, server.URL+"/missing", 5*time.Second)
+
+		if !phases.Failed {
+			t.Error("Expected 404 to be reported as failed")
+		}
+		if phases.StatusCode != http.StatusNotFound {
+			t.Errorf("StatusCode = %d, want 404", phases.StatusCode)
+		}
+	})
+}
+
+func TestRunHTTPTestAllFailed(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cfg := &config.Config{
+		Tests: config.TestConfigs{
+			HTTP: config.HTTPConfig{
+				URLs:           []string{server.URL},
+				TimeoutSeconds: 1,
+			},
+		},
+	}
+	tester := NewNetworkTester(cfg)
+
+	result, err := tester.RunHTTPTest(context.Background())
+	if err == nil {
+		t.Fatal("Expected error when every request fails")
+	}
+	if result == nil || result.Status != "FAILED" {
+		t.Errorf("Expected FAILED result, got %+v", result)
+	}
+}
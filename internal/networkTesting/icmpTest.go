@@ -1,18 +1,28 @@
 package networkTesting
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"os"
 	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
+// MultiHostICMPResult holds one ICMPTestResult per configured target and
+// address family, in config order with IPv4 before IPv6.
+type MultiHostICMPResult struct {
+	Timestamp time.Time
+	Hosts     []*ICMPTestResult
+	Status    string `json:",omitempty"` // Only set when the run was cancelled
+}
+
 type ICMPTestResult struct {
 	Host      string
+	Address   string `json:",omitempty"`
+	Family    string `json:",omitempty"`
 	Timestamp time.Time
 	Sent      int
 	Received  int
@@ -20,33 +30,90 @@ type ICMPTestResult struct {
 	MinRTT    time.Duration
 	MaxRTT    time.Duration
 	AvgRTT    time.Duration
+	RFactor   float64 `json:",omitempty"`
+	MOS       float64 `json:",omitempty"`
+	Error     string  `json:",omitempty"`
 }
 
 type icmpResponse struct {
-	rm  *icmp.Message
-	rtt time.Duration
-	err error
+	rm        *icmp.Message
+	rtt       time.Duration
+	err       error
+	cancelled bool
 }
 
-func (t *NetworkTester) runICMPTest() (*ICMPTestResult, error) {
-	dst, err := net.ResolveIPAddr("ip4", "8.8.8.8")
+func (t *NetworkTester) runICMPTest(ctx context.Context) (*MultiHostICMPResult, error) {
+	targets := t.config.Tests.ICMP.Targets
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no ICMP targets configured")
+	}
+	families, err := icmpFamilies(t.config.Tests.ICMP.Family)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve IP address: %w", err)
+		return nil, err
+	}
+
+	result := &MultiHostICMPResult{
+		Timestamp: time.Now(),
+		Hosts:     make([]*ICMPTestResult, len(targets)*len(families)),
 	}
 
-	c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		for j, family := range families {
+			wg.Add(1)
+			go func(slot int, target string, family *icmpFamily) {
+				defer wg.Done()
+				result.Hosts[slot] = t.pingHost(ctx, target, family)
+			}(i*len(families)+j, target, family)
+		}
+	}
+	wg.Wait()
+
+	for _, host := range result.Hosts {
+		if host.Error == "" {
+			return result, nil
+		}
+	}
+	return result, errors.New("ICMP test could not run against any target")
+}
+
+// pingHost runs a full ICMP test against a single target. Setup failures are
+// recorded on the result rather than returned so one bad target doesn't
+// hide the others.
+func (t *NetworkTester) pingHost(ctx context.Context, target string, family *icmpFamily) *ICMPTestResult {
+	failed := &ICMPTestResult{Host: target, Family: family.name, Timestamp: time.Now()}
+
+	dst, err := net.ResolveIPAddr(family.network, target)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen for ICMP packets: %w", err)
+		failed.Error = fmt.Sprintf("failed to resolve IP address: %v", err)
+		return failed
 	}
-	defer c.Close()
+	failed.Address = dst.String()
 
-	return t.performICMPTest(c, dst)
+	sock, err := listenICMP(t.config.Tests.ICMP.Mode, family)
+	if err != nil {
+		failed.Error = fmt.Sprintf("failed to listen for ICMP packets: %v", err)
+		return failed
+	}
+	prober := newICMPProber(sock)
+	defer prober.Close()
+
+	result, err := t.performICMPTest(ctx, prober, dst)
+	if err != nil {
+		failed.Error = err.Error()
+		return failed
+	}
+	result.Host = target
+	result.Family = family.name
+	return result
 }
 
-func (t *NetworkTester) performICMPTest(c *icmp.PacketConn, dst *net.IPAddr) (*ICMPTestResult, error) {
+func (t *NetworkTester) performICMPTest(ctx context.Context, prober *icmpProber, dst net.Addr) (*ICMPTestResult, error) {
 	count := t.config.Tests.ICMP.PacketCount
+	timeout := time.Duration(t.config.Tests.ICMP.TimeoutSeconds) * time.Second
 	result := &ICMPTestResult{
 		Host:      dst.String(),
+		Address:   dst.String(),
 		Timestamp: time.Now(),
 		Sent:      count,
 	}
@@ -56,10 +123,11 @@ func (t *NetworkTester) performICMPTest(c *icmp.PacketConn, dst *net.IPAddr) (*I
 
 	for i := 0; i < count; i++ {
 		wg.Add(1)
-		go func(seq int) {
+		go func() {
 			defer wg.Done()
-			t.sendAndReceiveICMP(c, dst, seq, responses)
-		}(i)
+			reply := prober.probe(ctx, dst, []byte("HELLO-R-U-THERE"), timeout)
+			responses <- &icmpResponse{rm: reply.msg, rtt: reply.rtt, err: reply.err, cancelled: reply.err != nil && ctx.Err() != nil}
+		}()
 	}
 
 	go func() {
@@ -71,73 +139,23 @@ func (t *NetworkTester) performICMPTest(c *icmp.PacketConn, dst *net.IPAddr) (*I
 	return result, nil
 }
 
-func (t *NetworkTester) sendAndReceiveICMP(c *icmp.PacketConn, dst *net.IPAddr, seq int, responses chan<- *icmpResponse) {
-	start := time.Now()
-	if err := t.sendICMPPacket(c, dst, seq); err != nil {
-		responses <- &icmpResponse{err: err}
-		return
-	}
-
-	rm, err := t.receiveICMPPacket(c)
-	responses <- &icmpResponse{
-		rm:  rm,
-		rtt: time.Since(start),
-		err: err,
-	}
-}
-
-func (t *NetworkTester) sendICMPPacket(c *icmp.PacketConn, dst *net.IPAddr, sequence int) error {
-	wm := &icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   os.Getpid() & 0xffff,
-			Seq:  sequence,
-			Data: []byte("HELLO-R-U-THERE"),
-		},
-	}
-
-	wb, err := wm.Marshal(nil)
-	if err != nil {
-		return fmt.Errorf("failed to marshal ICMP message: %w", err)
-	}
-
-	if _, err := c.WriteTo(wb, dst); err != nil {
-		return fmt.Errorf("failed to send ICMP message: %w", err)
-	}
-
-	return nil
-}
-
-func (t *NetworkTester) receiveICMPPacket(c *icmp.PacketConn) (*icmp.Message, error) {
-	timeout := time.Duration(t.config.Tests.ICMP.TimeoutSeconds) * time.Second
-	if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
-		return nil, fmt.Errorf("failed to set read deadline: %w", err)
-	}
-
-	rb := make([]byte, 150)
-	n, _, err := c.ReadFrom(rb)
-	if err != nil {
-		return nil, err
-	}
-
-	rm, err := icmp.ParseMessage(1, rb[:n])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse ICMP message: %w", err)
-	}
-
-	return rm, nil
-}
-
 func (t *NetworkTester) processICMPResponses(responses <-chan *icmpResponse, result *ICMPTestResult) {
+	var rtts []time.Duration
 	for resp := range responses {
+		// A ping cut short by cancellation was never given the chance to
+		// be lost.
+		if resp.cancelled {
+			result.Sent--
+			continue
+		}
 		if resp.err != nil {
 			result.Lost++
 			continue
 		}
 
-		if resp.rm.Type == ipv4.ICMPTypeEchoReply {
+		if isEchoReply(resp.rm.Type) {
 			result.Received++
+			rtts = append(rtts, resp.rtt)
 			t.updateICMPStats(result, resp.rtt)
 		} else {
 			result.Lost++
@@ -146,6 +164,17 @@ func (t *NetworkTester) processICMPResponses(responses <-chan *icmpResponse, res
 
 	if result.Received > 0 {
 		result.AvgRTT /= time.Duration(result.Received)
+
+		// The pings all go out at once, so there's no sequence to measure
+		// jitter along; their spread around the mean stands in for it.
+		var spread time.Duration
+		for _, rtt := range rtts {
+			spread += abs(rtt - result.AvgRTT)
+		}
+		jitter := spread / time.Duration(len(rtts))
+		lossPercent := float64(result.Lost) / float64(result.Sent) * 100
+		rFactor, mos := voiceQuality(result.AvgRTT, jitter, lossPercent, 1)
+		result.RFactor, result.MOS = roundTo(rFactor, 1), roundTo(mos, 2)
 	}
 }
 
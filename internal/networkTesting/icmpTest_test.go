@@ -1,11 +1,13 @@
 package networkTesting
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/oshaw1/go-net-test/config"
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 )
@@ -217,3 +219,67 @@ func TestProcessICMPResponses(t *testing.T) {
 		})
 	}
 }
+
+func TestRunICMPTestMultipleHosts(t *testing.T) {
+	cfg := &config.Config{
+		Tests: config.TestConfigs{
+			ICMP: config.ICMPConfig{
+				Targets:        []string{"127.0.0.1", "127.0.0.2"},
+				PacketCount:    3,
+				TimeoutSeconds: 1,
+			},
+		},
+	}
+	tester := NewNetworkTester(cfg)
+
+	result, err := tester.runICMPTest(context.Background())
+	if err != nil {
+		t.Fatalf("runICMPTest returned unexpected error: %v", err)
+	}
+
+	if len(result.Hosts) != 2 {
+		t.Fatalf("Expected 2 host results, got %d", len(result.Hosts))
+	}
+	for i, target := range cfg.Tests.ICMP.Targets {
+		host := result.Hosts[i]
+		if host.Host != target {
+			t.Errorf("Hosts[%d].Host = %q, want %q", i, host.Host, target)
+		}
+		if host.Error != "" {
+			t.Errorf("Unexpected error for %s: %s", target, host.Error)
+		}
+		if host.Sent != 3 || host.Received+host.Lost != host.Sent {
+			t.Errorf("%s: sent/received/lost = %d/%d/%d", target, host.Sent, host.Received, host.Lost)
+		}
+	}
+}
+
+func TestRunICMPTestIPv6(t *testing.T) {
+	cfg := &config.Config{
+		Tests: config.TestConfigs{
+			ICMP: config.ICMPConfig{
+				Targets:        []string{"::1"},
+				Family:         "v6",
+				PacketCount:    3,
+				TimeoutSeconds: 1,
+			},
+		},
+	}
+	tester := NewNetworkTester(cfg)
+
+	result, err := tester.runICMPTest(context.Background())
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+
+	if len(result.Hosts) != 1 {
+		t.Fatalf("Expected 1 host result, got %d", len(result.Hosts))
+	}
+	host := result.Hosts[0]
+	if host.Family != "v6" {
+		t.Errorf("Family = %q, want v6", host.Family)
+	}
+	if host.Received == 0 {
+		t.Errorf("no replies from %s", host.Address)
+	}
+}
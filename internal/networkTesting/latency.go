@@ -1,133 +1,264 @@
 package networkTesting
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"net"
-	"os"
+	"slices"
+	"sync"
 	"time"
-
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
+// LatencyTestResult describes how steady the RTT to one target is.
+//
+// Min/Avg/MaxLatency are the IP packet delay variation between packets with
+// adjacent sequence numbers (RFC 3393), so a lost packet breaks the chain
+// rather than pairing its neighbours. Jitter is the RFC 3550 interarrival
+// jitter: the same differences smoothed with a gain of 1/16, which is the
+// figure VoIP equipment and most ISPs quote. RFactor and MOS rate a voice
+// call over the path from the median RTT, the jitter and the loss.
 type LatencyTestResult struct {
 	Timestamp   time.Time       `json:"timestamp"`
 	Target      string          `json:"target"`
+	Family      string          `json:"family"`
 	PacketCount int             `json:"packet_count"`
+	Interval    time.Duration   `json:"interval"`
 	AvgLatency  time.Duration   `json:"avg_latency"`
 	MaxLatency  time.Duration   `json:"max_latency"`
 	MinLatency  time.Duration   `json:"min_latency"`
+	Jitter      time.Duration   `json:"jitter"`
+	P50RTT      time.Duration   `json:"p50_rtt"`
+	P90RTT      time.Duration   `json:"p90_rtt"`
+	P99RTT      time.Duration   `json:"p99_rtt"`
+	StdDevRTT   time.Duration   `json:"stddev_rtt"`
 	PacketLoss  float64         `json:"packet_loss"`
+	LostPackets []int           `json:"lost_packets,omitempty"`
+	LossBursts  int             `json:"loss_bursts"`
+	MaxBurst    int             `json:"max_loss_burst"`
+	RFactor     float64         `json:"r_factor"`
+	MOS         float64         `json:"mos"`
 	RTTs        []time.Duration `json:"rtts"`
 	Status      string          `json:"status"`
 	Error       error           `json:"error,omitempty"`
+
+	// IPv6 holds the IPv6 run when the family setting is "both". The
+	// top-level fields are then the IPv4 run, and Status covers both.
+	IPv6 *LatencyTestResult `json:"ipv6,omitempty"`
+}
+
+func (t *NetworkTester) RunLatencyTest(ctx context.Context) (*LatencyTestResult, error) {
+	families, err := icmpFamilies(t.config.Tests.LatencyTest.Family)
+	if err != nil {
+		return nil, err
+	}
+	if len(families) == 1 {
+		return t.runLatencyFamily(ctx, families[0])
+	}
+
+	v4, v4Err := t.runLatencyFamily(ctx, familyV4)
+	if v4 == nil {
+		v4 = &LatencyTestResult{Timestamp: time.Now(), Target: t.config.Tests.LatencyTest.Target, Family: FamilyV4, Status: "FAILED", Error: v4Err}
+	}
+	if ctx.Err() != nil {
+		return v4, v4Err
+	}
+	v6, v6Err := t.runLatencyFamily(ctx, familyV6)
+	if v6 == nil {
+		v6 = &LatencyTestResult{Timestamp: time.Now(), Target: t.config.Tests.LatencyTest.Target, Family: FamilyV6, Status: "FAILED", Error: v6Err}
+	}
+	v4.IPv6 = v6
+
+	switch {
+	case v4Err != nil && v6Err != nil:
+		return v4, fmt.Errorf("IPv4: %v; IPv6: %v", v4Err, v6Err)
+	case v4Err != nil || v6Err != nil:
+		v4.Status = "PARTIAL"
+	}
+	return v4, nil
 }
 
-func (t *NetworkTester) RunLatencyTest() (*LatencyTestResult, error) {
-	dst, err := net.ResolveIPAddr("ip4", t.config.Tests.LatencyTest.Target)
+func (t *NetworkTester) runLatencyFamily(ctx context.Context, family *icmpFamily) (*LatencyTestResult, error) {
+	dst, err := net.ResolveIPAddr(family.network, t.config.Tests.LatencyTest.Target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve target IP: %w", err)
 	}
 
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	sock, err := listenICMP(t.config.Tests.ICMP.Mode, family)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ICMP connection: %w", err)
 	}
-	defer conn.Close()
+	prober := newICMPProber(sock)
+	defer prober.Close()
+
+	result, err := t.measureLatency(ctx, icmpPinger{prober: prober, dst: dst})
+	result.Family = family.name
+	return result, err
+}
+
+// measureLatency sends PacketCount pings on a fixed schedule, one every
+// interval from the start. Each ping waits for its reply on its own, so a
+// slow or lost reply doesn't hold back or bunch up the pings after it. If
+// ctx ends the run early, the stats cover the pings answered or timed out
+// before the first one still waiting.
+func (t *NetworkTester) measureLatency(ctx context.Context, p latencyPinger) (*LatencyTestResult, error) {
+	cfg := t.config.Tests.LatencyTest
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	interval := time.Duration(cfg.IntervalMs) * time.Millisecond
 
 	result := &LatencyTestResult{
 		Timestamp:   time.Now(),
-		Target:      t.config.Tests.LatencyTest.Target,
-		PacketCount: t.config.Tests.LatencyTest.PacketCount,
+		Target:      cfg.Target,
+		PacketCount: cfg.PacketCount,
+		Interval:    interval,
 		RTTs:        make([]time.Duration, 0),
 	}
 
-	var lastRTT time.Duration
-	var totalLatency time.Duration
-	var lostPackets int
-
-	for i := 0; i < result.PacketCount; i++ {
-		rtt, err := ping(conn, dst, i, t.config.Tests.ICMP.TimeoutSeconds)
-		if err != nil {
-			lostPackets++
-			continue
+	samples := make([]time.Duration, cfg.PacketCount)
+	finished := make([]bool, cfg.PacketCount)
+	var wg sync.WaitGroup
+	start := time.Now()
+	sent := 0
+	for ; sent < len(samples); sent++ {
+		if wait := time.Until(start.Add(time.Duration(sent) * interval)); wait > 0 && !sleepContext(ctx, wait) {
+			break
 		}
-
-		result.RTTs = append(result.RTTs, rtt)
-
-		if i > 0 {
-			Latency := abs(rtt - lastRTT)
-			totalLatency += Latency
-
-			if Latency > result.MaxLatency {
-				result.MaxLatency = Latency
-			}
-			if Latency < result.MinLatency || result.MinLatency == 0 {
-				result.MinLatency = Latency
-			}
+		if ctx.Err() != nil {
+			break
 		}
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			rtt, err := p.ping(ctx, timeout)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				samples[seq] = lostSample
+			default:
+				samples[seq] = rtt
+			}
+			finished[seq] = true
+		}(sent)
+	}
+	wg.Wait()
 
-		lastRTT = rtt
-		time.Sleep(50 * time.Millisecond) // Space out packets
+	if unfinished := slices.Index(finished[:sent], false); unfinished >= 0 {
+		sent = unfinished
 	}
+	applyLatencyStats(result, samples[:sent])
 
 	if len(result.RTTs) == 0 {
 		result.Status = "FAILED"
 		result.Error = fmt.Errorf("all packets lost")
 		return result, result.Error
 	}
-
-	result.PacketLoss = float64(lostPackets) / float64(result.PacketCount) * 100
-	if len(result.RTTs) > 1 {
-		result.AvgLatency = totalLatency / time.Duration(len(result.RTTs)-1)
-	}
 	result.Status = "SUCCESS"
 
 	return result, nil
 }
 
-func ping(conn *icmp.PacketConn, dst *net.IPAddr, seq int, timeout int) (time.Duration, error) {
-	start := time.Now()
+// lostSample marks a ping with no reply in the samples handed to
+// applyLatencyStats.
+const lostSample time.Duration = -1
 
-	wm := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   os.Getpid() & 0xffff,
-			Seq:  seq,
-			Data: []byte("Latency"),
-		},
-	}
+// applyLatencyStats fills in result's statistics from samples, one per
+// ping in sequence order.
+func applyLatencyStats(result *LatencyTestResult, samples []time.Duration) {
+	var totalIPDV time.Duration
+	var pairs, burst int
+	var jitter float64
+	prev := lostSample
+	var lastReceived time.Duration
+	haveReceived := false
 
-	wb, err := wm.Marshal(nil)
-	if err != nil {
-		return 0, err
-	}
+	for i, rtt := range samples {
+		if rtt == lostSample {
+			result.LostPackets = append(result.LostPackets, i)
+			if burst == 0 {
+				result.LossBursts++
+			}
+			burst++
+			result.MaxBurst = max(result.MaxBurst, burst)
+			prev = lostSample
+			continue
+		}
+		burst = 0
+		result.RTTs = append(result.RTTs, rtt)
 
-	if _, err := conn.WriteTo(wb, dst); err != nil {
-		return 0, err
+		if prev != lostSample {
+			ipdv := abs(rtt - prev)
+			totalIPDV += ipdv
+			if pairs == 0 || ipdv < result.MinLatency {
+				result.MinLatency = ipdv
+			}
+			result.MaxLatency = max(result.MaxLatency, ipdv)
+			pairs++
+		}
+		// RFC 3550 runs over packets in arrival order, gaps included.
+		if haveReceived {
+			jitter += (float64(abs(rtt-lastReceived)) - jitter) / 16
+		}
+		prev, lastReceived, haveReceived = rtt, rtt, true
 	}
 
-	rb := make([]byte, 1500)
-	if err := conn.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second)); err != nil {
-		return 0, err
+	if len(samples) > 0 {
+		result.PacketLoss = float64(len(result.LostPackets)) / float64(len(samples)) * 100
+	}
+	if pairs > 0 {
+		result.AvgLatency = totalIPDV / time.Duration(pairs)
+	}
+	result.Jitter = time.Duration(jitter)
+	if len(result.RTTs) == 0 {
+		return
 	}
 
-	n, _, err := conn.ReadFrom(rb)
-	if err != nil {
-		return 0, err
+	result.P50RTT = percentile(result.RTTs, 50)
+	result.P90RTT = percentile(result.RTTs, 90)
+	result.P99RTT = percentile(result.RTTs, 99)
+
+	var sum float64
+	for _, rtt := range result.RTTs {
+		sum += float64(rtt)
+	}
+	mean := sum / float64(len(result.RTTs))
+	var variance float64
+	for _, rtt := range result.RTTs {
+		variance += (float64(rtt) - mean) * (float64(rtt) - mean)
 	}
+	result.StdDevRTT = time.Duration(math.Sqrt(variance / float64(len(result.RTTs))))
 
-	rm, err := icmp.ParseMessage(1, rb[:n])
-	if err != nil {
-		return 0, err
+	burstR := burstRatio(len(samples), len(result.LostPackets), result.LossBursts)
+	rFactor, mos := voiceQuality(result.P50RTT, result.Jitter, result.PacketLoss, burstR)
+	result.RFactor, result.MOS = roundTo(rFactor, 1), roundTo(mos, 2)
+}
+
+// latencyPinger sends one echo and waits for the reply. measureLatency
+// calls it from several goroutines at once.
+type latencyPinger interface {
+	ping(ctx context.Context, timeout time.Duration) (time.Duration, error)
+}
+
+type icmpPinger struct {
+	prober *icmpProber
+	dst    net.Addr
+}
+
+func (p icmpPinger) ping(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	return ping(ctx, p.prober, p.dst, timeout)
+}
+
+func ping(ctx context.Context, prober *icmpProber, dst net.Addr, timeout time.Duration) (time.Duration, error) {
+	reply := prober.probe(ctx, dst, []byte("Latency"), timeout)
+	if reply.err != nil {
+		return 0, reply.err
 	}
 
-	if rm.Type != ipv4.ICMPTypeEchoReply {
+	if !isEchoReply(reply.msg.Type) {
 		return 0, fmt.Errorf("non-echo reply received")
 	}
 
-	return time.Since(start), nil
+	return reply.rtt, nil
 }
 
 func abs(d time.Duration) time.Duration {
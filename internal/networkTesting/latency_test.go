@@ -1,7 +1,12 @@
 package networkTesting
 
 import (
+	"context"
+	"math"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/oshaw1/go-net-test/config"
 )
@@ -10,17 +15,15 @@ func TestLatencyTest(t *testing.T) {
 	cfg := &config.Config{
 		Tests: config.TestConfigs{
 			LatencyTest: config.LatencyConfig{
-				Target:      "8.8.8.8",
-				PacketCount: 3,
-			},
-			ICMP: config.ICMPConfig{
+				Target:         "8.8.8.8",
+				PacketCount:    3,
 				TimeoutSeconds: 1,
 			},
 		},
 	}
 	tester := NewNetworkTester(cfg)
 
-	result, err := tester.RunLatencyTest()
+	result, err := tester.RunLatencyTest(context.Background())
 	if err != nil {
 		t.Fatalf("runLatencyTest returned unexpected error: %v", err)
 	}
@@ -45,3 +48,191 @@ func TestLatencyTest(t *testing.T) {
 		t.Errorf("Invalid status: %v", result.Status)
 	}
 }
+
+const ms = time.Millisecond
+
+// scriptedPinger replies with each queued RTT in turn; lostSample entries
+// time out.
+type scriptedPinger struct {
+	mu       sync.Mutex
+	rtts     []time.Duration
+	timeouts []time.Duration
+}
+
+func (p *scriptedPinger) ping(_ context.Context, timeout time.Duration) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timeouts = append(p.timeouts, timeout)
+	rtt := p.rtts[0]
+	p.rtts = p.rtts[1:]
+	if rtt == lostSample {
+		return 0, errProbeTimeout
+	}
+	return rtt, nil
+}
+
+// lossyPinger loses the ping numbered lose, which blocks for the whole
+// timeout as a real lost echo does, and answers the rest at once. It
+// records when each ping went out.
+type lossyPinger struct {
+	mu    sync.Mutex
+	lose  int
+	sends []time.Time
+}
+
+func (p *lossyPinger) ping(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	p.mu.Lock()
+	seq := len(p.sends)
+	p.sends = append(p.sends, time.Now())
+	p.mu.Unlock()
+
+	if seq == p.lose {
+		sleepContext(ctx, timeout)
+		return 0, errProbeTimeout
+	}
+	return 5 * ms, nil
+}
+
+func TestApplyLatencyStats(t *testing.T) {
+	result := &LatencyTestResult{}
+	applyLatencyStats(result, []time.Duration{10 * ms, 12 * ms, lostSample, lostSample, 20 * ms, 18 * ms, lostSample, 15 * ms})
+
+	if result.PacketLoss != 37.5 {
+		t.Errorf("PacketLoss = %v, want 37.5", result.PacketLoss)
+	}
+	if !slices.Equal(result.LostPackets, []int{2, 3, 6}) {
+		t.Errorf("LostPackets = %v, want [2 3 6]", result.LostPackets)
+	}
+	if result.LossBursts != 2 || result.MaxBurst != 2 {
+		t.Errorf("bursts = %d (max %d), want 2 (max 2)", result.LossBursts, result.MaxBurst)
+	}
+
+	// Only 10->12 and 20->18 are adjacent; 12->20 and 18->15 straddle losses.
+	if result.MinLatency != 2*ms || result.AvgLatency != 2*ms || result.MaxLatency != 2*ms {
+		t.Errorf("IPDV min/avg/max = %v/%v/%v, want 2ms each", result.MinLatency, result.AvgLatency, result.MaxLatency)
+	}
+
+	// RFC 3550 over arrival order: differences 2, 8, 2 and 3ms, gain 1/16.
+	if result.Jitter != 847137*time.Nanosecond {
+		t.Errorf("Jitter = %v, want 847.137µs", result.Jitter)
+	}
+
+	if result.P50RTT != 15*ms || result.P90RTT != 20*ms || result.P99RTT != 20*ms {
+		t.Errorf("p50/p90/p99 = %v/%v/%v, want 15ms/20ms/20ms", result.P50RTT, result.P90RTT, result.P99RTT)
+	}
+	if want := time.Duration(math.Sqrt(13.6) * float64(ms)); result.StdDevRTT != want {
+		t.Errorf("StdDevRTT = %v, want %v", result.StdDevRTT, want)
+	}
+}
+
+func TestApplyLatencyStatsZeroDifference(t *testing.T) {
+	result := &LatencyTestResult{}
+	applyLatencyStats(result, []time.Duration{10 * ms, 10 * ms, 14 * ms})
+
+	if result.MinLatency != 0 || result.MaxLatency != 4*ms {
+		t.Errorf("IPDV min/max = %v/%v, want 0/4ms", result.MinLatency, result.MaxLatency)
+	}
+	if result.LossBursts != 0 || result.LostPackets != nil {
+		t.Errorf("Expected no losses, got %v", result.LostPackets)
+	}
+}
+
+func TestMeasureLatencyUsesLatencyConfig(t *testing.T) {
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			ICMP: config.ICMPConfig{TimeoutSeconds: 9},
+			LatencyTest: config.LatencyConfig{
+				Target:         "192.0.2.1",
+				PacketCount:    4,
+				IntervalMs:     20,
+				TimeoutSeconds: 2,
+			},
+		},
+	})
+	pinger := &scriptedPinger{rtts: []time.Duration{5 * ms, lostSample, 7 * ms, 6 * ms}}
+
+	start := time.Now()
+	result, err := tester.measureLatency(context.Background(), pinger)
+	if err != nil {
+		t.Fatalf("measureLatency returned error: %v", err)
+	}
+
+	for _, timeout := range pinger.timeouts {
+		if timeout != 2*time.Second {
+			t.Fatalf("ping timeout = %v, want the latency test's 2s", timeout)
+		}
+	}
+	// Four pings 20ms apart: the last goes out 60ms after the first.
+	if elapsed := time.Since(start); elapsed < 60*ms {
+		t.Errorf("pings finished after %v, want them spaced 20ms apart", elapsed)
+	}
+	if result.Interval != 20*ms {
+		t.Errorf("Interval = %v, want 20ms", result.Interval)
+	}
+	if len(result.RTTs) != 3 || result.PacketLoss != 25 {
+		t.Errorf("got %d RTTs with %v%% loss, want 3 and 25%%", len(result.RTTs), result.PacketLoss)
+	}
+	if result.Status != "SUCCESS" {
+		t.Errorf("Status = %s, want SUCCESS", result.Status)
+	}
+}
+
+func TestMeasureLatencyKeepsScheduleThroughLoss(t *testing.T) {
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			LatencyTest: config.LatencyConfig{
+				Target:         "192.0.2.1",
+				PacketCount:    5,
+				IntervalMs:     20,
+				TimeoutSeconds: 1,
+			},
+		},
+	})
+	pinger := &lossyPinger{lose: 1}
+
+	start := time.Now()
+	result, err := tester.measureLatency(context.Background(), pinger)
+	if err != nil {
+		t.Fatalf("measureLatency returned error: %v", err)
+	}
+
+	if len(pinger.sends) != 5 {
+		t.Fatalf("sent %d pings, want 5", len(pinger.sends))
+	}
+	// The lost ping holds its reply for a second, but the three after it
+	// still go out 20ms apart rather than back to back once it gives up.
+	for i := 2; i < len(pinger.sends); i++ {
+		if gap := pinger.sends[i].Sub(pinger.sends[i-1]); gap < 15*ms {
+			t.Errorf("ping %d went out %v after ping %d, want about 20ms", i, gap, i-1)
+		}
+	}
+	if at := pinger.sends[4].Sub(start); at > 500*ms {
+		t.Errorf("last ping went out %v in, want it on schedule at about 80ms", at)
+	}
+	if !slices.Equal(result.LostPackets, []int{1}) || len(result.RTTs) != 4 {
+		t.Errorf("LostPackets = %v with %d RTTs, want [1] and 4", result.LostPackets, len(result.RTTs))
+	}
+}
+
+func TestMeasureLatencyCancelledWhileWaiting(t *testing.T) {
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			LatencyTest: config.LatencyConfig{
+				Target:         "192.0.2.1",
+				PacketCount:    100,
+				IntervalMs:     10,
+				TimeoutSeconds: 5,
+			},
+		},
+	})
+	pinger := &lossyPinger{lose: 3}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*ms)
+	defer cancel()
+	result, _ := tester.measureLatency(ctx, pinger)
+
+	// Ping 3 was still waiting when ctx ended, so the stats stop before it.
+	if len(result.RTTs) != 3 || len(result.LostPackets) != 0 {
+		t.Errorf("got %d RTTs and lost %v, want the 3 pings before the unanswered one", len(result.RTTs), result.LostPackets)
+	}
+}
@@ -0,0 +1,253 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// LoadedLatencyTestResult compares ping times on an idle link with ping
+// times while a download, then an upload, saturates it. The difference is
+// the queueing delay ("bufferbloat") a video call would feel during a
+// backup.
+type LoadedLatencyTestResult struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Target    string             `json:"target"`
+	Family    string             `json:"family"`
+	Idle      LoadedLatencyPhase `json:"idle"`
+	Download  LoadedLatencyPhase `json:"download"`
+	Upload    LoadedLatencyPhase `json:"upload"`
+	Grade     string             `json:"grade,omitempty"`
+	Status    string             `json:"status"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// LoadedLatencyPhase holds the pings from one phase. AddedLatency is the
+// phase's median RTT over the idle median; Mbps is the throughput the load
+// achieved while the pings ran.
+type LoadedLatencyPhase struct {
+	Sent         int             `json:"sent"`
+	Lost         int             `json:"lost"`
+	RTTs         []time.Duration `json:"rtts"`
+	MinRTT       time.Duration   `json:"min_rtt"`
+	MedianRTT    time.Duration   `json:"median_rtt"`
+	P95RTT       time.Duration   `json:"p95_rtt"`
+	MaxRTT       time.Duration   `json:"max_rtt"`
+	AddedLatency time.Duration   `json:"added_latency,omitempty"`
+	Bytes        int64           `json:"bytes,omitempty"`
+	Mbps         float64         `json:"mbps,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+func (t *NetworkTester) RunLoadedLatencyTest(ctx context.Context) (*LoadedLatencyTestResult, error) {
+	families, err := icmpFamilies(t.config.Tests.LatencyTest.Family)
+	if err != nil {
+		return nil, err
+	}
+	family := families[0]
+
+	dst, err := net.ResolveIPAddr(family.network, t.config.Tests.LatencyTest.Target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve target IP: %w", err)
+	}
+	sock, err := listenICMP(t.config.Tests.ICMP.Mode, family)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ICMP connection: %w", err)
+	}
+	prober := newICMPProber(sock)
+	defer prober.Close()
+
+	result, err := t.runLoadedLatency(ctx, icmpPinger{prober: prober, dst: dst})
+	result.Target = t.config.Tests.LatencyTest.Target
+	result.Family = family.name
+	return result, err
+}
+
+// runLoadedLatency skips the phases still to come once ctx is done.
+func (t *NetworkTester) runLoadedLatency(ctx context.Context, p latencyPinger) (*LoadedLatencyTestResult, error) {
+	cfg := t.config.Tests.LoadedLatency
+	result := &LoadedLatencyTestResult{Timestamp: time.Now()}
+
+	result.Idle = t.measureLatencyPhase(ctx, p, nil)
+	if ctx.Err() != nil {
+		return result, nil
+	}
+	if len(result.Idle.RTTs) == 0 {
+		result.Status = "FAILED"
+		result.Error = "all idle pings lost"
+		return result, errors.New(result.Error)
+	}
+
+	result.Download = t.measureLatencyPhase(ctx, p, func(ctx context.Context) (int64, error) {
+		return repeatLoad(ctx, func(ctx context.Context) (int64, error) {
+			return t.downloadWithProgress(ctx, cfg.DownloadURL)
+		})
+	})
+
+	block, err := newPayloadBlock()
+	if err != nil {
+		result.Upload.Error = fmt.Sprintf("failed to generate test data: %v", err)
+	} else if ctx.Err() == nil {
+		result.Upload = t.measureLatencyPhase(ctx, p, func(ctx context.Context) (int64, error) {
+			return uploadLoad(ctx, cfg.UploadURL, block)
+		})
+	}
+
+	var worst time.Duration
+	loaded := 0
+	for _, phase := range []*LoadedLatencyPhase{&result.Download, &result.Upload} {
+		if phase.Error != "" || len(phase.RTTs) == 0 {
+			continue
+		}
+		phase.AddedLatency = max(phase.MedianRTT-result.Idle.MedianRTT, 0)
+		worst = max(worst, phase.AddedLatency)
+		loaded++
+	}
+
+	switch loaded {
+	case 0:
+		result.Status = "FAILED"
+		result.Error = "no load phase completed"
+		return result, errors.New(result.Error)
+	case 1:
+		result.Status = "PARTIAL"
+	default:
+		result.Status = "SUCCESS"
+	}
+	result.Grade = bufferbloatGrade(worst)
+
+	return result, nil
+}
+
+// measureLatencyPhase pings while load runs, or on an idle link when load is
+// nil. The load gets a head start so the pings see full queues, and is
+// cancelled as soon as the last ping is back, or when ctx is done.
+func (t *NetworkTester) measureLatencyPhase(ctx context.Context, p latencyPinger, load func(context.Context) (int64, error)) LoadedLatencyPhase {
+	cfg := t.config.Tests.LoadedLatency
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	interval := time.Duration(cfg.IntervalMs) * time.Millisecond
+	phase := LoadedLatencyPhase{RTTs: make([]time.Duration, 0, cfg.PingCount)}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	var loadBytes int64
+	var loadErr error
+	start := time.Now()
+	if load != nil {
+		go func() {
+			defer close(done)
+			loadBytes, loadErr = load(ctx)
+		}()
+		select {
+		case <-time.After(time.Duration(cfg.WarmupSeconds) * time.Second):
+		case <-done:
+		case <-ctx.Done():
+		}
+	} else {
+		close(done)
+	}
+
+	for i := 0; i < cfg.PingCount && ctx.Err() == nil; i++ {
+		if load != nil && isClosed(done) {
+			break
+		}
+		rtt, err := p.ping(ctx, timeout)
+		if ctx.Err() != nil {
+			break
+		}
+		phase.Sent++
+		if err != nil {
+			phase.Lost++
+		} else {
+			phase.RTTs = append(phase.RTTs, rtt)
+		}
+		sleepContext(ctx, interval)
+	}
+
+	cancel()
+	<-done
+	if load != nil {
+		phase.Bytes = loadBytes
+		phase.Mbps = megabitsPerSecond(loadBytes, time.Since(start))
+		if loadErr != nil {
+			phase.Error = loadErr.Error()
+		} else if phase.Sent < cfg.PingCount && parent.Err() == nil {
+			phase.Error = "load finished before the pings did"
+		}
+	}
+
+	if len(phase.RTTs) > 0 {
+		phase.MinRTT = percentile(phase.RTTs, 0)
+		phase.MedianRTT = percentile(phase.RTTs, 50)
+		phase.P95RTT = percentile(phase.RTTs, 95)
+		phase.MaxRTT = percentile(phase.RTTs, 100)
+	}
+	return phase
+}
+
+// repeatLoad runs transfer back to back until ctx is done, so a short file
+// still keeps the link busy for the whole phase. Errors caused by the
+// cancellation itself are the normal way out.
+func repeatLoad(ctx context.Context, transfer func(context.Context) (int64, error)) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := transfer(ctx)
+		total += n
+		if err != nil && ctx.Err() == nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// loadedUploadBytes is the size of each upload repeated to load the link.
+const loadedUploadBytes = 10 * 1024 * 1024
+
+// uploadLoad uploads to url back to back on one connection until ctx is
+// done, and returns how many bytes reached the socket, including the part
+// of an upload cut short.
+func uploadLoad(ctx context.Context, url string, block []byte) (int64, error) {
+	var written atomic.Int64
+	stream := newUploadStream(&written)
+	defer stream.close()
+
+	_, err := repeatLoad(ctx, func(ctx context.Context) (int64, error) {
+		return 0, stream.upload(ctx, url, block, loadedUploadBytes)
+	})
+	return written.Load(), err
+}
+
+// bufferbloatGrade turns the worst added latency under load into a letter,
+// using the thresholds popularised by the Waveform bufferbloat test: A+
+// means calls and games won't notice a saturated link, F means they will
+// stall.
+func bufferbloatGrade(added time.Duration) string {
+	switch {
+	case added < 5*time.Millisecond:
+		return "A+"
+	case added < 30*time.Millisecond:
+		return "A"
+	case added < 60*time.Millisecond:
+		return "B"
+	case added < 200*time.Millisecond:
+		return "C"
+	case added < 400*time.Millisecond:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
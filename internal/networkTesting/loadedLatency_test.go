@@ -0,0 +1,176 @@
+package networkTesting
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+// throttledServer serves an endless slow download on GET, so a phase only
+// ends if its load is cancelled, and drains uploads slowly on POST, counting the transfers in flight so a fake pinger can
+// tell whether the "link" is busy.
+type throttledServer struct {
+	*httptest.Server
+	active atomic.Int32
+}
+
+func newThrottledServer(t *testing.T) *throttledServer {
+	s := &throttledServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		s.active.Add(1)
+		defer s.active.Add(-1)
+
+		chunk := make([]byte, 16*1024)
+		tick := time.NewTicker(5 * time.Millisecond)
+		defer tick.Stop()
+		for range tick.C {
+			if r.Method == http.MethodPost {
+				if _, err := r.Body.Read(chunk); err != nil {
+					return
+				}
+				continue
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// bloatedPinger answers in idle while the server is quiet and in busy while
+// it has a transfer running, like a link with an oversized queue.
+type bloatedPinger struct {
+	server     *throttledServer
+	idle, busy time.Duration
+	sent       atomic.Int32
+}
+
+func (p *bloatedPinger) ping(context.Context, time.Duration) (time.Duration, error) {
+	p.sent.Add(1)
+	if p.server.active.Load() > 0 {
+		return p.busy, nil
+	}
+	return p.idle, nil
+}
+
+func loadedLatencyTester(downloadURL, uploadURL string) *NetworkTester {
+	return NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			LoadedLatency: config.LoadedLatencyConfig{
+				DownloadURL:    downloadURL,
+				UploadURL:      uploadURL,
+				PingCount:      5,
+				IntervalMs:     10,
+				WarmupSeconds:  1,
+				TimeoutSeconds: 1,
+			},
+		},
+	})
+}
+
+func TestRunLoadedLatency(t *testing.T) {
+	server := newThrottledServer(t)
+	pinger := &bloatedPinger{server: server, idle: 10 * time.Millisecond, busy: 90 * time.Millisecond}
+	tester := loadedLatencyTester(server.URL+"/download", server.URL+"/upload")
+
+	result, err := tester.runLoadedLatency(context.Background(), pinger)
+	if err != nil {
+		t.Fatalf("runLoadedLatency returned error: %v", err)
+	}
+
+	if result.Status != "SUCCESS" {
+		t.Errorf("Status = %s, want SUCCESS (%s)", result.Status, result.Error)
+	}
+	if got := pinger.sent.Load(); got != 15 {
+		t.Errorf("sent %d pings, want 5 per phase", got)
+	}
+	if result.Idle.MedianRTT != 10*time.Millisecond {
+		t.Errorf("idle median = %v, want 10ms", result.Idle.MedianRTT)
+	}
+	for name, phase := range map[string]LoadedLatencyPhase{"download": result.Download, "upload": result.Upload} {
+		if phase.Error != "" {
+			t.Errorf("%s phase failed: %s", name, phase.Error)
+		}
+		if phase.AddedLatency != 80*time.Millisecond {
+			t.Errorf("%s added latency = %v, want 80ms", name, phase.AddedLatency)
+		}
+		if phase.Bytes <= 0 || phase.Mbps <= 0 {
+			t.Errorf("%s load moved %d bytes at %.2f Mbps, want both > 0", name, phase.Bytes, phase.Mbps)
+		}
+	}
+	if result.Grade != "C" {
+		t.Errorf("Grade = %s, want C for 80ms of bufferbloat", result.Grade)
+	}
+}
+
+func TestRunLoadedLatencyFailedLoad(t *testing.T) {
+	server := newThrottledServer(t)
+	pinger := &bloatedPinger{server: server, idle: 10 * time.Millisecond, busy: 90 * time.Millisecond}
+	tester := loadedLatencyTester(server.URL+"/missing", server.URL+"/upload")
+
+	result, err := tester.runLoadedLatency(context.Background(), pinger)
+	if err != nil {
+		t.Fatalf("runLoadedLatency returned error: %v", err)
+	}
+
+	if result.Status != "PARTIAL" {
+		t.Errorf("Status = %s, want PARTIAL", result.Status)
+	}
+	if result.Download.Error == "" {
+		t.Error("Expected the download phase to report the 404")
+	}
+	if result.Upload.Error != "" || result.Upload.AddedLatency != 80*time.Millisecond {
+		t.Errorf("upload phase = %+v, want 80ms added and no error", result.Upload)
+	}
+}
+
+func TestBufferbloatGrade(t *testing.T) {
+	tests := []struct {
+		added time.Duration
+		want  string
+	}{
+		{0, "A+"},
+		{4 * time.Millisecond, "A+"},
+		{5 * time.Millisecond, "A"},
+		{59 * time.Millisecond, "B"},
+		{150 * time.Millisecond, "C"},
+		{399 * time.Millisecond, "D"},
+		{time.Second, "F"},
+	}
+
+	for _, tt := range tests {
+		if got := bufferbloatGrade(tt.added); got != tt.want {
+			t.Errorf("bufferbloatGrade(%v) = %s, want %s", tt.added, got, tt.want)
+		}
+	}
+}
+
+func TestUploadLoadCountsPartialBody(t *testing.T) {
+	server := newThrottledServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	block, err := newPayloadBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := uploadLoad(ctx, server.URL, block)
+	if err != nil {
+		t.Fatalf("uploadLoad returned %v, want cancellation to end it cleanly", err)
+	}
+	if n <= 0 || n >= loadedUploadBytes {
+		t.Errorf("sent %d bytes, want part of the %d-byte body", n, loadedUploadBytes)
+	}
+}
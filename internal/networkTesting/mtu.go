@@ -0,0 +1,274 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+type MTUTestResult struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Targets   []MTUTargetResult `json:"targets"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// MTUTargetResult is the outcome of one path MTU search. MTU is the largest
+// packet, IP header included, that got an echo reply. BlackHole flags the
+// case the search exists to catch: bigger packets vanished without any
+// router saying they were too big.
+type MTUTargetResult struct {
+	Target     string          `json:"target"`
+	Address    string          `json:"address,omitempty"`
+	Family     string          `json:"family"`
+	MTU        int             `json:"mtu"`
+	Probes     []MTUProbe      `json:"probes"`
+	FragNeeded []MTUFragNeeded `json:"frag_needed,omitempty"`
+	BlackHole  bool            `json:"black_hole"`
+	Failed     bool            `json:"failed"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// MTUProbe is one size tried. Reason says why a failed probe failed.
+type MTUProbe struct {
+	Size   int           `json:"size"`
+	Passed bool          `json:"passed"`
+	RTT    time.Duration `json:"rtt,omitempty"`
+	Reason string        `json:"reason,omitempty"`
+}
+
+// MTUFragNeeded records an ICMP "fragmentation needed" (IPv6 "packet too
+// big") and the next-hop MTU the router reported.
+type MTUFragNeeded struct {
+	From string `json:"from"`
+	Size int    `json:"size"`
+	MTU  int    `json:"mtu"`
+}
+
+const (
+	mtuReasonTimeout     = "timeout"
+	mtuReasonFragNeeded  = "frag_needed"
+	mtuReasonLocalMTU    = "local_mtu"
+	mtuReasonUnreachable = "unreachable"
+)
+
+func (t *NetworkTester) RunMTUTest(ctx context.Context) (*MTUTestResult, error) {
+	cfg := t.config.Tests.MTU
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("no MTU targets configured")
+	}
+	families, err := icmpFamilies(cfg.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MTUTestResult{
+		Timestamp: time.Now(),
+		Targets:   make([]MTUTargetResult, len(cfg.Targets)*len(families)),
+	}
+
+	var wg sync.WaitGroup
+	for i, target := range cfg.Targets {
+		for j, family := range families {
+			wg.Add(1)
+			go func(slot int, target string, family *icmpFamily) {
+				defer wg.Done()
+				result.Targets[slot] = t.discoverPathMTU(ctx, target, family)
+			}(i*len(families)+j, target, family)
+		}
+	}
+	wg.Wait()
+
+	failures, blackHoles := 0, 0
+	for _, target := range result.Targets {
+		switch {
+		case target.Failed:
+			failures++
+		case target.BlackHole:
+			blackHoles++
+		}
+	}
+
+	switch {
+	case failures == len(result.Targets):
+		result.Status = "FAILED"
+		result.Error = "path MTU could not be measured to any target"
+		return result, errors.New(result.Error)
+	case failures > 0 || blackHoles > 0:
+		result.Status = "PARTIAL"
+	default:
+		result.Status = "SUCCESS"
+	}
+
+	return result, nil
+}
+
+func (t *NetworkTester) discoverPathMTU(ctx context.Context, target string, family *icmpFamily) MTUTargetResult {
+	cfg := t.config.Tests.MTU
+	result := MTUTargetResult{Target: target, Family: family.name}
+
+	dst, err := net.ResolveIPAddr(family.network, target)
+	if err != nil {
+		result.Failed = true
+		result.Error = fmt.Sprintf("failed to resolve IP address: %v", err)
+		return result
+	}
+	result.Address = dst.String()
+
+	sock, err := listenICMP(t.config.Tests.ICMP.Mode, family)
+	if err != nil {
+		result.Failed = true
+		result.Error = fmt.Sprintf("failed to listen for ICMP packets: %v", err)
+		return result
+	}
+	if err := setDontFragment(sock); err != nil {
+		sock.conn.Close()
+		result.Failed = true
+		result.Error = fmt.Sprintf("failed to set don't-fragment: %v", err)
+		return result
+	}
+	prober := newICMPProber(sock)
+	defer prober.Close()
+
+	floor := cfg.MinMTU
+	if floor == 0 {
+		floor = defaultMinMTU(family)
+	}
+	if floor < family.quotedHeaderLen+8 || floor > cfg.MaxMTU {
+		result.Failed = true
+		result.Error = fmt.Sprintf("invalid MTU range %d-%d", floor, cfg.MaxMTU)
+		return result
+	}
+
+	search := &mtuSearch{
+		ctx:      ctx,
+		prober:   prober,
+		dst:      dst,
+		family:   family,
+		attempts: max(cfg.Attempts, 1),
+		timeout:  time.Duration(cfg.TimeoutSeconds) * time.Second,
+		result:   &result,
+	}
+	search.run(floor, cfg.MaxMTU)
+	return result
+}
+
+// defaultMinMTU is where the search starts when no floor is configured:
+// 576 is the smallest datagram every IPv4 host must accept, and IPv6 links
+// can't go below 1280.
+func defaultMinMTU(family *icmpFamily) int {
+	if family == familyV6 {
+		return 1280
+	}
+	return 576
+}
+
+// mtuSearch binary searches for the largest echo that gets through. A
+// "fragmentation needed" names the next-hop MTU, so the search jumps
+// straight to that size instead of halving. If ctx ends the search early,
+// MTU is the largest size confirmed so far.
+type mtuSearch struct {
+	ctx      context.Context
+	prober   *icmpProber
+	dst      *net.IPAddr
+	family   *icmpFamily
+	attempts int
+	timeout  time.Duration
+	result   *MTUTargetResult
+}
+
+func (s *mtuSearch) run(floor, ceiling int) {
+	first := s.try(floor)
+	if err := s.ctx.Err(); err != nil {
+		s.result.Failed = true
+		s.result.Error = fmt.Sprintf("search cancelled: %v", err)
+		return
+	}
+	if !first.Passed {
+		s.result.Failed = true
+		s.result.Error = fmt.Sprintf("no reply to a %d-byte probe (%s)", floor, first.Reason)
+		return
+	}
+
+	// lo always passed and hi always failed (or is one past the ceiling).
+	lo, hi := floor, ceiling+1
+	var hiReason string
+	size := ceiling
+	for hi-lo > 1 {
+		probe := s.try(size)
+		if s.ctx.Err() != nil {
+			s.result.MTU = lo
+			s.result.Error = "search cancelled"
+			return
+		}
+		if probe.Passed {
+			lo = size
+		} else {
+			hi, hiReason = size, probe.Reason
+		}
+
+		size = lo + (hi-lo)/2
+		if probe.Reason == mtuReasonFragNeeded {
+			// Anything above the reported MTU fails at that router.
+			if hint := s.result.FragNeeded[len(s.result.FragNeeded)-1].MTU; hint > lo && hint < hi {
+				hi, size = hint+1, hint
+			}
+		}
+	}
+
+	s.result.MTU = lo
+	s.result.BlackHole = lo < ceiling && hiReason == mtuReasonTimeout && len(s.result.FragNeeded) == 0
+}
+
+// try sends echoes of the given total packet size until one is answered or
+// the attempts run out. Only silence is retried: a router's "too big" or a
+// local size error is a definite answer.
+func (s *mtuSearch) try(size int) MTUProbe {
+	probe := MTUProbe{Size: size}
+	payload := make([]byte, size-s.family.quotedHeaderLen-8)
+
+	for attempt := 0; attempt < s.attempts; attempt++ {
+		reply := s.prober.probe(s.ctx, s.dst, payload, s.timeout)
+		if errors.Is(reply.err, errProbeTimeout) {
+			probe.Reason = mtuReasonTimeout
+			continue
+		}
+
+		switch {
+		case errors.Is(reply.err, syscall.EMSGSIZE):
+			probe.Reason = mtuReasonLocalMTU
+		case reply.err != nil:
+			probe.Reason = mtuReasonUnreachable
+		case isEchoReply(reply.msg.Type):
+			probe.Passed, probe.RTT, probe.Reason = true, reply.rtt, ""
+		case isTooBig(reply.msg):
+			probe.Reason = mtuReasonFragNeeded
+			s.result.FragNeeded = append(s.result.FragNeeded, MTUFragNeeded{
+				From: reply.peer.String(),
+				Size: size,
+				MTU:  reply.nextHopMTU,
+			})
+		default:
+			probe.Reason = mtuReasonUnreachable
+		}
+		break
+	}
+
+	s.result.Probes = append(s.result.Probes, probe)
+	return probe
+}
+
+// isTooBig reports whether rm says a probe needed fragmenting. Routers that
+// predate RFC 1191 send it with no MTU, so the type is checked, not the MTU.
+func isTooBig(rm *icmp.Message) bool {
+	return rm.Type == ipv6.ICMPTypePacketTooBig ||
+		(rm.Type == ipv4.ICMPTypeDestinationUnreachable && rm.Code == icmpCodeFragNeeded)
+}
@@ -0,0 +1,164 @@
+package networkTesting
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// fakePath answers the prober's echoes like a path whose narrowest link is
+// mtu bytes. Oversized probes get a "fragmentation needed" from router, or
+// nothing at all when router is nil.
+func fakePath(t *testing.T, conn *fakePacketConn, target, router *net.IPAddr, mtu int) {
+	for {
+		var b []byte
+		select {
+		case b = <-conn.writes:
+		case <-conn.closed:
+			return
+		}
+		rm, err := icmp.ParseMessage(protocolICMP, b)
+		if err != nil {
+			t.Errorf("prober wrote unparseable ICMP: %v", err)
+			return
+		}
+		req := rm.Body.(*icmp.Echo)
+
+		if ipv4.HeaderLen+len(b) <= mtu {
+			reply, _ := (&icmp.Message{
+				Type: ipv4.ICMPTypeEchoReply,
+				Body: &icmp.Echo{ID: req.ID, Seq: req.Seq, Data: req.Data},
+			}).Marshal(nil)
+			conn.reads <- fakePacket{data: reply, peer: target}
+			continue
+		}
+		if router == nil {
+			continue
+		}
+
+		header, _ := (&ipv4.Header{
+			Version:  ipv4.Version,
+			Len:      ipv4.HeaderLen,
+			TotalLen: ipv4.HeaderLen + len(b),
+			TTL:      63,
+			Protocol: protocolICMP,
+			Dst:      target.IP,
+		}).Marshal()
+		tooBig, _ := (&icmp.Message{
+			Type: ipv4.ICMPTypeDestinationUnreachable,
+			Code: icmpCodeFragNeeded,
+			Body: &icmp.DstUnreach{Data: append(header, b[:8]...)},
+		}).Marshal(nil)
+		binary.BigEndian.PutUint16(tooBig[6:8], uint16(mtu))
+		conn.reads <- fakePacket{data: tooBig, peer: router}
+	}
+}
+
+func runFakeMTUSearch(t *testing.T, router *net.IPAddr, pathMTU int) MTUTargetResult {
+	t.Helper()
+	conn := newFakePacketConn()
+	prober := newICMPProber(&icmpSocket{conn: conn, family: familyV4})
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	go fakePath(t, conn, target, router, pathMTU)
+
+	result := MTUTargetResult{Target: target.String(), Family: FamilyV4}
+	search := &mtuSearch{
+		ctx:      context.Background(),
+		prober:   prober,
+		dst:      target,
+		family:   familyV4,
+		attempts: 2,
+		timeout:  20 * time.Millisecond,
+		result:   &result,
+	}
+	search.run(576, 1500)
+	return result
+}
+
+func TestMTUSearchFollowsFragNeeded(t *testing.T) {
+	router := &net.IPAddr{IP: net.ParseIP("198.51.100.1")}
+	result := runFakeMTUSearch(t, router, 1400)
+
+	if result.Failed {
+		t.Fatalf("search failed: %s", result.Error)
+	}
+	if result.MTU != 1400 {
+		t.Errorf("MTU = %d, want 1400", result.MTU)
+	}
+	if result.BlackHole {
+		t.Error("path that reports its MTU flagged as a black hole")
+	}
+	if len(result.FragNeeded) != 1 {
+		t.Fatalf("FragNeeded = %+v, want a single report", result.FragNeeded)
+	}
+	if got := result.FragNeeded[0]; got.From != router.String() || got.Size != 1500 || got.MTU != 1400 {
+		t.Errorf("FragNeeded[0] = %+v, want from %v for 1500 bytes with MTU 1400", got, router)
+	}
+	// The hint should spare the search from bisecting: floor, ceiling, hint.
+	if len(result.Probes) != 3 {
+		t.Errorf("sent %d probe sizes, want 3: %+v", len(result.Probes), result.Probes)
+	}
+}
+
+func TestMTUSearchDetectsBlackHole(t *testing.T) {
+	result := runFakeMTUSearch(t, nil, 1280)
+
+	if result.Failed {
+		t.Fatalf("search failed: %s", result.Error)
+	}
+	if result.MTU != 1280 {
+		t.Errorf("MTU = %d, want 1280", result.MTU)
+	}
+	if !result.BlackHole {
+		t.Error("silently dropped probes not flagged as a black hole")
+	}
+	for _, probe := range result.Probes {
+		if !probe.Passed && probe.Reason != mtuReasonTimeout {
+			t.Errorf("probe %+v failed for %q, want timeout", probe, probe.Reason)
+		}
+	}
+}
+
+func TestMTUSearchFullPath(t *testing.T) {
+	result := runFakeMTUSearch(t, nil, 1500)
+
+	if result.MTU != 1500 || result.BlackHole || result.Failed {
+		t.Errorf("result = %+v, want MTU 1500 with no black hole", result)
+	}
+}
+
+func TestNextHopMTU(t *testing.T) {
+	b, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeDestinationUnreachable,
+		Code: icmpCodeFragNeeded,
+		Body: &icmp.DstUnreach{Data: make([]byte, 28)},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint16(b[6:8], 1492)
+	rm, err := icmp.ParseMessage(protocolICMP, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nextHopMTU(rm, b); got != 1492 {
+		t.Errorf("nextHopMTU = %d, want 1492", got)
+	}
+
+	// Port unreachable shares the type but says nothing about size.
+	b[1] = 3
+	rm, err = icmp.ParseMessage(protocolICMP, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nextHopMTU(rm, b); got != 0 {
+		t.Errorf("nextHopMTU for port unreachable = %d, want 0", got)
+	}
+}
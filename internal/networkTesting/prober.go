@@ -0,0 +1,274 @@
+package networkTesting
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var errProbeTimeout = errors.New("timed out waiting for ICMP reply")
+
+// packetConn is the part of *icmp.PacketConn the prober uses, split out so
+// tests can feed it scripted replies.
+type packetConn interface {
+	ReadFrom(b []byte) (int, net.Addr, error)
+	WriteTo(b []byte, dst net.Addr) (int, error)
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+type probeReply struct {
+	msg  *icmp.Message
+	peer net.Addr
+	rtt  time.Duration
+	err  error
+
+	// nextHopMTU is set when msg says the probe was too big to forward.
+	nextHopMTU int
+}
+
+type pendingProbe struct {
+	sent  time.Time
+	reply chan probeReply
+}
+
+// icmpProber owns an ICMP socket and runs a single reader that hands each
+// reply to the probe that caused it, keyed by echo sequence. Replies carrying
+// another prober's echo ID (every raw socket sees all ICMP on the host), late
+// replies to abandoned probes and anything unparseable are dropped. On a
+// datagram socket the kernel rewrites the echo ID and already filters to our
+// own replies, so only the sequence is matched.
+type icmpProber struct {
+	conn     packetConn
+	family   *icmpFamily
+	id       int
+	datagram bool
+
+	mu      sync.Mutex
+	seq     int
+	pending map[int]*pendingProbe
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+var echoIDCounter atomic.Uint32
+
+// nextEchoID gives each prober in the process its own echo ID so that
+// concurrent tests never claim each other's replies.
+func nextEchoID() int {
+	return int((uint32(os.Getpid()) + echoIDCounter.Add(1)) & 0xffff)
+}
+
+// newICMPProber takes ownership of sock; closing the prober closes it.
+func newICMPProber(sock *icmpSocket) *icmpProber {
+	p := &icmpProber{
+		conn:     sock.conn,
+		family:   sock.family,
+		id:       nextEchoID(),
+		datagram: sock.datagram,
+		pending:  make(map[int]*pendingProbe),
+		done:     make(chan struct{}),
+	}
+	go p.readLoop()
+	return p
+}
+
+// Close closes the underlying socket and waits for the reader to exit.
+func (p *icmpProber) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		err = p.conn.Close()
+		<-p.done
+	})
+	return err
+}
+
+// probe sends one echo request to dst and waits up to timeout, or until ctx
+// is done, for a matching echo reply or ICMP error. The RTT runs from just
+// before the write to the moment the reader pulled the reply off the socket.
+func (p *icmpProber) probe(ctx context.Context, dst net.Addr, payload []byte, timeout time.Duration) probeReply {
+	if ip, ok := dst.(*net.IPAddr); ok && p.datagram {
+		dst = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+	}
+
+	pending := &pendingProbe{reply: make(chan probeReply, 1)}
+
+	p.mu.Lock()
+	seq := p.seq
+	p.seq = (p.seq + 1) & 0xffff
+	p.pending[seq] = pending
+	p.mu.Unlock()
+
+	wm := icmp.Message{
+		Type: p.family.echoRequest,
+		Code: 0,
+		Body: &icmp.Echo{ID: p.id, Seq: seq, Data: payload},
+	}
+	wb, err := wm.Marshal(nil)
+	if err != nil {
+		p.forget(seq)
+		return probeReply{err: fmt.Errorf("failed to marshal ICMP message: %w", err)}
+	}
+
+	p.mu.Lock()
+	pending.sent = time.Now()
+	p.mu.Unlock()
+	if _, err := p.conn.WriteTo(wb, dst); err != nil {
+		p.forget(seq)
+		return probeReply{err: fmt.Errorf("failed to send ICMP message: %w", err)}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case reply := <-pending.reply:
+		return reply
+	case <-timer.C:
+		p.forget(seq)
+		return probeReply{err: errProbeTimeout}
+	case <-ctx.Done():
+		p.forget(seq)
+		return probeReply{err: ctx.Err()}
+	case <-p.done:
+		return probeReply{err: net.ErrClosed}
+	}
+}
+
+func (p *icmpProber) forget(seq int) {
+	p.mu.Lock()
+	delete(p.pending, seq)
+	p.mu.Unlock()
+}
+
+func (p *icmpProber) readLoop() {
+	defer close(p.done)
+
+	rb := make([]byte, 1<<16) // MTU probes can be jumbo-sized
+	for {
+		n, peer, err := p.conn.ReadFrom(rb)
+		received := time.Now()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if isTimeout(err) {
+				continue
+			}
+			return
+		}
+
+		rm, err := icmp.ParseMessage(p.family.proto, rb[:n])
+		if err != nil {
+			continue
+		}
+
+		id, seq, ok := p.family.echoIdentity(rm)
+		if !ok || (!p.datagram && id != p.id) {
+			continue
+		}
+		if udp, ok := peer.(*net.UDPAddr); ok {
+			peer = &net.IPAddr{IP: udp.IP, Zone: udp.Zone}
+		}
+
+		p.mu.Lock()
+		pending, found := p.pending[seq]
+		if found {
+			delete(p.pending, seq)
+		}
+		p.mu.Unlock()
+		if !found {
+			continue
+		}
+
+		pending.reply <- probeReply{
+			msg:        rm,
+			peer:       peer,
+			rtt:        received.Sub(pending.sent),
+			nextHopMTU: nextHopMTU(rm, rb[:n]),
+		}
+	}
+}
+
+// echoIdentity returns the echo ID and sequence a message refers to. For echo
+// replies that's the body itself; for ICMP errors it's the echo request
+// quoted after the embedded IP header.
+func (f *icmpFamily) echoIdentity(rm *icmp.Message) (id, seq int, ok bool) {
+	switch body := rm.Body.(type) {
+	case *icmp.Echo:
+		if rm.Type != f.echoReply {
+			return 0, 0, false
+		}
+		return body.ID, body.Seq, true
+	case *icmp.TimeExceeded:
+		return f.quotedEchoIdentity(body.Data)
+	case *icmp.DstUnreach:
+		return f.quotedEchoIdentity(body.Data)
+	case *icmp.PacketTooBig:
+		return f.quotedEchoIdentity(body.Data)
+	}
+	return 0, 0, false
+}
+
+// quotedEchoIdentity reads the echo header out of the original packet an
+// ICMP error quotes. IPv6 extension headers aren't walked; we never send any.
+func (f *icmpFamily) quotedEchoIdentity(data []byte) (id, seq int, ok bool) {
+	if len(data) < f.quotedHeaderLen {
+		return 0, 0, false
+	}
+
+	headerLen, next := ipv6.HeaderLen, int(data[6])
+	if f.proto == protocolICMP {
+		headerLen, next = int(data[0]&0x0f)*4, int(data[9])
+	}
+	if headerLen < f.quotedHeaderLen || next != f.proto || len(data) < headerLen+8 {
+		return 0, 0, false
+	}
+
+	quoted := data[headerLen:]
+	var quotedType icmp.Type = ipv4.ICMPType(quoted[0])
+	if f.proto == protocolICMPv6 {
+		quotedType = ipv6.ICMPType(quoted[0])
+	}
+	if quotedType != f.echoRequest {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(quoted[4:6])), int(binary.BigEndian.Uint16(quoted[6:8])), true
+}
+
+// isEchoReply and isTimeExceeded classify a reply from either family.
+func isEchoReply(t icmp.Type) bool {
+	return t == ipv4.ICMPTypeEchoReply || t == ipv6.ICMPTypeEchoReply
+}
+
+func isTimeExceeded(t icmp.Type) bool {
+	return t == ipv4.ICMPTypeTimeExceeded || t == ipv6.ICMPTypeTimeExceeded
+}
+
+// nextHopMTU returns the MTU a router reported when it couldn't forward a
+// probe, or 0 if rm isn't such a report. x/net drops the IPv4 next-hop MTU
+// field while parsing, so it's read from the raw message b.
+func nextHopMTU(rm *icmp.Message, b []byte) int {
+	if body, ok := rm.Body.(*icmp.PacketTooBig); ok {
+		return body.MTU
+	}
+	if rm.Type == ipv4.ICMPTypeDestinationUnreachable && rm.Code == icmpCodeFragNeeded && len(b) >= 8 {
+		return int(binary.BigEndian.Uint16(b[6:8]))
+	}
+	return 0
+}
+
+// icmpCodeFragNeeded is IPv4 Destination Unreachable code 4, "fragmentation
+// needed and DF set".
+const icmpCodeFragNeeded = 4
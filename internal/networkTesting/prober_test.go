@@ -0,0 +1,278 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+type fakePacket struct {
+	data []byte
+	peer net.Addr
+}
+
+// fakePacketConn hands every write to the test and returns whatever the test
+// queues as reads, in the order queued.
+type fakePacketConn struct {
+	writes    chan []byte
+	reads     chan fakePacket
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakePacketConn() *fakePacketConn {
+	return &fakePacketConn{
+		writes: make(chan []byte, 16),
+		reads:  make(chan fakePacket, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *fakePacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case pkt := <-c.reads:
+		return copy(b, pkt.data), pkt.peer, nil
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (c *fakePacketConn) WriteTo(b []byte, dst net.Addr) (int, error) {
+	c.writes <- append([]byte(nil), b...)
+	return len(b), nil
+}
+
+func (c *fakePacketConn) SetReadDeadline(time.Time) error { return nil }
+
+func (c *fakePacketConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakePacketConn) nextWrite(t *testing.T) *icmp.Echo {
+	t.Helper()
+	select {
+	case b := <-c.writes:
+		rm, err := icmp.ParseMessage(protocolICMP, b)
+		if err != nil {
+			t.Fatalf("prober wrote unparseable ICMP: %v", err)
+		}
+		return rm.Body.(*icmp.Echo)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for prober to send")
+		return nil
+	}
+}
+
+func (c *fakePacketConn) deliver(t *testing.T, msg icmp.Message, peer net.Addr) {
+	t.Helper()
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatalf("failed to marshal fake reply: %v", err)
+	}
+	c.reads <- fakePacket{data: b, peer: peer}
+}
+
+func echoReply(req *icmp.Echo) icmp.Message {
+	return icmp.Message{
+		Type: ipv4.ICMPTypeEchoReply,
+		Body: &icmp.Echo{ID: req.ID, Seq: req.Seq, Data: req.Data},
+	}
+}
+
+// timeExceeded builds the IPv4 Time Exceeded a router sends for req, quoting
+// its IP header and the first 8 bytes of the echo.
+func timeExceeded(t *testing.T, req *icmp.Echo, target *net.IPAddr) icmp.Message {
+	t.Helper()
+	quotedEcho, err := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: req}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := (&ipv4.Header{
+		Version:  ipv4.Version,
+		Len:      ipv4.HeaderLen,
+		TotalLen: ipv4.HeaderLen + len(quotedEcho),
+		TTL:      1,
+		Protocol: protocolICMP,
+		Dst:      target.IP,
+	}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return icmp.Message{
+		Type: ipv4.ICMPTypeTimeExceeded,
+		Body: &icmp.TimeExceeded{Data: append(header, quotedEcho[:8]...)},
+	}
+}
+
+func TestProberMatchesOutOfOrderReplies(t *testing.T) {
+	conn := newFakePacketConn()
+	prober := newICMPProber(&icmpSocket{conn: conn, family: familyV4})
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	const count = 3
+
+	replies := make([]probeReply, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replies[i] = prober.probe(context.Background(), target, []byte(fmt.Sprintf("probe-%d", i)), time.Second)
+		}()
+	}
+
+	requests := make([]*icmp.Echo, count)
+	for i := range requests {
+		requests[i] = conn.nextWrite(t)
+	}
+
+	// Noise a raw socket really sees: another prober's reply, our own
+	// outgoing request looped back, and a packet that isn't ICMP at all.
+	foreign := echoReply(requests[0])
+	foreign.Body.(*icmp.Echo).ID = requests[0].ID ^ 0xffff
+	foreign.Body.(*icmp.Echo).Data = []byte("foreign")
+	conn.deliver(t, foreign, target)
+	conn.deliver(t, icmp.Message{Type: ipv4.ICMPTypeEcho, Body: requests[1]}, target)
+	conn.reads <- fakePacket{data: []byte{0xde, 0xad}, peer: target}
+
+	// Answer in reverse order, spaced out so each probe has a distinct RTT.
+	for i := count - 1; i >= 0; i-- {
+		time.Sleep(15 * time.Millisecond)
+		conn.deliver(t, echoReply(requests[i]), target)
+	}
+	wg.Wait()
+
+	rttBySeq := make(map[int]time.Duration, count)
+	for i, reply := range replies {
+		if reply.err != nil {
+			t.Fatalf("probe %d failed: %v", i, reply.err)
+		}
+		echo := reply.msg.Body.(*icmp.Echo)
+		if want := fmt.Sprintf("probe-%d", i); string(echo.Data) != want {
+			t.Errorf("probe %d got reply carrying %q, want %q", i, echo.Data, want)
+		}
+		rttBySeq[echo.Seq] = reply.rtt
+	}
+
+	// The request answered first must report the shortest RTT.
+	for i := 0; i < count-1; i++ {
+		if rttBySeq[requests[i].Seq] <= rttBySeq[requests[i+1].Seq] {
+			t.Errorf("RTT for seq %d (%v) should exceed seq %d (%v)",
+				requests[i].Seq, rttBySeq[requests[i].Seq], requests[i+1].Seq, rttBySeq[requests[i+1].Seq])
+		}
+	}
+}
+
+func TestProberMatchesTimeExceeded(t *testing.T) {
+	conn := newFakePacketConn()
+	prober := newICMPProber(&icmpSocket{conn: conn, family: familyV4})
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	router := &net.IPAddr{IP: net.ParseIP("198.51.100.1")}
+
+	done := make(chan probeReply, 1)
+	go func() { done <- prober.probe(context.Background(), target, []byte("TRACEROUTE"), time.Second) }()
+	req := conn.nextWrite(t)
+
+	conn.deliver(t, timeExceeded(t, req, target), router)
+
+	reply := <-done
+	if reply.err != nil {
+		t.Fatalf("probe failed: %v", reply.err)
+	}
+	if reply.msg.Type != ipv4.ICMPTypeTimeExceeded {
+		t.Errorf("Type = %v, want time exceeded", reply.msg.Type)
+	}
+	if reply.peer.String() != router.String() {
+		t.Errorf("peer = %v, want %v", reply.peer, router)
+	}
+}
+
+func TestProberMatchesIPv6TimeExceeded(t *testing.T) {
+	conn := newFakePacketConn()
+	prober := newICMPProber(&icmpSocket{conn: conn, family: familyV6})
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+	router := &net.IPAddr{IP: net.ParseIP("2001:db8:ffff::1")}
+
+	done := make(chan probeReply, 1)
+	go func() { done <- prober.probe(context.Background(), target, []byte("TRACEROUTE"), time.Second) }()
+
+	var sent []byte
+	select {
+	case sent = <-conn.writes:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for prober to send")
+	}
+	rm, err := icmp.ParseMessage(protocolICMPv6, sent)
+	if err != nil {
+		t.Fatalf("prober wrote unparseable ICMPv6: %v", err)
+	}
+	if rm.Type != ipv6.ICMPTypeEchoRequest {
+		t.Fatalf("Type = %v, want echo request", rm.Type)
+	}
+
+	// A v6 router quotes a 40-byte header whose next header is ICMPv6.
+	header := make([]byte, ipv6.HeaderLen)
+	header[0] = ipv6.Version << 4
+	header[6] = protocolICMPv6
+	header[7] = 1
+	copy(header[24:40], target.IP)
+
+	conn.deliver(t, icmp.Message{
+		Type: ipv6.ICMPTypeTimeExceeded,
+		Body: &icmp.TimeExceeded{Data: append(header, sent[:8]...)},
+	}, router)
+
+	reply := <-done
+	if reply.err != nil {
+		t.Fatalf("probe failed: %v", reply.err)
+	}
+	if !isTimeExceeded(reply.msg.Type) {
+		t.Errorf("Type = %v, want time exceeded", reply.msg.Type)
+	}
+	if reply.peer.String() != router.String() {
+		t.Errorf("peer = %v, want %v", reply.peer, router)
+	}
+}
+
+func TestProberDropsLateReplies(t *testing.T) {
+	conn := newFakePacketConn()
+	prober := newICMPProber(&icmpSocket{conn: conn, family: familyV4})
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+
+	reply := prober.probe(context.Background(), target, nil, 20*time.Millisecond)
+	if !errors.Is(reply.err, errProbeTimeout) {
+		t.Fatalf("expected timeout, got %+v", reply)
+	}
+	late := conn.nextWrite(t)
+
+	done := make(chan probeReply, 1)
+	go func() { done <- prober.probe(context.Background(), target, []byte("second"), time.Second) }()
+	req := conn.nextWrite(t)
+
+	conn.deliver(t, echoReply(late), target)
+	conn.deliver(t, echoReply(req), target)
+
+	reply = <-done
+	if reply.err != nil {
+		t.Fatalf("second probe failed: %v", reply.err)
+	}
+	if echo := reply.msg.Body.(*icmp.Echo); echo.Seq != req.Seq {
+		t.Errorf("second probe matched seq %d, want %d", echo.Seq, req.Seq)
+	}
+}
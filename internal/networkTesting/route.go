@@ -1,122 +1,228 @@
 package networkTesting
 
 import (
+	"context"
 	"fmt"
 	"net"
-	"os"
+	"net/netip"
+	"strings"
+	"sync"
 	"time"
-
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
 type RouteTestResult struct {
 	Timestamp time.Time  `json:"timestamp"`
 	Target    string     `json:"target"`
+	Family    string     `json:"family"`
+	Protocol  string     `json:"protocol"` // empty on rows stored before UDP/TCP tracing, which were ICMP
+	Port      int        `json:"port,omitempty"`
 	Hops      []RouteHop `json:"hops"`
 	Status    string     `json:"status"`
 	Error     error      `json:"error,omitempty"`
+
+	// IPv6 holds the IPv6 trace when the family setting is "both". The
+	// top-level fields are then the IPv4 trace, and Status covers both.
+	IPv6 *RouteTestResult `json:"ipv6,omitempty"`
 }
 
+// RouteHop summarises every probe sent at one TTL. Address, Hostname and
+// RTT describe the first responder and the mean RTT so older consumers keep
+// working; Responders lists every address that answered, which is more than
+// one when the path load-balances (ECMP).
 type RouteHop struct {
-	Number  int           `json:"hop_number"`
-	Address string        `json:"address"`
-	RTT     time.Duration `json:"rtt"`
-	Lost    bool          `json:"packet_lost"`
+	Number     int              `json:"hop_number"`
+	Address    string           `json:"address"`
+	Hostname   string           `json:"hostname,omitempty"`
+	RTT        time.Duration    `json:"rtt"`
+	RTTs       []time.Duration  `json:"rtts"`
+	Sent       int              `json:"sent"`
+	Loss       float64          `json:"loss"`
+	Lost       bool             `json:"packet_lost"`
+	Responders []RouteResponder `json:"responders,omitempty"`
+}
+
+type RouteResponder struct {
+	Address  string `json:"address"`
+	Hostname string `json:"hostname,omitempty"`
+	ASN      uint32 `json:"asn,omitempty"`
+	ASName   string `json:"as_name,omitempty"`
+	Replies  int    `json:"replies"`
+}
+
+func (t *NetworkTester) RunRouteTest(ctx context.Context) (*RouteTestResult, error) {
+	families, err := icmpFamilies(t.config.Tests.RouteTest.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	var asns *asnTable
+	if path := t.config.Tests.RouteTest.ASNFile; path != "" {
+		// Annotation is optional, so a bad file costs the ASNs, not the trace.
+		if asns, err = loadASNTable(path); err != nil {
+			fmt.Printf("ASN annotation disabled: %v\n", err)
+		}
+	}
+
+	if len(families) == 1 {
+		return t.runRouteFamily(ctx, families[0], asns)
+	}
+
+	v4, v4Err := t.runRouteFamily(ctx, familyV4, asns)
+	if v4 == nil {
+		v4 = &RouteTestResult{Timestamp: time.Now(), Target: t.config.Tests.RouteTest.Target, Family: FamilyV4, Status: "FAILED", Error: v4Err}
+	}
+	if ctx.Err() != nil {
+		return v4, v4Err
+	}
+	v6, v6Err := t.runRouteFamily(ctx, familyV6, asns)
+	if v6 == nil {
+		v6 = &RouteTestResult{Timestamp: time.Now(), Target: t.config.Tests.RouteTest.Target, Family: FamilyV6, Status: "FAILED", Error: v6Err}
+	}
+	v4.IPv6 = v6
+
+	switch {
+	case v4Err != nil && v6Err != nil:
+		return v4, fmt.Errorf("IPv4: %v; IPv6: %v", v4Err, v6Err)
+	case v4Err != nil || v6Err != nil:
+		v4.Status = "PARTIAL"
+	case v6.Status != "SUCCESS":
+		v4.Status = v6.Status
+	}
+	return v4, nil
 }
 
-func (t *NetworkTester) RunRouteTest() (*RouteTestResult, error) {
-	dst, err := net.ResolveIPAddr("ip4", t.config.Tests.RouteTest.Target)
+func (t *NetworkTester) runRouteFamily(ctx context.Context, family *icmpFamily, asns *asnTable) (*RouteTestResult, error) {
+	dst, err := net.ResolveIPAddr(family.network, t.config.Tests.RouteTest.Target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve target IP: %w", err)
 	}
 
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	cfg := t.config.Tests.RouteTest
+	rp, err := newRouteProber(cfg.Protocol, t.config.Tests.ICMP.Mode, family, dst, cfg.Port)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ICMP connection: %w", err)
+		return nil, err
 	}
-	defer conn.Close()
+	defer rp.Close()
 
+	timeout := time.Duration(t.config.Tests.ICMP.TimeoutSeconds) * time.Second
 	result := &RouteTestResult{
 		Timestamp: time.Now(),
 		Target:    t.config.Tests.RouteTest.Target,
+		Family:    family.name,
+		Protocol:  RouteProtocolICMP,
 		Hops:      make([]RouteHop, 0),
 	}
+	if cfg.Protocol == RouteProtocolUDP || cfg.Protocol == RouteProtocolTCP {
+		result.Protocol = cfg.Protocol
+		result.Port = routePort(cfg.Protocol, cfg.Port)
+	}
+
+	probes := t.config.Tests.RouteTest.ProbesPerHop
+	if probes <= 0 {
+		probes = 1
+	}
 
 	maxHops := t.config.Tests.RouteTest.MaxHops
 	for ttl := 1; ttl <= maxHops; ttl++ {
-		hop := probeRouteHop(conn, dst, ttl, t.config.Tests.ICMP.TimeoutSeconds)
+		hop, reached := probeRouteHop(ctx, rp, ttl, probes, timeout)
+		if ctx.Err() != nil {
+			break // A hop cut short would look lost
+		}
 		result.Hops = append(result.Hops, hop)
 
-		if !hop.Lost && hop.Address == dst.String() {
+		if reached {
 			result.Status = "SUCCESS"
-			return result, nil
+			break
 		}
 	}
+	if result.Status == "" {
+		result.Status = "INCOMPLETE"
+	}
 
-	result.Status = "INCOMPLETE"
+	annotateRouteHops(ctx, result.Hops, asns)
 	return result, nil
 }
 
-func probeRouteHop(conn *icmp.PacketConn, dst *net.IPAddr, ttl int, timeout int) RouteHop {
-	hop := RouteHop{
+// probeRouteHop sends every probe for one TTL (hop limit on IPv6) through rp
+// and summarises the replies. Hops are probed one at a time, which the ICMP
+// prober relies on because its probes share a socket-wide TTL. reached
+// reports whether the target itself answered.
+func probeRouteHop(ctx context.Context, rp routeProber, ttl, probes int, timeout time.Duration) (hop RouteHop, reached bool) {
+	hop = RouteHop{
 		Number: ttl,
+		Sent:   probes,
+		Loss:   100,
 		Lost:   true,
+		RTTs:   make([]time.Duration, 0, probes),
 	}
 
 	fmt.Printf("Probing hop %d\n", ttl)
 
-	if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
-		fmt.Printf("SetTTL error: %v\n", err)
-		return hop
-	}
-
-	start := time.Now()
-
-	wm := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   os.Getpid() & 0xffff,
-			Seq:  ttl,
-			Data: []byte("TRACEROUTE"),
-		},
-	}
-
-	wb, err := wm.Marshal(nil)
-	if err != nil {
-		return hop
-	}
-
-	if _, err := conn.WriteTo(wb, dst); err != nil {
-		return hop
+	var total time.Duration
+	for _, reply := range rp.probeHop(ctx, ttl, probes, timeout) {
+		if reply.err != nil {
+			continue
+		}
+		reached = reached || reply.reached
+		hop.RTTs = append(hop.RTTs, reply.rtt)
+		total += reply.rtt
+		hop.addResponder(reply.peer)
 	}
 
-	rb := make([]byte, 1500)
-	if err := conn.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second)); err != nil {
-		return hop
+	if len(hop.RTTs) > 0 {
+		hop.Lost = false
+		hop.Address = hop.Responders[0].Address
+		hop.RTT = total / time.Duration(len(hop.RTTs))
+		hop.Loss = float64(probes-len(hop.RTTs)) / float64(probes) * 100
 	}
+	return hop, reached
+}
 
-	n, peer, err := conn.ReadFrom(rb)
-	if err != nil {
-		return hop
+func (h *RouteHop) addResponder(address string) {
+	for i := range h.Responders {
+		if h.Responders[i].Address == address {
+			h.Responders[i].Replies++
+			return
+		}
 	}
+	h.Responders = append(h.Responders, RouteResponder{Address: address, Replies: 1})
+}
 
-	rm, err := icmp.ParseMessage(1, rb[:n])
-	if err != nil {
-		return hop
+// annotateRouteHops fills in reverse DNS names for every responder, plus its
+// AS when an ASN table is loaded. Lookups run concurrently under one short
+// deadline so a slow resolver can't stall the test by a timeout per hop.
+func annotateRouteHops(ctx context.Context, hops []RouteHop, asns *asnTable) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range hops {
+		for j := range hops[i].Responders {
+			responder := &hops[i].Responders[j]
+
+			if asns != nil {
+				if ip, err := netip.ParseAddr(responder.Address); err == nil {
+					if r, ok := asns.lookup(ip); ok {
+						responder.ASN, responder.ASName = r.asn, r.name
+					}
+				}
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				names, err := net.DefaultResolver.LookupAddr(ctx, responder.Address)
+				if err == nil && len(names) > 0 {
+					responder.Hostname = strings.TrimSuffix(names[0], ".")
+				}
+			}()
+		}
 	}
+	wg.Wait()
 
-	switch rm.Type {
-	case ipv4.ICMPTypeTimeExceeded:
-		hop.Lost = false
-		hop.Address = peer.String()
-		hop.RTT = time.Since(start)
-	case ipv4.ICMPTypeEchoReply:
-		hop.Lost = false
-		hop.Address = dst.String()
-		hop.RTT = time.Since(start)
+	for i := range hops {
+		if len(hops[i].Responders) > 0 {
+			hops[i].Hostname = hops[i].Responders[0].Hostname
+		}
 	}
-
-	return hop
 }
@@ -0,0 +1,158 @@
+package networkTesting
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	RouteProtocolICMP = "icmp"
+	RouteProtocolUDP  = "udp"
+	RouteProtocolTCP  = "tcp"
+)
+
+// Default destination ports: UDP starts at the classic traceroute base and
+// counts up per probe, TCP aims at a port firewalls usually let through.
+const (
+	defaultUDPTracePort = 33434
+	defaultTCPTracePort = 443
+)
+
+// hopReply is the outcome of one traceroute probe, whatever carried it.
+// reached means the target itself answered rather than a router on the way.
+type hopReply struct {
+	peer    string
+	rtt     time.Duration
+	reached bool
+	err     error
+}
+
+// routeProber sends the probes for one hop of a trace.
+type routeProber interface {
+	probeHop(ctx context.Context, ttl, probes int, timeout time.Duration) []hopReply
+	Close() error
+}
+
+// newRouteProber opens a prober for the configured protocol. port is ignored
+// for ICMP and defaults per protocol when zero.
+func newRouteProber(protocol, mode string, family *icmpFamily, dst *net.IPAddr, port int) (routeProber, error) {
+	switch protocol {
+	case "", RouteProtocolICMP:
+		sock, err := listenICMP(mode, family)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ICMP connection: %w", err)
+		}
+		return &icmpRouteProber{sock: sock, prober: newICMPProber(sock), dst: dst}, nil
+	case RouteProtocolUDP, RouteProtocolTCP:
+		return newTransportRouteProber(protocol, family, dst, routePort(protocol, port))
+	default:
+		return nil, fmt.Errorf("unknown route protocol %q, want icmp, udp or tcp", protocol)
+	}
+}
+
+func routePort(protocol string, port int) int {
+	if port > 0 {
+		return port
+	}
+	switch protocol {
+	case RouteProtocolUDP:
+		return defaultUDPTracePort
+	case RouteProtocolTCP:
+		return defaultTCPTracePort
+	}
+	return 0
+}
+
+// icmpRouteProber traces with ICMP echo. Its probes share one socket, so the
+// TTL is set once per hop and hops must be probed one at a time.
+type icmpRouteProber struct {
+	sock   *icmpSocket
+	prober *icmpProber
+	dst    *net.IPAddr
+}
+
+func (p *icmpRouteProber) probeHop(ctx context.Context, ttl, probes int, timeout time.Duration) []hopReply {
+	replies := make([]hopReply, probes)
+	if err := p.sock.setTTL(ttl); err != nil {
+		fmt.Printf("SetTTL error: %v\n", err)
+		for i := range replies {
+			replies[i].err = err
+		}
+		return replies
+	}
+
+	var wg sync.WaitGroup
+	for i := range replies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reply := p.prober.probe(ctx, p.dst, []byte("TRACEROUTE"), timeout)
+			switch {
+			case reply.err != nil:
+				replies[i].err = reply.err
+			case isTimeExceeded(reply.msg.Type):
+				replies[i] = hopReply{peer: reply.peer.String(), rtt: reply.rtt}
+			case isEchoReply(reply.msg.Type):
+				replies[i] = hopReply{peer: p.dst.String(), rtt: reply.rtt, reached: true}
+			default:
+				replies[i].err = fmt.Errorf("unexpected ICMP type %v", reply.msg.Type)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return replies
+}
+
+func (p *icmpRouteProber) Close() error {
+	return p.prober.Close()
+}
+
+// transportRouteProber traces with UDP datagrams or TCP SYNs. Every probe
+// gets its own socket and TTL, so probes never interfere with each other.
+type transportRouteProber struct {
+	protocol string
+	family   *icmpFamily
+	dst      *net.IPAddr
+	port     int
+
+	mu   sync.Mutex
+	sent int // UDP probes so far; each one targets the next port up
+}
+
+// Each probe's own socket deadline bounds it, so ctx is only checked before
+// sending.
+func (p *transportRouteProber) probeHop(ctx context.Context, ttl, probes int, timeout time.Duration) []hopReply {
+	replies := make([]hopReply, probes)
+	if err := ctx.Err(); err != nil {
+		for i := range replies {
+			replies[i].err = err
+		}
+		return replies
+	}
+
+	var wg sync.WaitGroup
+	for i := range replies {
+		port := p.port
+		if p.protocol == RouteProtocolUDP {
+			p.mu.Lock()
+			port += p.sent
+			p.sent++
+			p.mu.Unlock()
+		}
+
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			replies[i] = p.probe(ttl, port, timeout)
+		}(i, port)
+	}
+	wg.Wait()
+	return replies
+}
+
+func (p *transportRouteProber) Close() error {
+	return nil
+}
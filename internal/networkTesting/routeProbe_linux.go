@@ -0,0 +1,147 @@
+//go:build linux
+
+package networkTesting
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// newTransportRouteProber needs no privileges: each probe socket enables
+// IP_RECVERR, so the routers' Time Exceeded replies land on its error queue.
+func newTransportRouteProber(protocol string, family *icmpFamily, dst *net.IPAddr, port int) (routeProber, error) {
+	return &transportRouteProber{protocol: protocol, family: family, dst: dst, port: port}, nil
+}
+
+// probe sends a single UDP datagram or TCP SYN with the given TTL and waits
+// for whatever answers first: an ICMP error on the error queue, a TCP
+// handshake or reset, or a UDP reply from the target.
+func (p *transportRouteProber) probe(ttl, port int, timeout time.Duration) hopReply {
+	domain, sa := syscall.AF_INET, syscall.Sockaddr(nil)
+	level, recvErr, ttlOpt := syscall.IPPROTO_IP, syscall.IP_RECVERR, syscall.IP_TTL
+	if p.family == familyV6 {
+		domain = syscall.AF_INET6
+		level, recvErr, ttlOpt = syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR, syscall.IPV6_UNICAST_HOPS
+		addr := &syscall.SockaddrInet6{Port: port}
+		copy(addr.Addr[:], p.dst.IP.To16())
+		sa = addr
+	} else {
+		addr := &syscall.SockaddrInet4{Port: port}
+		copy(addr.Addr[:], p.dst.IP.To4())
+		sa = addr
+	}
+
+	typ := syscall.SOCK_DGRAM
+	if p.protocol == RouteProtocolTCP {
+		typ = syscall.SOCK_STREAM
+	}
+	fd, err := syscall.Socket(domain, typ|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return hopReply{err: os.NewSyscallError("socket", err)}
+	}
+	f := os.NewFile(uintptr(fd), p.protocol+"-probe")
+	defer f.Close()
+
+	if err := syscall.SetsockoptInt(fd, level, recvErr, 1); err != nil {
+		return hopReply{err: os.NewSyscallError("setsockopt", err)}
+	}
+	if err := syscall.SetsockoptInt(fd, level, ttlOpt, ttl); err != nil {
+		return hopReply{err: os.NewSyscallError("setsockopt", err)}
+	}
+
+	start := time.Now()
+	err = syscall.Connect(fd, sa)
+	if err != nil && !errors.Is(err, syscall.EINPROGRESS) {
+		return hopReply{err: os.NewSyscallError("connect", err)}
+	}
+	if p.protocol == RouteProtocolUDP {
+		if _, err := syscall.Write(fd, []byte("TRACEROUTE")); err != nil {
+			return hopReply{err: os.NewSyscallError("write", err)}
+		}
+	}
+
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return hopReply{err: err}
+	}
+	if err := f.SetDeadline(start.Add(timeout)); err != nil {
+		return hopReply{err: err}
+	}
+
+	var reply hopReply
+	wait := rc.Read
+	if p.protocol == RouteProtocolTCP {
+		// A connecting socket turns writable once the handshake succeeds
+		// or fails, which includes failing on an ICMP error.
+		wait = rc.Write
+	}
+	waitErr := wait(func(fd uintptr) bool {
+		reply = p.checkProbe(int(fd))
+		return reply.peer != "" || reply.err != nil
+	})
+	switch {
+	case errors.Is(waitErr, os.ErrDeadlineExceeded):
+		return hopReply{err: errProbeTimeout}
+	case waitErr != nil:
+		return hopReply{err: waitErr}
+	}
+	reply.rtt = time.Since(start)
+	return reply
+}
+
+// checkProbe reports whether the probe on fd has been answered yet. An
+// empty hopReply means keep waiting.
+func (p *transportRouteProber) checkProbe(fd int) hopReply {
+	oob := make([]byte, 128)
+	for {
+		_, oobLen, _, _, err := syscall.Recvmsg(fd, make([]byte, 64), oob, syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+		if err != nil {
+			break
+		}
+		if _, _, _, offender, ok := parseRecvErr(p.family, oob[:oobLen]); ok && offender != nil {
+			peer := offender.String()
+			// An error from the target itself, typically UDP port
+			// unreachable, means the probe got all the way there.
+			return hopReply{peer: peer, reached: peer == p.dst.String()}
+		}
+	}
+
+	if p.protocol == RouteProtocolTCP {
+		soErr, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+		if err != nil {
+			return hopReply{err: os.NewSyscallError("getsockopt", err)}
+		}
+		switch errno := syscall.Errno(soErr); {
+		case errno == 0:
+			if _, err := syscall.Getpeername(fd); err != nil {
+				return hopReply{} // still connecting
+			}
+			return hopReply{peer: p.dst.String(), reached: true}
+		case errno == syscall.ECONNREFUSED:
+			// A reset still proves the target answered.
+			return hopReply{peer: p.dst.String(), reached: true}
+		default:
+			return hopReply{err: fmt.Errorf("connect: %w", errno)}
+		}
+	}
+
+	// Anything readable on a connected UDP socket came from the target.
+	_, _, err := syscall.Recvfrom(fd, make([]byte, 64), syscall.MSG_DONTWAIT)
+	switch {
+	case err == nil:
+		return hopReply{peer: p.dst.String(), reached: true}
+	case errors.Is(err, syscall.EAGAIN):
+		return hopReply{}
+	case isQueuedICMPErrno(err):
+		// The error arrived after the queue was drained above. It is
+		// still queued, so the socket stays readable and the next pass
+		// picks it up.
+		return hopReply{}
+	default:
+		return hopReply{err: os.NewSyscallError("recvfrom", err)}
+	}
+}
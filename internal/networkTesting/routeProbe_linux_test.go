@@ -0,0 +1,68 @@
+//go:build linux
+
+package networkTesting
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTransportRouteProberTCPLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	open := ln.Addr().(*net.TCPAddr).Port
+
+	// A closed port answers with a reset, which still reaches the target.
+	closedLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := closedLn.Addr().(*net.TCPAddr).Port
+	closedLn.Close()
+
+	dst := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	for _, port := range []int{open, closed} {
+		rp, err := newRouteProber(RouteProtocolTCP, "", familyV4, dst, port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies := rp.probeHop(context.Background(), 1, 2, time.Second)
+		rp.Close()
+
+		for i, reply := range replies {
+			if reply.err != nil {
+				t.Fatalf("port %d probe %d failed: %v", port, i, reply.err)
+			}
+			if !reply.reached || reply.peer != "127.0.0.1" || reply.rtt <= 0 {
+				t.Errorf("port %d probe %d = %+v, want reached from 127.0.0.1", port, i, reply)
+			}
+		}
+	}
+}
+
+func TestTransportRouteProberUDPPortUnreachable(t *testing.T) {
+	dst := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	rp, err := newRouteProber(RouteProtocolUDP, "", familyV4, dst, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rp.Close()
+
+	replies := rp.probeHop(context.Background(), 1, 3, time.Second)
+	for i, reply := range replies {
+		if reply.err != nil {
+			t.Fatalf("probe %d failed: %v", i, reply.err)
+		}
+		if !reply.reached || reply.peer != "127.0.0.1" {
+			t.Errorf("probe %d = %+v, want port unreachable from 127.0.0.1", i, reply)
+		}
+	}
+	if sent := rp.(*transportRouteProber).sent; sent != 3 {
+		t.Errorf("sent = %d, want each probe on its own port", sent)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package networkTesting
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// newTransportRouteProber is Linux-only: elsewhere a UDP or TCP socket can't
+// see the ICMP errors its probes trigger without a raw socket to read them.
+func newTransportRouteProber(protocol string, family *icmpFamily, dst *net.IPAddr, port int) (routeProber, error) {
+	return nil, errors.New("UDP and TCP route probes are only supported on Linux")
+}
+
+func (p *transportRouteProber) probe(ttl, port int, timeout time.Duration) hopReply {
+	return hopReply{err: errors.New("UDP and TCP route probes are only supported on Linux")}
+}
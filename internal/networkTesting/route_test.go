@@ -1,7 +1,10 @@
 package networkTesting
 
 import (
+	"context"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/oshaw1/go-net-test/config"
 )
@@ -20,7 +23,7 @@ func TestRouteTest(t *testing.T) {
 	}
 	tester := NewNetworkTester(cfg)
 
-	result, err := tester.RunRouteTest()
+	result, err := tester.RunRouteTest(context.Background())
 	if err != nil {
 		t.Fatalf("runRouteTest returned unexpected error: %v", err)
 	}
@@ -51,3 +54,86 @@ func TestRouteTest(t *testing.T) {
 		t.Errorf("Invalid status: %v", result.Status)
 	}
 }
+
+func TestProbeRouteHopECMPAndLoss(t *testing.T) {
+	conn := newFakePacketConn()
+	var ttls []int
+	sock := &icmpSocket{
+		conn:   conn,
+		family: familyV4,
+		setTTL: func(ttl int) error { ttls = append(ttls, ttl); return nil },
+	}
+	prober := newICMPProber(sock)
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	routerA := &net.IPAddr{IP: net.ParseIP("198.51.100.1")}
+	routerB := &net.IPAddr{IP: net.ParseIP("198.51.100.2")}
+
+	type hopResult struct {
+		hop     RouteHop
+		reached bool
+	}
+	done := make(chan hopResult, 1)
+	go func() {
+		hop, reached := probeRouteHop(context.Background(), &icmpRouteProber{sock: sock, prober: prober, dst: target}, 4, 3, 200*time.Millisecond)
+		done <- hopResult{hop, reached}
+	}()
+
+	// Two load-balanced routers answer; the third probe is lost.
+	conn.deliver(t, timeExceeded(t, conn.nextWrite(t), target), routerA)
+	conn.deliver(t, timeExceeded(t, conn.nextWrite(t), target), routerB)
+	conn.nextWrite(t)
+
+	res := <-done
+	hop := res.hop
+	if res.reached {
+		t.Error("intermediate hop reported as reaching the target")
+	}
+	if len(ttls) != 1 || ttls[0] != 4 {
+		t.Errorf("setTTL calls = %v, want [4]", ttls)
+	}
+	if hop.Number != 4 || hop.Sent != 3 || hop.Lost {
+		t.Errorf("hop = %+v, want number 4, 3 sent, not lost", hop)
+	}
+	if len(hop.RTTs) != 2 {
+		t.Errorf("got %d RTTs, want 2", len(hop.RTTs))
+	}
+	if hop.Loss < 33 || hop.Loss > 34 {
+		t.Errorf("Loss = %.1f%%, want 33.3%%", hop.Loss)
+	}
+	if len(hop.Responders) != 2 {
+		t.Fatalf("got %d responders, want 2: %+v", len(hop.Responders), hop.Responders)
+	}
+	if hop.Address != hop.Responders[0].Address {
+		t.Errorf("Address = %s, want first responder %s", hop.Address, hop.Responders[0].Address)
+	}
+}
+
+func TestProbeRouteHopReachesTarget(t *testing.T) {
+	conn := newFakePacketConn()
+	sock := &icmpSocket{conn: conn, family: familyV4, setTTL: func(int) error { return nil }}
+	prober := newICMPProber(sock)
+	defer prober.Close()
+
+	target := &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	done := make(chan bool, 1)
+	go func() {
+		hop, reached := probeRouteHop(context.Background(), &icmpRouteProber{sock: sock, prober: prober, dst: target}, 7, 2, time.Second)
+		done <- reached && hop.Loss == 0 && hop.Address == target.String()
+	}()
+
+	conn.deliver(t, echoReply(conn.nextWrite(t)), target)
+	conn.deliver(t, echoReply(conn.nextWrite(t)), target)
+
+	if !<-done {
+		t.Error("expected the hop to reach the target with no loss")
+	}
+}
+
+func TestNewRouteProberUnknownProtocol(t *testing.T) {
+	dst := &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	if _, err := newRouteProber("sctp", "", familyV4, dst, 0); err == nil {
+		t.Error("expected error for unknown route protocol")
+	}
+}
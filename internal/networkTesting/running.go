@@ -0,0 +1,195 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoSuchTest is returned when cancelling a test that isn't queued or
+// running.
+var ErrNoSuchTest = errors.New("no running test with that id")
+
+// DefaultMaxConcurrentTests is how many tests run at once when no limit is
+// configured.
+const DefaultMaxConcurrentTests = 2
+
+// exclusiveTests fill the link on their own, so no two of them run at
+// once; their results would only measure each other. Loaded latency is
+// here too, as it saturates the link to load it.
+var exclusiveTests = map[string]bool{
+	"bandwidth":      true,
+	"download":       true,
+	"upload":         true,
+	"loaded-latency": true,
+}
+
+// IsExclusiveTest reports whether testType never runs alongside another
+// exclusive test.
+func IsExclusiveTest(testType string) bool {
+	return exclusiveTests[testType]
+}
+
+// Test states shown in RunningTest.State.
+const (
+	TestQueued  = "queued"
+	TestRunning = "running"
+)
+
+// RunningTest describes a test that is queued or running.
+type RunningTest struct {
+	ID        int64      `json:"id"`
+	TestType  string     `json:"test_type"`
+	Source    string     `json:"source,omitempty"` // Who asked for it, e.g. "manual" or a scheduled task's name
+	Run       string     `json:"run,omitempty"`    // Token the caller started it with, to find this exact test again
+	State     string     `json:"state"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+type runningEntry struct {
+	test    RunningTest
+	cancel  context.CancelFunc
+	started chan struct{} // closed once the test may run
+}
+
+// RunningTests queues tests and tracks them by ID so they can be listed and
+// cancelled from another request. At most limit tests run at once, and
+// exclusive tests one at a time; the rest wait their turn in the order
+// they arrived.
+type RunningTests struct {
+	mu        sync.Mutex
+	nextID    int64
+	limit     int
+	tests     map[int64]*runningEntry
+	queue     []*runningEntry
+	running   int
+	exclusive bool // an exclusive test is running
+}
+
+// NewRunningTests returns a registry running up to limit tests at once, or
+// DefaultMaxConcurrentTests if limit isn't positive.
+func NewRunningTests(limit int) *RunningTests {
+	if limit <= 0 {
+		limit = DefaultMaxConcurrentTests
+	}
+	return &RunningTests{limit: limit, tests: make(map[int64]*runningEntry)}
+}
+
+// Start queues a test and waits for its turn, returning the context it
+// should run under. done must be called once the test has finished. If the
+// test is cancelled, or parent done, before its turn comes, Start returns
+// ErrTestCancelled and the test shouldn't run. run, if set, is listed with
+// the test so a caller that can't see the ID can still pick out its own.
+func (r *RunningTests) Start(parent context.Context, testType, source, run string) (ctx context.Context, id int64, done func(), err error) {
+	ctx, cancel := context.WithCancel(parent)
+
+	r.mu.Lock()
+	r.nextID++
+	id = r.nextID
+	entry := &runningEntry{
+		test:    RunningTest{ID: id, TestType: testType, Source: source, Run: run, State: TestQueued, QueuedAt: time.Now()},
+		cancel:  cancel,
+		started: make(chan struct{}),
+	}
+	r.tests[id] = entry
+	r.queue = append(r.queue, entry)
+	r.dispatch()
+	r.mu.Unlock()
+
+	done = func() {
+		r.mu.Lock()
+		r.finish(entry)
+		r.mu.Unlock()
+		cancel()
+	}
+
+	select {
+	case <-entry.started:
+		return ctx, id, done, nil
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	if entry.test.State == TestRunning {
+		// Its turn came as it was cancelled; let it run and wind down.
+		r.mu.Unlock()
+		return ctx, id, done, nil
+	}
+	r.finish(entry)
+	r.mu.Unlock()
+	cancel()
+	return nil, 0, nil, ErrTestCancelled
+}
+
+// dispatch starts every queued test that now has a turn, oldest first.
+// r.mu must be held.
+func (r *RunningTests) dispatch() {
+	waiting := r.queue[:0]
+	for _, entry := range r.queue {
+		exclusive := exclusiveTests[entry.test.TestType]
+		if r.running >= r.limit || (exclusive && r.exclusive) {
+			waiting = append(waiting, entry)
+			continue
+		}
+
+		now := time.Now()
+		entry.test.State = TestRunning
+		entry.test.StartedAt = &now
+		r.running++
+		if exclusive {
+			r.exclusive = true
+		}
+		close(entry.started)
+	}
+	clear(r.queue[len(waiting):])
+	r.queue = waiting
+}
+
+// finish removes a test, freeing its turn if it had one. r.mu must be held.
+func (r *RunningTests) finish(entry *runningEntry) {
+	delete(r.tests, entry.test.ID)
+	if entry.test.State == TestRunning {
+		r.running--
+		if exclusiveTests[entry.test.TestType] {
+			r.exclusive = false
+		}
+	} else {
+		for i, queued := range r.queue {
+			if queued == entry {
+				r.queue = append(r.queue[:i], r.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	r.dispatch()
+}
+
+// Cancel stops the test with the given ID, or takes it out of the queue if
+// it hasn't started. A running test keeps its registry entry until it has
+// wound down and called done.
+func (r *RunningTests) Cancel(id int64) error {
+	r.mu.Lock()
+	entry, ok := r.tests[id]
+	r.mu.Unlock()
+	if !ok {
+		return ErrNoSuchTest
+	}
+	entry.cancel()
+	return nil
+}
+
+// List returns the queued and running tests, oldest first.
+func (r *RunningTests) List() []RunningTest {
+	r.mu.Lock()
+	tests := make([]RunningTest, 0, len(r.tests))
+	for _, entry := range r.tests {
+		tests = append(tests, entry.test)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(tests, func(i, j int) bool { return tests[i].ID < tests[j].ID })
+	return tests
+}
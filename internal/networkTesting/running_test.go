@@ -0,0 +1,180 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func TestRunningTests(t *testing.T) {
+	running := NewRunningTests(0)
+
+	ctx1, id1, done1, err := running.Start(context.Background(), "bandwidth", "manual", "a1")
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	_, id2, done2, err := running.Start(context.Background(), "dns", "nightly dns", "")
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer done2()
+
+	list := running.List()
+	if len(list) != 2 || list[0].ID != id1 || list[1].ID != id2 {
+		t.Fatalf("List() = %+v, want ids %d and %d in order", list, id1, id2)
+	}
+	if list[0].TestType != "bandwidth" || list[0].State != TestRunning || list[0].StartedAt == nil || list[0].Source != "manual" || list[0].Run != "a1" {
+		t.Errorf("List()[0] = %+v, want a running manual bandwidth test from run a1", list[0])
+	}
+
+	if err := running.Cancel(id1); err != nil {
+		t.Fatalf("Cancel(%d) returned error: %v", id1, err)
+	}
+	if !errors.Is(ctx1.Err(), context.Canceled) {
+		t.Errorf("ctx.Err() = %v after Cancel, want context.Canceled", ctx1.Err())
+	}
+	if len(running.List()) != 2 {
+		t.Error("a cancelled test should stay listed until it calls done")
+	}
+
+	done1()
+	if list := running.List(); len(list) != 1 || list[0].ID != id2 {
+		t.Errorf("List() = %+v after done, want only id %d", list, id2)
+	}
+	if err := running.Cancel(id1); !errors.Is(err, ErrNoSuchTest) {
+		t.Errorf("Cancel of a finished test returned %v, want ErrNoSuchTest", err)
+	}
+}
+
+// startAsync calls Start in the background, as a test waiting its turn
+// would block.
+func startAsync(running *RunningTests, ctx context.Context, testType string) <-chan func() {
+	started := make(chan func(), 1)
+	go func() {
+		_, _, done, err := running.Start(ctx, testType, "", "")
+		if err != nil {
+			close(started)
+			return
+		}
+		started <- done
+	}()
+	return started
+}
+
+func waitForState(t *testing.T, running *RunningTests, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		list := running.List()
+		got := make([]string, len(list))
+		for i, test := range list {
+			got[i] = test.TestType + ":" + test.State
+		}
+		if slices.Equal(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("List() states = %v, want %v", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRunningTestsQueue(t *testing.T) {
+	running := NewRunningTests(2)
+
+	download := startAsync(running, context.Background(), "download")
+	waitForState(t, running, "download:running")
+
+	upload := startAsync(running, context.Background(), "upload")
+	waitForState(t, running, "download:running", "upload:queued")
+	dns := startAsync(running, context.Background(), "dns")
+	waitForState(t, running, "download:running", "upload:queued", "dns:running")
+
+	// The limit of two is reached, so even a non-exclusive test waits.
+	icmp := startAsync(running, context.Background(), "icmp")
+	waitForState(t, running, "download:running", "upload:queued", "dns:running", "icmp:queued")
+
+	// The upload is first in line but still can't overlap the download.
+	(<-dns)()
+	waitForState(t, running, "download:running", "upload:queued", "icmp:running")
+
+	(<-download)()
+	waitForState(t, running, "upload:running", "icmp:running")
+	(<-upload)()
+	(<-icmp)()
+	waitForState(t, running)
+}
+
+func TestRunningTestsCancelQueued(t *testing.T) {
+	running := NewRunningTests(1)
+
+	_, _, done, err := running.Start(context.Background(), "bandwidth", "", "")
+	if err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	defer done()
+
+	queued := startAsync(running, context.Background(), "download")
+	waitForState(t, running, "bandwidth:running", "download:queued")
+
+	list := running.List()
+	if err := running.Cancel(list[1].ID); err != nil {
+		t.Fatalf("Cancel of a queued test returned error: %v", err)
+	}
+	if _, ok := <-queued; ok {
+		t.Error("a test cancelled while queued shouldn't start")
+	}
+	waitForState(t, running, "bandwidth:running")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued = startAsync(running, ctx, "dns")
+	waitForState(t, running, "bandwidth:running", "dns:queued")
+	cancel()
+	if _, ok := <-queued; ok {
+		t.Error("a test whose context ends while queued shouldn't start")
+	}
+	waitForState(t, running, "bandwidth:running")
+}
+
+func TestRunTestCancelled(t *testing.T) {
+	server := newSpeedTestServer(t, DefaultSpeedTestMaxBytes)
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			SpeedTestURLs: config.SpeedTestURLs{
+				DownloadURLs:     []string{server.URL + "/speedtest/download?size=4MB"},
+				Mode:             "duration",
+				Streams:          2,
+				DurationSeconds:  10,
+				SampleIntervalMs: 100,
+			},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(350*time.Millisecond, cancel)
+
+	start := time.Now()
+	result, err := tester.RunTest(ctx, "download")
+	if !errors.Is(err, ErrTestCancelled) {
+		t.Fatalf("RunTest returned %v, want ErrTestCancelled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %v to stop after cancel", elapsed)
+	}
+
+	speed, ok := result.(*AverageSpeedTestResult)
+	if !ok {
+		t.Fatalf("result = %T, want the partial *AverageSpeedTestResult", result)
+	}
+	if speed.Status != StatusCancelled {
+		t.Errorf("Status = %q, want %q", speed.Status, StatusCancelled)
+	}
+	if speed.BytesReceived == 0 {
+		t.Error("Expected the bytes measured before the cancel to be kept")
+	}
+}
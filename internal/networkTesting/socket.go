@@ -0,0 +1,137 @@
+package networkTesting
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	ICMPModeAuto = "auto"
+	ICMPModeRaw  = "raw"
+	ICMPModeUDP  = "udp"
+)
+
+const (
+	FamilyV4   = "v4"
+	FamilyV6   = "v6"
+	FamilyBoth = "both"
+)
+
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// icmpFamily collects everything that differs between ICMP and ICMPv6 so
+// the prober and tests can stay family-agnostic.
+type icmpFamily struct {
+	name            string
+	network         string // for net.ResolveIPAddr
+	proto           int
+	echoRequest     icmp.Type
+	echoReply       icmp.Type
+	quotedHeaderLen int // fixed header length of the IP packet quoted in an error
+}
+
+var (
+	familyV4 = &icmpFamily{
+		name:            FamilyV4,
+		network:         "ip4",
+		proto:           protocolICMP,
+		echoRequest:     ipv4.ICMPTypeEcho,
+		echoReply:       ipv4.ICMPTypeEchoReply,
+		quotedHeaderLen: ipv4.HeaderLen,
+	}
+	familyV6 = &icmpFamily{
+		name:            FamilyV6,
+		network:         "ip6",
+		proto:           protocolICMPv6,
+		echoRequest:     ipv6.ICMPTypeEchoRequest,
+		echoReply:       ipv6.ICMPTypeEchoReply,
+		quotedHeaderLen: ipv6.HeaderLen,
+	}
+)
+
+// icmpFamilies expands a family setting into the families to test, in the
+// order they should run. An empty setting means IPv4 only.
+func icmpFamilies(setting string) ([]*icmpFamily, error) {
+	switch setting {
+	case "", FamilyV4:
+		return []*icmpFamily{familyV4}, nil
+	case FamilyV6:
+		return []*icmpFamily{familyV6}, nil
+	case FamilyBoth:
+		return []*icmpFamily{familyV4, familyV6}, nil
+	default:
+		return nil, fmt.Errorf("unknown address family %q, want v4, v6 or both", setting)
+	}
+}
+
+// icmpSocket is an open ICMP endpoint plus what callers need to know about
+// it. Datagram sockets are the unprivileged Linux "ping" sockets: the kernel
+// owns the echo ID and only delivers this socket's replies.
+type icmpSocket struct {
+	conn     packetConn
+	family   *icmpFamily
+	setTTL   func(int) error // hop limit on IPv6
+	datagram bool
+	raw      syscall.RawConn // for socket options x/net doesn't wrap; nil if unavailable
+}
+
+// listenICMP opens an ICMP socket for the configured mode and family. Auto
+// prefers a raw socket, which sees every ICMP error, and falls back to a
+// datagram socket when the process isn't allowed to open one.
+func listenICMP(mode string, family *icmpFamily) (*icmpSocket, error) {
+	switch mode {
+	case ICMPModeRaw:
+		return listenRawICMP(family)
+	case ICMPModeUDP:
+		return listenDatagramICMP(family)
+	case "", ICMPModeAuto:
+		sock, rawErr := listenRawICMP(family)
+		if rawErr == nil {
+			return sock, nil
+		}
+		sock, udpErr := listenDatagramICMP(family)
+		if udpErr == nil {
+			return sock, nil
+		}
+		return nil, fmt.Errorf("no usable ICMP socket (raw: %v; datagram: %v)", rawErr, udpErr)
+	default:
+		return nil, fmt.Errorf("unknown ICMP mode %q, want auto, raw or udp", mode)
+	}
+}
+
+func listenRawICMP(family *icmpFamily) (*icmpSocket, error) {
+	if family == familyV6 {
+		c, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+		if err != nil {
+			return nil, err
+		}
+		return &icmpSocket{conn: c, family: family, setTTL: c.IPv6PacketConn().SetHopLimit, raw: syscallConn(c.IPv6PacketConn().PacketConn)}, nil
+	}
+
+	c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, err
+	}
+	return &icmpSocket{conn: c, family: family, setTTL: c.IPv4PacketConn().SetTTL, raw: syscallConn(c.IPv4PacketConn().PacketConn)}, nil
+}
+
+// syscallConn digs the raw descriptor out of a connection, or returns nil
+// if it has none.
+func syscallConn(c any) syscall.RawConn {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	return raw
+}
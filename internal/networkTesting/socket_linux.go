@@ -0,0 +1,246 @@
+//go:build linux
+
+package networkTesting
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// SO_EE_ORIGIN_ICMP and SO_EE_ORIGIN_ICMP6 from linux/errqueue.h.
+const (
+	soEEOriginICMP  = 2
+	soEEOriginICMP6 = 3
+)
+
+// listenDatagramICMP opens an unprivileged ping socket. The process's group
+// must fall inside net.ipv4.ping_group_range (which also governs IPv6).
+// Linux never delivers ICMP errors to a ping socket as ordinary reads, so
+// IP_RECVERR is enabled and errQueueConn reads them from the error queue
+// instead; without that the route test would never see a Time Exceeded.
+func listenDatagramICMP(family *icmpFamily) (*icmpSocket, error) {
+	domain, proto, level, opt := syscall.AF_INET, syscall.IPPROTO_ICMP, syscall.IPPROTO_IP, syscall.IP_RECVERR
+	var addr syscall.Sockaddr = &syscall.SockaddrInet4{}
+	if family == familyV6 {
+		domain, proto, level, opt = syscall.AF_INET6, syscall.IPPROTO_ICMPV6, syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR
+		addr = &syscall.SockaddrInet6{}
+	}
+
+	fd, err := syscall.Socket(domain, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.SetsockoptInt(fd, level, opt, 1); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "icmp-datagram")
+	pc, err := net.FilePacketConn(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	udp, ok := pc.(*net.UDPConn)
+	if !ok {
+		pc.Close()
+		return nil, fmt.Errorf("unexpected datagram ICMP socket type %T", pc)
+	}
+	raw, err := udp.SyscallConn()
+	if err != nil {
+		udp.Close()
+		return nil, err
+	}
+
+	sock := &icmpSocket{
+		conn:     &errQueueConn{UDPConn: udp, raw: raw, family: family},
+		family:   family,
+		setTTL:   ipv4.NewPacketConn(udp).SetTTL,
+		datagram: true,
+		raw:      raw,
+	}
+	if family == familyV6 {
+		sock.setTTL = ipv6.NewPacketConn(udp).SetHopLimit
+	}
+	return sock, nil
+}
+
+// errQueueConn is a ping socket whose reads return queued ICMP errors as
+// well as echo replies. Errors are rebuilt into ordinary ICMP messages
+// quoting the original echo, so the prober matches them the same way it
+// does on a raw socket.
+type errQueueConn struct {
+	*net.UDPConn
+	raw    syscall.RawConn
+	family *icmpFamily
+}
+
+func (c *errQueueConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	var n int
+	var peer net.Addr
+	var readErr error
+	oob := make([]byte, 128)
+
+	err := c.raw.Read(func(fd uintptr) bool {
+		for {
+			for {
+				payloadLen, oobLen, _, _, err := syscall.Recvmsg(int(fd), b, oob, syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+				if err != nil {
+					break
+				}
+				if msg, from, ok := icmpFromErrQueue(c.family, b[:payloadLen], oob[:oobLen]); ok {
+					n, peer = copy(b, msg), from
+					return true
+				}
+			}
+
+			var from syscall.Sockaddr
+			var err error
+			n, from, err = syscall.Recvfrom(int(fd), b, syscall.MSG_DONTWAIT)
+			switch {
+			case err == nil:
+				switch sa := from.(type) {
+				case *syscall.SockaddrInet4:
+					peer = &net.IPAddr{IP: net.IP(append([]byte(nil), sa.Addr[:]...))}
+				case *syscall.SockaddrInet6:
+					peer = &net.IPAddr{IP: net.IP(append([]byte(nil), sa.Addr[:]...))}
+				}
+				return true
+			case errors.Is(err, syscall.EAGAIN):
+				return false
+			case errors.Is(err, syscall.EINTR), isQueuedICMPErrno(err):
+				// A pending socket error is reported once by recvfrom and
+				// the details are on the error queue; go round again.
+				continue
+			default:
+				readErr = os.NewSyscallError("recvfrom", err)
+				return true
+			}
+		}
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if readErr != nil {
+		return 0, nil, readErr
+	}
+	return n, peer, nil
+}
+
+func isQueuedICMPErrno(err error) bool {
+	for _, errno := range []syscall.Errno{
+		syscall.EHOSTUNREACH, syscall.ENETUNREACH, syscall.ECONNREFUSED,
+		syscall.EPROTO, syscall.EMSGSIZE, syscall.ETIMEDOUT,
+	} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// icmpFromErrQueue turns one error queue entry into the ICMP message the
+// router actually sent: type and code from the sock_extended_err, the
+// offender address as the peer, and the echo we sent quoted behind a
+// minimal IP header.
+func icmpFromErrQueue(family *icmpFamily, payload, oob []byte) ([]byte, net.Addr, bool) {
+	typ, code, info, offender, ok := parseRecvErr(family, oob)
+	if !ok {
+		return nil, nil, false
+	}
+
+	quoted := payload
+	if len(quoted) > 8 {
+		quoted = quoted[:8]
+	}
+	headerLen := family.quotedHeaderLen
+	msg := make([]byte, 8+headerLen, 8+headerLen+len(quoted))
+	msg[0], msg[1] = typ, code
+	// ee_info carries the next-hop MTU for "fragmentation needed" and
+	// "packet too big", which both keep it in the low bytes of this word.
+	binary.BigEndian.PutUint32(msg[4:8], info)
+	if family == familyV6 {
+		msg[8] = ipv6.Version << 4
+		msg[8+6] = protocolICMPv6
+	} else {
+		msg[8] = ipv4.Version<<4 | ipv4.HeaderLen>>2
+		msg[8+9] = protocolICMP
+	}
+	return append(msg, quoted...), offender, true
+}
+
+// parseRecvErr finds the IP_RECVERR (IPV6_RECVERR) control message in oob
+// and returns the ICMP type, code and extra info word it carries and the
+// address of the router that sent it. Errors raised locally rather than by
+// ICMP are skipped.
+func parseRecvErr(family *icmpFamily, oob []byte) (typ, code byte, info uint32, offender net.Addr, ok bool) {
+	cmsgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, 0, 0, nil, false
+	}
+
+	level, cmsgType, origin := syscall.IPPROTO_IP, syscall.IP_RECVERR, byte(soEEOriginICMP)
+	if family == familyV6 {
+		level, cmsgType, origin = syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR, soEEOriginICMP6
+	}
+
+	for _, cmsg := range cmsgs {
+		if int(cmsg.Header.Level) != level || int(cmsg.Header.Type) != cmsgType {
+			continue
+		}
+		// struct sock_extended_err is 16 bytes, followed by the offender's
+		// sockaddr_in or sockaddr_in6.
+		data := cmsg.Data
+		if len(data) < 16 || data[4] != origin {
+			continue
+		}
+
+		if len(data) >= 18 {
+			switch binary.NativeEndian.Uint16(data[16:18]) {
+			case syscall.AF_INET:
+				if len(data) >= 24 {
+					offender = &net.IPAddr{IP: net.IP(append([]byte(nil), data[20:24]...))}
+				}
+			case syscall.AF_INET6:
+				if len(data) >= 40 {
+					offender = &net.IPAddr{IP: net.IP(append([]byte(nil), data[24:40]...))}
+				}
+			}
+		}
+		return data[5], data[6], binary.NativeEndian.Uint32(data[8:12]), offender, true
+	}
+
+	return 0, 0, 0, nil, false
+}
+
+// setDontFragment makes every echo leave with DF set (IPv6 never fragments
+// in transit anyway) and stops the kernel refusing sizes above its cached
+// path MTU, so the MTU test can probe past what the host already believes.
+func setDontFragment(sock *icmpSocket) error {
+	if sock.raw == nil {
+		return errors.New("socket does not expose its descriptor")
+	}
+	level, opt, val := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE
+	if sock.family == familyV6 {
+		level, opt, val = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE
+	}
+
+	var sockErr error
+	if err := sock.raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), level, opt, val)
+	}); err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", sockErr)
+}
@@ -0,0 +1,179 @@
+//go:build linux
+
+package networkTesting
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// recvErrCmsg builds the control message Linux attaches to an error queue
+// entry: a sock_extended_err followed by the offender's sockaddr_in, or
+// sockaddr_in6 when offender is an IPv6 address.
+func recvErrCmsg(origin, icmpType, icmpCode byte, offender net.IP) []byte {
+	level, typ := syscall.IPPROTO_IP, syscall.IP_RECVERR
+	data := make([]byte, 16+16)
+	if offender.To4() != nil {
+		binary.NativeEndian.PutUint16(data[16:18], syscall.AF_INET)
+		copy(data[20:24], offender.To4())
+	} else {
+		level, typ = syscall.IPPROTO_IPV6, syscall.IPV6_RECVERR
+		data = make([]byte, 16+28)
+		binary.NativeEndian.PutUint16(data[16:18], syscall.AF_INET6)
+		copy(data[24:40], offender)
+	}
+	data[4], data[5], data[6] = origin, icmpType, icmpCode
+
+	b := make([]byte, syscall.CmsgSpace(len(data)))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = int32(level)
+	h.Type = int32(typ)
+	h.SetLen(syscall.CmsgLen(len(data)))
+	copy(b[syscall.CmsgLen(0):], data)
+	return b
+}
+
+func TestICMPFromErrQueue(t *testing.T) {
+	echo, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: 7, Seq: 42, Data: []byte("TRACEROUTE")},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := net.ParseIP("198.51.100.1")
+
+	msg, peer, ok := icmpFromErrQueue(familyV4, echo, recvErrCmsg(soEEOriginICMP, 11, 0, router))
+	if !ok {
+		t.Fatal("expected ICMP error to be rebuilt")
+	}
+	if peer.String() != router.String() {
+		t.Errorf("peer = %v, want %v", peer, router)
+	}
+
+	rm, err := icmp.ParseMessage(protocolICMP, msg)
+	if err != nil {
+		t.Fatalf("rebuilt message does not parse: %v", err)
+	}
+	if rm.Type != ipv4.ICMPTypeTimeExceeded {
+		t.Errorf("Type = %v, want time exceeded", rm.Type)
+	}
+	id, seq, ok := familyV4.echoIdentity(rm)
+	if !ok || id != 7 || seq != 42 {
+		t.Errorf("echoIdentity = %d/%d/%v, want 7/42/true", id, seq, ok)
+	}
+
+	// Local errors (origin SO_EE_ORIGIN_LOCAL) aren't from the network.
+	if _, _, ok := icmpFromErrQueue(familyV4, echo, recvErrCmsg(1, 11, 0, router)); ok {
+		t.Error("expected non-ICMP origin to be ignored")
+	}
+}
+
+func TestICMPFromErrQueueIPv6(t *testing.T) {
+	echo, err := (&icmp.Message{
+		Type: ipv6.ICMPTypeEchoRequest,
+		Body: &icmp.Echo{ID: 7, Seq: 42, Data: []byte("TRACEROUTE")},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := net.ParseIP("2001:db8:ffff::1")
+
+	msg, peer, ok := icmpFromErrQueue(familyV6, echo, recvErrCmsg(soEEOriginICMP6, 3, 0, router))
+	if !ok {
+		t.Fatal("expected ICMPv6 error to be rebuilt")
+	}
+	if peer.String() != router.String() {
+		t.Errorf("peer = %v, want %v", peer, router)
+	}
+
+	rm, err := icmp.ParseMessage(protocolICMPv6, msg)
+	if err != nil {
+		t.Fatalf("rebuilt message does not parse: %v", err)
+	}
+	if rm.Type != ipv6.ICMPTypeTimeExceeded {
+		t.Errorf("Type = %v, want time exceeded", rm.Type)
+	}
+	id, seq, ok := familyV6.echoIdentity(rm)
+	if !ok || id != 7 || seq != 42 {
+		t.Errorf("echoIdentity = %d/%d/%v, want 7/42/true", id, seq, ok)
+	}
+
+	// An IPv4 origin on a v6 socket isn't one of ours.
+	if _, _, ok := icmpFromErrQueue(familyV6, echo, recvErrCmsg(soEEOriginICMP, 3, 0, router)); ok {
+		t.Error("expected ICMPv4 origin to be ignored")
+	}
+}
+
+func TestICMPFromErrQueueFragNeeded(t *testing.T) {
+	echo, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: 7, Seq: 42, Data: make([]byte, 1472)},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oob := recvErrCmsg(soEEOriginICMP, 3, icmpCodeFragNeeded, net.ParseIP("198.51.100.1"))
+	// ee_info follows ee_errno and the four single-byte fields.
+	binary.NativeEndian.PutUint32(oob[syscall.CmsgLen(0)+8:], 1400)
+
+	msg, _, ok := icmpFromErrQueue(familyV4, echo, oob)
+	if !ok {
+		t.Fatal("expected ICMP error to be rebuilt")
+	}
+	rm, err := icmp.ParseMessage(protocolICMP, msg)
+	if err != nil {
+		t.Fatalf("rebuilt message does not parse: %v", err)
+	}
+	if got := nextHopMTU(rm, msg); got != 1400 {
+		t.Errorf("nextHopMTU = %d, want 1400", got)
+	}
+	if id, seq, ok := familyV4.echoIdentity(rm); !ok || id != 7 || seq != 42 {
+		t.Errorf("echoIdentity = %d/%d/%v, want 7/42/true", id, seq, ok)
+	}
+}
+
+func TestDatagramICMPLoopback(t *testing.T) {
+	for _, tt := range []struct {
+		family *icmpFamily
+		addr   string
+	}{
+		{familyV4, "127.0.0.1"},
+		{familyV6, "::1"},
+	} {
+		t.Run(tt.family.name, func(t *testing.T) {
+			sock, err := listenICMP(ICMPModeUDP, tt.family)
+			if err != nil {
+				t.Skipf("unprivileged ICMP sockets unavailable (check net.ipv4.ping_group_range): %v", err)
+			}
+			if !sock.datagram {
+				t.Fatal("udp mode returned a raw socket")
+			}
+			prober := newICMPProber(sock)
+			defer prober.Close()
+
+			dst := &net.IPAddr{IP: net.ParseIP(tt.addr)}
+			for i := 0; i < 3; i++ {
+				reply := prober.probe(context.Background(), dst, []byte("Latency"), time.Second)
+				if reply.err != nil {
+					t.Fatalf("probe %d failed: %v", i, reply.err)
+				}
+				if reply.msg.Type != tt.family.echoReply {
+					t.Errorf("probe %d: Type = %v, want echo reply", i, reply.msg.Type)
+				}
+				if reply.peer.String() != tt.addr {
+					t.Errorf("probe %d: peer = %v, want %s", i, reply.peer, tt.addr)
+				}
+			}
+		})
+	}
+}
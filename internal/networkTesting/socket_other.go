@@ -0,0 +1,34 @@
+//go:build !linux
+
+package networkTesting
+
+import (
+	"errors"
+
+	"golang.org/x/net/icmp"
+)
+
+// listenDatagramICMP opens a plain datagram ICMP socket. Outside Linux there
+// is no error queue to read, so Time Exceeded replies never reach us and the
+// route test only sees the final hop in this mode.
+func listenDatagramICMP(family *icmpFamily) (*icmpSocket, error) {
+	if family == familyV6 {
+		c, err := icmp.ListenPacket("udp6", "::")
+		if err != nil {
+			return nil, err
+		}
+		return &icmpSocket{conn: c, family: family, setTTL: c.IPv6PacketConn().SetHopLimit, datagram: true}, nil
+	}
+
+	c, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		return nil, err
+	}
+	return &icmpSocket{conn: c, family: family, setTTL: c.IPv4PacketConn().SetTTL, datagram: true}, nil
+}
+
+// setDontFragment is Linux-only for now; other platforms spell the DF and
+// path MTU probing options differently, if they have them at all.
+func setDontFragment(sock *icmpSocket) error {
+	return errors.New("setting DF on ICMP probes is only supported on Linux")
+}
@@ -0,0 +1,41 @@
+package networkTesting
+
+import "testing"
+
+func TestListenICMPUnknownMode(t *testing.T) {
+	if _, err := listenICMP("bogus", familyV4); err == nil {
+		t.Error("expected error for unknown ICMP mode")
+	}
+}
+
+func TestICMPFamilies(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    []*icmpFamily
+	}{
+		{"", []*icmpFamily{familyV4}},
+		{FamilyV4, []*icmpFamily{familyV4}},
+		{FamilyV6, []*icmpFamily{familyV6}},
+		{FamilyBoth, []*icmpFamily{familyV4, familyV6}},
+	}
+	for _, tt := range tests {
+		got, err := icmpFamilies(tt.setting)
+		if err != nil {
+			t.Errorf("icmpFamilies(%q) returned error: %v", tt.setting, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("icmpFamilies(%q) = %d families, want %d", tt.setting, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("icmpFamilies(%q)[%d] = %s, want %s", tt.setting, i, got[i].name, tt.want[i].name)
+			}
+		}
+	}
+
+	if _, err := icmpFamilies("ipx"); err == nil {
+		t.Error("expected error for unknown address family")
+	}
+}
@@ -2,10 +2,11 @@ package networkTesting
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,40 +20,78 @@ type AverageSpeedTestResult struct {
 	Error         error
 }
 
+// SpeedTestResult is one URL's share of a speed test. In duration mode
+// Speed is the steady-state rate, Samples holds the rate in every
+// SampleInterval of the run and the first WarmupSamples of them were left
+// out of Speed.
 type SpeedTestResult struct {
 	Speed    float64
 	Status   string
 	Duration time.Duration
 	Bytes    int64
+
+	Streams        int           `json:",omitempty"`
+	SampleInterval time.Duration `json:",omitempty"`
+	WarmupSamples  int           `json:",omitempty"`
+	Samples        []float64     `json:",omitempty"`
+}
+
+// Sampled reports whether any URL was measured in duration mode, and so
+// has a throughput time series.
+func (r *AverageSpeedTestResult) Sampled() bool {
+	for _, test := range r.TestedURLs {
+		if len(test.Samples) > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 type speedTest struct {
 	urls    []string
-	measure func(string) AverageSpeedTestResult
+	measure func(context.Context, string) AverageSpeedTestResult
 }
 
-func (t *NetworkTester) MeasureDownloadSpeed() (*AverageSpeedTestResult, error) {
-	return t.runSpeedTest(speedTest{
+func (t *NetworkTester) MeasureDownloadSpeed(ctx context.Context) (*AverageSpeedTestResult, error) {
+	measure := t.measureSingleDownload
+	if t.config.Tests.SpeedTestURLs.Mode == "duration" {
+		measure = t.measureTimedDownload
+	}
+
+	return t.runSpeedTest(ctx, speedTest{
 		urls:    t.config.Tests.SpeedTestURLs.DownloadURLs,
-		measure: t.measureSingleDownload,
+		measure: measure,
 	}, "download")
 }
 
-func (t *NetworkTester) MeasureUploadSpeed() (*AverageSpeedTestResult, error) {
-	data := make([]byte, 10*1024*1024) // 10MB
-	if _, err := rand.Read(data); err != nil {
+func (t *NetworkTester) MeasureUploadSpeed(ctx context.Context) (*AverageSpeedTestResult, error) {
+	block, err := newPayloadBlock()
+	if err != nil {
 		return nil, fmt.Errorf("failed to generate test data: %v", err)
 	}
+	size := int64(t.config.Tests.SpeedTestURLs.UploadSizeMB) * 1024 * 1024
+	if size <= 0 {
+		size = 10 * 1024 * 1024
+	}
 
-	return t.runSpeedTest(speedTest{
-		urls: t.config.Tests.SpeedTestURLs.UploadURLs,
-		measure: func(url string) AverageSpeedTestResult {
-			return t.measureSingleUpload(url, data)
-		},
+	measure := func(ctx context.Context, url string) AverageSpeedTestResult {
+		return t.measureSingleUpload(ctx, url, block, size)
+	}
+	if t.config.Tests.SpeedTestURLs.Mode == "duration" {
+		measure = func(ctx context.Context, url string) AverageSpeedTestResult {
+			return t.measureTimedUpload(ctx, url, block, size)
+		}
+	}
+
+	return t.runSpeedTest(ctx, speedTest{
+		urls:    t.config.Tests.SpeedTestURLs.UploadURLs,
+		measure: measure,
 	}, "upload")
 }
 
-func (t *NetworkTester) runSpeedTest(test speedTest, testType string) (*AverageSpeedTestResult, error) {
+// runSpeedTest measures each URL in turn. A URL cut short by ctx is left
+// out, along with those not reached.
+func (t *NetworkTester) runSpeedTest(ctx context.Context, test speedTest, testType string) (*AverageSpeedTestResult, error) {
 	var totalSpeed float64
 	var totalBytes int64
 	var totalTime time.Duration
@@ -62,7 +101,10 @@ func (t *NetworkTester) runSpeedTest(test speedTest, testType string) (*AverageS
 	testedURLs := make(map[string]SpeedTestResult)
 
 	for _, url := range test.urls {
-		result := test.measure(url)
+		result := test.measure(ctx, url)
+		if ctx.Err() != nil && result.Error != nil {
+			break
+		}
 
 		if result.Error != nil {
 			lastError = result.Error
@@ -78,11 +120,15 @@ func (t *NetworkTester) runSpeedTest(test speedTest, testType string) (*AverageS
 	}
 
 	if successfulTests == 0 {
+		err := lastError
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrAllURLsFailed, lastError)
+		}
 		return &AverageSpeedTestResult{
 			TestedURLs: testedURLs,
 			Status:     fmt.Sprintf("All %s tests failed", testType),
 			Error:      lastError,
-		}, lastError
+		}, err
 	}
 
 	avgSpeed := totalSpeed / float64(successfulTests)
@@ -132,13 +178,14 @@ func createSpeedTestResult(url string, speedMbps float64, elapsed time.Duration,
 	}
 }
 
-func (t *NetworkTester) measureSingleDownload(url string) AverageSpeedTestResult {
+func (t *NetworkTester) measureSingleDownload(ctx context.Context, url string) AverageSpeedTestResult {
 	start := time.Now()
 
 	client, req, err := t.setupClient(url, nil, "GET")
 	if err != nil {
 		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - Request Creation Error")
 	}
+	req = req.WithContext(ctx)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -152,7 +199,7 @@ func (t *NetworkTester) measureSingleDownload(url string) AverageSpeedTestResult
 	}
 
 	elapsed := time.Since(start)
-	speedMbps := float64(bytes*8) / (1024 * 1024) / elapsed.Seconds()
+	speedMbps := megabitsPerSecond(bytes, elapsed)
 
 	return createSpeedTestResult(url, speedMbps, elapsed, bytes, nil, "%s",
 		fmt.Sprintf("%.2f Mbps (%s downloaded in %s)",
@@ -161,39 +208,26 @@ func (t *NetworkTester) measureSingleDownload(url string) AverageSpeedTestResult
 			formatDuration(elapsed)))
 }
 
-func (t *NetworkTester) measureSingleUpload(url string, data []byte) AverageSpeedTestResult {
-	start := time.Now()
+// measureSingleUpload times one size-byte upload from the request starting
+// to the last body byte reaching the socket, leaving out however long the
+// server takes to process it and answer.
+func (t *NetworkTester) measureSingleUpload(ctx context.Context, url string, block []byte, size int64) AverageSpeedTestResult {
+	var written atomic.Int64
+	stream := newUploadStream(&written)
+	defer stream.close()
 
-	client, req, err := t.setupClient(url, data, "POST")
-	if err != nil {
-		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - Request Creation Error")
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - Connection Error")
-	}
-	defer resp.Body.Close()
-
-	_, err = io.Copy(io.Discard, resp.Body)
-	if err != nil {
-		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - Response Error")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return createSpeedTestResult(url, 0, 0, 0,
-			fmt.Errorf("upload failed with status: %d", resp.StatusCode),
-			"FAILED - HTTP %d", resp.StatusCode)
+	start := time.Now()
+	if err := stream.upload(ctx, url, block, size); err != nil {
+		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - %v", err)
 	}
 
-	elapsed := time.Since(start)
-	bytes := int64(len(data))
-	speedMbps := float64(bytes*8) / (1024 * 1024) / elapsed.Seconds()
+	elapsed := stream.lastWriteTime().Sub(start)
+	speedMbps := megabitsPerSecond(size, elapsed)
 
-	return createSpeedTestResult(url, speedMbps, elapsed, bytes, nil, "%s",
+	return createSpeedTestResult(url, speedMbps, elapsed, size, nil, "%s",
 		fmt.Sprintf("%.2f Mbps (%s uploaded in %s)",
 			speedMbps,
-			formatBytes(bytes),
+			formatBytes(size),
 			formatDuration(elapsed)))
 }
 
@@ -0,0 +1,146 @@
+package networkTesting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSpeedTestMaxBytes caps a single download or upload so one
+	// request can't tie a server up indefinitely.
+	DefaultSpeedTestMaxBytes = 1024 * 1024 * 1024
+
+	defaultSpeedTestDownloadBytes = 10 * 1024 * 1024
+)
+
+// SpeedTestServer makes a GoNetTest instance a target for other instances'
+// download, upload and bandwidth tests. Downloads are generated on the fly
+// from a random block, so nothing is stored and compression on the path
+// gains nothing; uploads are read and discarded.
+type SpeedTestServer struct {
+	maxBytes int64
+	payload  []byte
+}
+
+// SpeedTestUploadReport is what the upload sink answers with once it has
+// read the whole body. Mbps is in decimal megabits, as the upload test
+// reports in either mode, so the two sides of a transfer can be compared.
+type SpeedTestUploadReport struct {
+	Bytes   int64         `json:"bytes"`
+	Elapsed time.Duration `json:"elapsed"`
+	Mbps    float64       `json:"mbps"`
+}
+
+func NewSpeedTestServer(maxBytes int64) (*SpeedTestServer, error) {
+	payload, err := newPayloadBlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate download payload: %w", err)
+	}
+
+	return &SpeedTestServer{
+		maxBytes: maxBytes,
+		payload:  payload,
+	}, nil
+}
+
+// Handler serves the download and upload endpoints on their own, for the
+// standalone speed-test server.
+func (s *SpeedTestServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/speedtest/download", s.ServeDownload)
+	mux.HandleFunc("/speedtest/upload", s.ServeUpload)
+	return mux
+}
+
+// ServeDownload sends ?size= bytes (10MB when omitted), where size is a
+// byte count with an optional KB, MB or GB suffix.
+func (s *SpeedTestServer) ServeDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	size := int64(defaultSpeedTestDownloadBytes)
+	if param := r.URL.Query().Get("size"); param != "" {
+		var err error
+		if size, err = parseByteSize(param); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if size > s.maxBytes {
+		http.Error(w, fmt.Sprintf("size is over the %s limit", formatBytes(s.maxBytes)), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	for remaining := size; remaining > 0; {
+		chunk := s.payload[:min(remaining, int64(len(s.payload)))]
+		n, err := w.Write(chunk)
+		if err != nil {
+			return // Client went away
+		}
+		remaining -= int64(n)
+	}
+}
+
+// ServeUpload reads and discards the request body, then reports how much
+// arrived and how fast.
+func (s *SpeedTestServer) ServeUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, http.MaxBytesReader(w, r.Body, s.maxBytes))
+	elapsed := time.Since(start)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("upload failed after %s: %v", formatBytes(n), err), http.StatusBadRequest)
+		return
+	}
+
+	report := SpeedTestUploadReport{Bytes: n, Elapsed: elapsed, Mbps: megabitsPerSecond(n, elapsed)}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseByteSize reads sizes like "500", "64KB", "100MB" or "1GB". Units are
+// binary, matching how results are displayed.
+func parseByteSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"B", 1},
+	}
+
+	value, scale := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/scale {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * scale, nil
+}
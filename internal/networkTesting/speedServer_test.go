@@ -0,0 +1,185 @@
+package networkTesting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func newSpeedTestServer(t *testing.T, maxBytes int64) *httptest.Server {
+	s, err := NewSpeedTestServer(maxBytes)
+	if err != nil {
+		t.Fatalf("NewSpeedTestServer failed: %v", err)
+	}
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSpeedTestServerDownload(t *testing.T) {
+	server := newSpeedTestServer(t, 8*1024*1024)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantBytes  int64
+	}{
+		{"?size=3MB", http.StatusOK, 3 * 1024 * 1024},
+		{"?size=1500", http.StatusOK, 1500},
+		{"?size=9MB", http.StatusBadRequest, -1},
+		{"?size=lots", http.StatusBadRequest, -1},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + "/speedtest/download" + tt.query)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tt.query, err)
+		}
+		n, _ := io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("GET %s: status %d, want %d", tt.query, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantBytes >= 0 && (n != tt.wantBytes || resp.ContentLength != tt.wantBytes) {
+			t.Errorf("GET %s: read %d bytes with Content-Length %d, want %d", tt.query, n, resp.ContentLength, tt.wantBytes)
+		}
+	}
+}
+
+func TestSpeedTestServerUpload(t *testing.T) {
+	server := newSpeedTestServer(t, 4*1024*1024)
+
+	resp, err := http.Post(server.URL+"/speedtest/upload", "application/octet-stream", bytes.NewReader(make([]byte, 3*1024*1024)))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var report SpeedTestUploadReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode upload report: %v", err)
+	}
+	if report.Bytes != 3*1024*1024 {
+		t.Errorf("report.Bytes = %d, want %d", report.Bytes, 3*1024*1024)
+	}
+	if want := megabitsPerSecond(report.Bytes, report.Elapsed); report.Mbps != want {
+		t.Errorf("report.Mbps = %v, want %v in the same SI megabits as client results", report.Mbps, want)
+	}
+
+	resp, err = http.Post(server.URL+"/speedtest/upload", "application/octet-stream", bytes.NewReader(make([]byte, 5*1024*1024)))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Oversized upload got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestSpeedTestsAgainstSpeedTestServer(t *testing.T) {
+	server := newSpeedTestServer(t, DefaultSpeedTestMaxBytes)
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			SpeedTestURLs: config.SpeedTestURLs{
+				DownloadURLs: []string{
+					server.URL + "/speedtest/download?size=8MB",
+					server.URL + "/speedtest/download?size=4MB",
+				},
+				UploadURLs: []string{server.URL + "/speedtest/upload"},
+			},
+		},
+	})
+
+	download, err := tester.MeasureDownloadSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("MeasureDownloadSpeed returned error: %v", err)
+	}
+	if download.BytesReceived != 6*1024*1024 {
+		t.Errorf("BytesReceived = %d, want the 6MB average of 8MB and 4MB", download.BytesReceived)
+	}
+	if download.AverageMbps <= 0 {
+		t.Errorf("AverageMbps = %v, want > 0", download.AverageMbps)
+	}
+
+	upload, err := tester.MeasureUploadSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("MeasureUploadSpeed returned error: %v", err)
+	}
+	if upload.BytesReceived != 10*1024*1024 || upload.AverageMbps <= 0 {
+		t.Errorf("upload = %d bytes at %.2f Mbps, want 10MB at > 0", upload.BytesReceived, upload.AverageMbps)
+	}
+}
+
+func TestFileModeDownloadReportsDecimalMbps(t *testing.T) {
+	server := newSpeedTestServer(t, DefaultSpeedTestMaxBytes)
+	url := server.URL + "/speedtest/download?size=4MB"
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			SpeedTestURLs: config.SpeedTestURLs{DownloadURLs: []string{url}, Mode: "file"},
+		},
+	})
+
+	download, err := tester.MeasureDownloadSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("MeasureDownloadSpeed returned error: %v", err)
+	}
+	// The same decimal megabits duration mode reports, so switching mode
+	// doesn't move the history.
+	tested := download.TestedURLs[url]
+	if want := megabitsPerSecond(tested.Bytes, tested.Duration); math.Abs(tested.Speed-want) > 1e-9*want {
+		t.Errorf("Speed = %v Mbps, want %v for %d bytes in %v", tested.Speed, want, tested.Bytes, tested.Duration)
+	}
+}
+
+func TestFileModeUploadReportsDecimalMbps(t *testing.T) {
+	server := newSpeedTestServer(t, DefaultSpeedTestMaxBytes)
+	url := server.URL + "/speedtest/upload"
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			SpeedTestURLs: config.SpeedTestURLs{UploadURLs: []string{url}, UploadSizeMB: 2, Mode: "file"},
+		},
+	})
+
+	upload, err := tester.MeasureUploadSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("MeasureUploadSpeed returned error: %v", err)
+	}
+	tested := upload.TestedURLs[url]
+	if want := megabitsPerSecond(tested.Bytes, tested.Duration); math.Abs(tested.Speed-want) > 1e-9*want {
+		t.Errorf("Speed = %v Mbps, want %v for %d bytes in %v", tested.Speed, want, tested.Bytes, tested.Duration)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"500", 500, false},
+		{"500B", 500, false},
+		{"64KB", 64 * 1024, false},
+		{"100mb", 100 * 1024 * 1024, false},
+		{" 2 GB ", 2 * 1024 * 1024 * 1024, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"-1MB", 0, true},
+		{"1.5MB", 0, true},
+		{"99999999999GB", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package networkTesting
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/oshaw1/go-net-test/config"
@@ -19,7 +23,7 @@ func TestMeasureDownloadSpeed(t *testing.T) {
 	}
 	tester := NewNetworkTester(cfg)
 
-	result, err := tester.MeasureDownloadSpeed()
+	result, err := tester.MeasureDownloadSpeed(context.Background())
 	if err != nil {
 		t.Fatalf("MeasureDownloadSpeed returned unexpected error: %v", err)
 	}
@@ -66,7 +70,7 @@ func TestMeasureUploadSpeed(t *testing.T) {
 	}
 	tester := NewNetworkTester(cfg)
 
-	result, err := tester.MeasureUploadSpeed()
+	result, err := tester.MeasureUploadSpeed(context.Background())
 	if err != nil {
 		t.Fatalf("MeasureUploadSpeed returned unexpected error: %v", err)
 	}
@@ -99,3 +103,35 @@ func TestMeasureUploadSpeed(t *testing.T) {
 		t.Error("Expected at least one successful speed test")
 	}
 }
+
+func TestSpeedTestAllURLsFailed(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	closedURL := server.URL + "/speedtest/download"
+	server.Close()
+
+	for _, mode := range []string{"", "duration"} {
+		cfg := &config.Config{
+			Tests: config.TestConfigs{
+				SpeedTestURLs: config.SpeedTestURLs{
+					Mode:            mode,
+					DownloadURLs:    []string{closedURL},
+					UploadURLs:      []string{closedURL},
+					UploadSizeMB:    1,
+					DurationSeconds: 1,
+				},
+			},
+		}
+		tester := NewNetworkTester(cfg)
+
+		_, downloadErr := tester.MeasureDownloadSpeed(context.Background())
+		_, uploadErr := tester.MeasureUploadSpeed(context.Background())
+		for name, err := range map[string]error{"download": downloadErr, "upload": uploadErr} {
+			if !errors.Is(err, ErrAllURLsFailed) {
+				t.Errorf("%s (mode %q): expected ErrAllURLsFailed, got %v", name, mode, err)
+			}
+			if !IsNetworkError(err) {
+				t.Errorf("%s (mode %q): expected a network error, got %v", name, mode, err)
+			}
+		}
+	}
+}
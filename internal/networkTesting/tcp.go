@@ -0,0 +1,132 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+type TCPTestResult struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Targets   []TCPTargetResult `json:"targets"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type TCPTargetResult struct {
+	Target       string          `json:"target"`
+	Attempts     int             `json:"attempts"`
+	Connected    int             `json:"connected"`
+	Refused      int             `json:"refused"`
+	Timeouts     int             `json:"timeouts"`
+	Errors       int             `json:"errors"`
+	MinTime      time.Duration   `json:"min_time"`
+	AvgTime      time.Duration   `json:"avg_time"`
+	MaxTime      time.Duration   `json:"max_time"`
+	P95Time      time.Duration   `json:"p95_time"`
+	ConnectTimes []time.Duration `json:"connect_times"`
+	LastError    string          `json:"last_error,omitempty"`
+}
+
+func (t *NetworkTester) RunTCPTest(ctx context.Context) (*TCPTestResult, error) {
+	cfg := t.config.Tests.TCP
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("no TCP targets configured")
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	result := &TCPTestResult{
+		Timestamp: time.Now(),
+		Targets:   make([]TCPTargetResult, 0, len(cfg.Targets)),
+	}
+
+	connected, failed := 0, 0
+	for _, target := range cfg.Targets {
+		if ctx.Err() != nil {
+			break
+		}
+		targetResult := measureTCPConnect(ctx, target, cfg.Count, timeout)
+		connected += targetResult.Connected
+		failed += targetResult.Attempts - targetResult.Connected
+		result.Targets = append(result.Targets, targetResult)
+	}
+
+	switch {
+	case connected == 0:
+		result.Status = "FAILED"
+		result.Error = "no TCP connections succeeded"
+		return result, errors.New(result.Error)
+	case failed > 0:
+		result.Status = "PARTIAL"
+	default:
+		result.Status = "SUCCESS"
+	}
+
+	return result, nil
+}
+
+// measureTCPConnect stops early if ctx is done, leaving the attempts made so
+// far.
+func measureTCPConnect(ctx context.Context, target string, count int, timeout time.Duration) TCPTargetResult {
+	result := TCPTargetResult{
+		Target:       target,
+		ConnectTimes: make([]time.Duration, 0, count),
+	}
+
+	var total time.Duration
+	dialer := &net.Dialer{Timeout: timeout}
+	for i := 0; i < count; i++ {
+		if i > 0 && !sleepContext(ctx, 50*time.Millisecond) { // Space out attempts
+			break
+		}
+
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", target)
+		elapsed := time.Since(start)
+		if ctx.Err() != nil {
+			if err == nil {
+				conn.Close()
+			}
+			break
+		}
+		result.Attempts++
+		if err != nil {
+			switch {
+			case errors.Is(err, syscall.ECONNREFUSED):
+				result.Refused++
+			case isTimeout(err):
+				result.Timeouts++
+			default:
+				result.Errors++
+			}
+			result.LastError = err.Error()
+			continue
+		}
+		conn.Close()
+
+		result.Connected++
+		result.ConnectTimes = append(result.ConnectTimes, elapsed)
+		total += elapsed
+		if elapsed < result.MinTime || result.MinTime == 0 {
+			result.MinTime = elapsed
+		}
+		if elapsed > result.MaxTime {
+			result.MaxTime = elapsed
+		}
+	}
+
+	if result.Connected > 0 {
+		result.AvgTime = total / time.Duration(result.Connected)
+		result.P95Time = percentile(result.ConnectTimes, 95)
+	}
+
+	return result
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,85 @@
+package networkTesting
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func TestRunTCPTest(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// Grab a free port and release it so connecting to it is refused.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	cfg := &config.Config{
+		Tests: config.TestConfigs{
+			TCP: config.TCPConfig{
+				Targets:        []string{listener.Addr().String(), closedAddr},
+				Count:          3,
+				TimeoutSeconds: 1,
+			},
+		},
+	}
+	tester := NewNetworkTester(cfg)
+
+	result, err := tester.RunTCPTest(context.Background())
+	if err != nil {
+		t.Fatalf("RunTCPTest returned unexpected error: %v", err)
+	}
+
+	if len(result.Targets) != 2 {
+		t.Fatalf("Expected 2 target results, got %d", len(result.Targets))
+	}
+	if result.Status != "PARTIAL" {
+		t.Errorf("Status = %q with one target refusing, want PARTIAL", result.Status)
+	}
+
+	open := result.Targets[0]
+	if open.Attempts != 3 || open.Connected != 3 {
+		t.Errorf("Open target attempts/connected = %d/%d, want 3/3", open.Attempts, open.Connected)
+	}
+	if open.MinTime <= 0 || open.MinTime > open.AvgTime || open.AvgTime > open.MaxTime {
+		t.Errorf("Invalid connect times: min=%v avg=%v max=%v", open.MinTime, open.AvgTime, open.MaxTime)
+	}
+	if open.P95Time < open.MinTime || open.P95Time > open.MaxTime {
+		t.Errorf("P95 %v outside [%v, %v]", open.P95Time, open.MinTime, open.MaxTime)
+	}
+
+	refused := result.Targets[1]
+	if refused.Connected != 0 || refused.Refused != 3 {
+		t.Errorf("Closed target connected/refused = %d/%d, want 0/3", refused.Connected, refused.Refused)
+	}
+	if refused.LastError == "" {
+		t.Error("Expected closed target to record an error")
+	}
+}
+
+func TestRunTCPTestNoTargets(t *testing.T) {
+	tester := NewNetworkTester(&config.Config{})
+
+	if _, err := tester.RunTCPTest(context.Background()); err == nil {
+		t.Error("Expected error with no targets configured")
+	}
+}
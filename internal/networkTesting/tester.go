@@ -1,7 +1,10 @@
 package networkTesting
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 
 	"github.com/oshaw1/go-net-test/config"
 )
@@ -17,39 +20,126 @@ func NewNetworkTester(config *config.Config) *NetworkTester {
 }
 
 type TestResult struct {
-	ICMP      *ICMPTestResult         `json:"ICMP,omitempty"`
-	Download  *AverageSpeedTestResult `json:"Download,omitempty"`
-	Upload    *AverageSpeedTestResult `json:"Upload,omitempty"`
-	Route     *RouteTestResult        `json:"Route,omitempty"`
-	Latency   *LatencyTestResult      `json:"Jitter,omitempty"`
-	Bandwidth *BandwidthTestResult    `json:"Bandwidth,omitempty"`
+	ICMP          *MultiHostICMPResult     `json:"ICMP,omitempty"`
+	Download      *AverageSpeedTestResult  `json:"Download,omitempty"`
+	Upload        *AverageSpeedTestResult  `json:"Upload,omitempty"`
+	Route         *RouteTestResult         `json:"Route,omitempty"`
+	Latency       *LatencyTestResult       `json:"Jitter,omitempty"`
+	Bandwidth     *BandwidthTestResult     `json:"Bandwidth,omitempty"`
+	DNS           *DNSTestResult           `json:"DNS,omitempty"`
+	TCP           *TCPTestResult           `json:"TCP,omitempty"`
+	HTTP          *HTTPTestResult          `json:"HTTP,omitempty"`
+	TLS           *TLSTestResult           `json:"TLS,omitempty"`
+	MTU           *MTUTestResult           `json:"MTU,omitempty"`
+	LoadedLatency *LoadedLatencyTestResult `json:"LoadedLatency,omitempty"`
+	UDP           *UDPTestResult           `json:"UDP,omitempty"`
 }
 
-func (t *NetworkTester) RunTest(testType string) (any, error) {
+// RunTest runs one test until it finishes or ctx is cancelled. A cancelled
+// test returns what it measured so far with its status set to CANCELLED,
+// along with ErrTestCancelled.
+func (t *NetworkTester) RunTest(ctx context.Context, testType string) (any, error) {
 
 	var result any
 	var err error
 
 	switch testType {
 	case "icmp":
-		result, err = t.runICMPTest()
+		result, err = asResult(t.runICMPTest(ctx))
 	case "download":
-		result, err = t.MeasureDownloadSpeed()
+		result, err = asResult(t.MeasureDownloadSpeed(ctx))
 	case "upload":
-		result, err = t.MeasureUploadSpeed()
+		result, err = asResult(t.MeasureUploadSpeed(ctx))
 	case "route":
-		result, err = t.RunRouteTest()
+		result, err = asResult(t.RunRouteTest(ctx))
 	case "latency":
-		result, err = t.RunLatencyTest()
+		result, err = asResult(t.RunLatencyTest(ctx))
 	case "bandwidth":
-		result, err = t.RunBandwidthTest()
+		result, err = asResult(t.RunBandwidthTest(ctx))
+	case "dns":
+		result, err = asResult(t.RunDNSTest(ctx))
+	case "tcp":
+		result, err = asResult(t.RunTCPTest(ctx))
+	case "http":
+		result, err = asResult(t.RunHTTPTest(ctx))
+	case "tls":
+		result, err = asResult(t.RunTLSTest(ctx))
+	case "mtu":
+		result, err = asResult(t.RunMTUTest(ctx))
+	case "loaded-latency":
+		result, err = asResult(t.RunLoadedLatencyTest(ctx))
+	case "udp":
+		result, err = asResult(t.RunUDPTest(ctx))
 	default:
 		err = fmt.Errorf("unsupported test type: %s", testType)
 	}
 
+	if errors.Is(ctx.Err(), context.Canceled) {
+		if result != nil {
+			markCancelled(result)
+		}
+		return result, ErrTestCancelled
+	}
 	if err != nil {
 		return result, err
 	}
 
 	return result, nil
 }
+
+// ErrTestCancelled is returned by RunTest when its context was cancelled.
+var ErrTestCancelled = errors.New("test cancelled")
+
+// ErrAllURLsFailed is wrapped around the last error of a download or upload
+// test in which no URL could be measured.
+var ErrAllURLsFailed = errors.New("all URLs failed")
+
+// IsNetworkError reports whether err came from the network itself, such as
+// a refused connection, a failed DNS lookup or a timeout, rather than from
+// a bad response or setting.
+func IsNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// asResult keeps a test that returned no result from handing back a
+// non-nil any holding a nil pointer.
+func asResult[T any](result *T, err error) (any, error) {
+	if result == nil {
+		return nil, err
+	}
+	return result, err
+}
+
+// markCancelled sets a cancelled run's status so it can't be mistaken for
+// one that finished.
+func markCancelled(result any) {
+	switch r := result.(type) {
+	case *MultiHostICMPResult:
+		r.Status = StatusCancelled
+	case *AverageSpeedTestResult:
+		r.Status = StatusCancelled
+	case *RouteTestResult:
+		r.Status = StatusCancelled
+	case *LatencyTestResult:
+		r.Status = StatusCancelled
+	case *BandwidthTestResult:
+		r.Status = StatusCancelled
+	case *DNSTestResult:
+		r.Status = StatusCancelled
+	case *TCPTestResult:
+		r.Status = StatusCancelled
+	case *HTTPTestResult:
+		r.Status = StatusCancelled
+	case *TLSTestResult:
+		r.Status = StatusCancelled
+	case *MTUTestResult:
+		r.Status = StatusCancelled
+	case *LoadedLatencyTestResult:
+		r.Status = StatusCancelled
+	case *UDPTestResult:
+		r.Status = StatusCancelled
+	}
+}
+
+const StatusCancelled = "CANCELLED"
@@ -0,0 +1,239 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transferSample is what a timed transfer moved during one sampling
+// interval, in total and by stream.
+type transferSample struct {
+	bytes       int64
+	streamBytes []int64
+	elapsed     time.Duration
+}
+
+// timedTransfer is the outcome of runTimedTransfer: the samples, and the
+// streams that stopped with an error before the time was up.
+type timedTransfer struct {
+	samples    []transferSample
+	streams    int
+	failed     int
+	firstErr   error
+	streamErrs []error
+}
+
+type streamError struct {
+	stream int
+	err    error
+}
+
+// errEmptyTransfer stops a stream whose transfer finished without moving
+// any data, such as a 200 with an empty body, rather than restarting it in
+// a tight loop for the rest of the run.
+var errEmptyTransfer = errors.New("transfer finished without moving any data")
+
+// runTimedTransfer keeps streams copies of transfer running for duration, or
+// until ctx is done, restarting any that finish early, and samples the bytes
+// moved by each of them every interval. Each call is told which stream it
+// is, so a stream can hold on to its own connection. A stream fails if a
+// transfer moves nothing, and runTimedTransfer returns early if every
+// stream fails.
+func runTimedTransfer(ctx context.Context, streams int, duration, interval time.Duration, transfer func(ctx context.Context, stream int, counted *atomic.Int64) error) timedTransfer {
+	streams = max(streams, 1)
+	result := timedTransfer{streams: streams, streamErrs: make([]error, streams)}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	counted := make([]atomic.Int64, streams)
+	errs := make(chan streamError, streams)
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				before := counted[i].Load()
+				err := transfer(ctx, i, &counted[i])
+				if err == nil && counted[i].Load() == before {
+					err = errEmptyTransfer
+				}
+				if err != nil && ctx.Err() == nil {
+					errs <- streamError{stream: i, err: err}
+					return
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last, lastBytes := time.Now(), make([]int64, streams)
+
+	takeSample := func(now time.Time) {
+		sample := transferSample{streamBytes: make([]int64, streams), elapsed: now.Sub(last)}
+		for i := range counted {
+			bytes := counted[i].Load()
+			sample.streamBytes[i] = bytes - lastBytes[i]
+			sample.bytes += sample.streamBytes[i]
+			lastBytes[i] = bytes
+		}
+		result.samples = append(result.samples, sample)
+		last = now
+	}
+
+sampling:
+	for {
+		select {
+		case <-ctx.Done():
+			// Keep the tail unless it's too short to be a fair sample.
+			if now := time.Now(); ctx.Err() == context.DeadlineExceeded && now.Sub(last) >= interval/2 {
+				takeSample(now)
+			}
+			break sampling
+		case err := <-errs:
+			result.recordError(err)
+			if result.failed == streams {
+				break sampling
+			}
+		case now := <-ticker.C:
+			takeSample(now)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		result.recordError(err)
+	}
+
+	return result
+}
+
+func (t *timedTransfer) recordError(e streamError) {
+	t.failed++
+	t.streamErrs[e.stream] = e.err
+	if t.firstErr == nil {
+		t.firstErr = e.err
+	}
+}
+
+// timedSpeedResult turns a timed transfer into a speed test result, leaving
+// the samples that fall inside the warm-up window out of the rate.
+func timedSpeedResult(url, verb string, transfer timedTransfer, warmup, interval time.Duration) AverageSpeedTestResult {
+	var totalBytes, steadyBytes int64
+	var totalTime, steadyTime time.Duration
+	warmupSamples := min(int(warmup/interval), len(transfer.samples))
+	samples := make([]float64, len(transfer.samples))
+	for i, sample := range transfer.samples {
+		samples[i] = megabitsPerSecond(sample.bytes, sample.elapsed)
+		totalBytes += sample.bytes
+		totalTime += sample.elapsed
+		if i >= warmupSamples {
+			steadyBytes += sample.bytes
+			steadyTime += sample.elapsed
+		}
+	}
+
+	if totalBytes == 0 {
+		err := transfer.firstErr
+		if err == nil {
+			err = errors.New("no data transferred")
+		}
+		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - %v", err)
+	}
+	if steadyTime == 0 {
+		err := fmt.Errorf("no samples after the %s warm-up", formatDuration(warmup))
+		return createSpeedTestResult(url, 0, 0, 0, err, "FAILED - %v", err)
+	}
+
+	speedMbps := megabitsPerSecond(steadyBytes, steadyTime)
+	status := fmt.Sprintf("%.2f Mbps steady state (%s %s over %d streams in %s)",
+		speedMbps, formatBytes(totalBytes), verb, transfer.streams, formatDuration(totalTime))
+	if transfer.failed > 0 {
+		status += fmt.Sprintf(", %d of %d streams failed: %v", transfer.failed, transfer.streams, transfer.firstErr)
+	}
+
+	return AverageSpeedTestResult{
+		AverageMbps:   speedMbps,
+		ElapsedTime:   totalTime,
+		BytesReceived: totalBytes,
+		TestedURLs: map[string]SpeedTestResult{
+			url: {
+				Speed:          speedMbps,
+				Status:         status,
+				Duration:       totalTime,
+				Bytes:          totalBytes,
+				Streams:        transfer.streams,
+				SampleInterval: interval,
+				WarmupSamples:  warmupSamples,
+				Samples:        samples,
+			},
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+func (t *NetworkTester) measureTimedDownload(ctx context.Context, url string) AverageSpeedTestResult {
+	duration, warmup, interval := t.timedSpeedSettings()
+	transfer := runTimedTransfer(ctx, t.config.Tests.SpeedTestURLs.Streams, duration, interval,
+		func(ctx context.Context, _ int, counted *atomic.Int64) error {
+			return t.downloadCounting(ctx, url, counted)
+		})
+
+	return timedSpeedResult(url, "downloaded", transfer, warmup, interval)
+}
+
+// measureTimedUpload uploads size-byte bodies back to back on each stream.
+// Bytes count as they reach the socket, so the gap while the server answers
+// one upload before the next starts is the only server time that shows.
+func (t *NetworkTester) measureTimedUpload(ctx context.Context, url string, block []byte, size int64) AverageSpeedTestResult {
+	duration, warmup, interval := t.timedSpeedSettings()
+	streams := max(t.config.Tests.SpeedTestURLs.Streams, 1)
+
+	// Each slot is only touched by its own stream's goroutine.
+	uploads := make([]*uploadStream, streams)
+	transfer := runTimedTransfer(ctx, streams, duration, interval,
+		func(ctx context.Context, stream int, counted *atomic.Int64) error {
+			if uploads[stream] == nil {
+				uploads[stream] = newUploadStream(counted)
+			}
+			return uploads[stream].upload(ctx, url, block, size)
+		})
+	for _, upload := range uploads {
+		if upload != nil {
+			upload.close()
+		}
+	}
+
+	return timedSpeedResult(url, "uploaded", transfer, warmup, interval)
+}
+
+// timedSpeedSettings reads the duration mode timings, falling back to the
+// defaults for a duration or interval saved as zero from the settings page.
+func (t *NetworkTester) timedSpeedSettings() (duration, warmup, interval time.Duration) {
+	cfg := t.config.Tests.SpeedTestURLs
+	duration = time.Duration(cfg.DurationSeconds) * time.Second
+	if duration <= 0 {
+		duration = 10 * time.Second
+	}
+	interval = time.Duration(cfg.SampleIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	return duration, time.Duration(cfg.WarmupSeconds) * time.Second, interval
+}
+
+// megabitsPerSecond uses decimal megabits, the unit links are sold in.
+func megabitsPerSecond(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes*8) / 1e6 / d.Seconds()
+}
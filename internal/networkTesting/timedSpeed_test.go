@@ -0,0 +1,144 @@
+package networkTesting
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func TestRunTimedTransferSamples(t *testing.T) {
+	var calls atomic.Int32
+	transfer := runTimedTransfer(context.Background(), 3, 500*time.Millisecond, 50*time.Millisecond, func(ctx context.Context, _ int, counted *atomic.Int64) error {
+		calls.Add(1)
+		// Each "file" is 10 chunks, so streams finish and get restarted.
+		for i := 0; i < 10; i++ {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Millisecond):
+				counted.Add(1000)
+			}
+		}
+		return nil
+	})
+
+	if transfer.failed != 0 || transfer.firstErr != nil {
+		t.Errorf("failed = %d (%v), want no failures", transfer.failed, transfer.firstErr)
+	}
+	if n := len(transfer.samples); n < 8 || n > 10 {
+		t.Errorf("took %d samples, want about 10", n)
+	}
+	if calls.Load() <= 3 {
+		t.Errorf("transfer ran %d times, want streams restarted after finishing", calls.Load())
+	}
+	for i, sample := range transfer.samples {
+		if sample.bytes <= 0 {
+			t.Errorf("sample %d moved %d bytes, want > 0", i, sample.bytes)
+		}
+	}
+}
+
+func TestRunTimedTransferAllStreamsFail(t *testing.T) {
+	start := time.Now()
+	transfer := runTimedTransfer(context.Background(), 2, 5*time.Second, 100*time.Millisecond, func(context.Context, int, *atomic.Int64) error {
+		return errors.New("connection refused")
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want an early return once every stream failed", elapsed)
+	}
+	if transfer.failed != 2 || transfer.firstErr == nil {
+		t.Errorf("failed = %d (%v), want 2 with an error", transfer.failed, transfer.firstErr)
+	}
+
+	result := timedSpeedResult("http://example", "downloaded", transfer, 0, 100*time.Millisecond)
+	if result.Error == nil {
+		t.Error("Expected an error when nothing was transferred")
+	}
+}
+
+func TestRunTimedTransferEmptyTransferFails(t *testing.T) {
+	var calls atomic.Int32
+	start := time.Now()
+	transfer := runTimedTransfer(context.Background(), 2, 5*time.Second, 100*time.Millisecond, func(context.Context, int, *atomic.Int64) error {
+		calls.Add(1)
+		return nil // A 200 with an empty body
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want an early return once every stream came back empty", elapsed)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("transfer ran %d times, want each stream stopped after one empty transfer", calls.Load())
+	}
+	if transfer.failed != 2 || !errors.Is(transfer.firstErr, errEmptyTransfer) {
+		t.Errorf("failed = %d (%v), want 2 with errEmptyTransfer", transfer.failed, transfer.firstErr)
+	}
+}
+
+func TestTimedSpeedResultExcludesWarmup(t *testing.T) {
+	// Three slow-start samples at 8 Mbps, then a steady 100 Mbps.
+	transfer := timedTransfer{streams: 4}
+	for i := 0; i < 10; i++ {
+		bytes := int64(1_250_000)
+		if i < 3 {
+			bytes = 100_000
+		}
+		transfer.samples = append(transfer.samples, transferSample{bytes: bytes, elapsed: 100 * time.Millisecond})
+	}
+
+	result := timedSpeedResult("http://example", "downloaded", transfer, 300*time.Millisecond, 100*time.Millisecond)
+	if result.Error != nil {
+		t.Fatalf("timedSpeedResult returned error: %v", result.Error)
+	}
+
+	if math.Abs(result.AverageMbps-100) > 1e-9 {
+		t.Errorf("AverageMbps = %v, want 100 with the warm-up left out", result.AverageMbps)
+	}
+	if result.BytesReceived != 3*100_000+7*1_250_000 {
+		t.Errorf("BytesReceived = %d, want every sample counted", result.BytesReceived)
+	}
+	test := result.TestedURLs["http://example"]
+	if test.WarmupSamples != 3 || len(test.Samples) != 10 || test.Samples[0] != 8 {
+		t.Errorf("got %d warm-up samples of %v, want 3 of 10 starting at 8 Mbps", test.WarmupSamples, test.Samples)
+	}
+}
+
+func TestMeasureDownloadSpeedDurationMode(t *testing.T) {
+	server := newSpeedTestServer(t, DefaultSpeedTestMaxBytes)
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			SpeedTestURLs: config.SpeedTestURLs{
+				DownloadURLs:     []string{server.URL + "/speedtest/download?size=4MB"},
+				Mode:             "duration",
+				Streams:          2,
+				DurationSeconds:  1,
+				SampleIntervalMs: 100,
+			},
+		},
+	})
+
+	start := time.Now()
+	result, err := tester.MeasureDownloadSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("MeasureDownloadSpeed returned error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 2*time.Second {
+		t.Errorf("took %v, want the 1s duration bound", elapsed)
+	}
+	if !result.Sampled() {
+		t.Fatal("Expected a sampled result in duration mode")
+	}
+	if result.AverageMbps <= 0 || result.BytesReceived <= 4*1024*1024 {
+		t.Errorf("got %.2f Mbps over %d bytes, want repeated 4MB downloads", result.AverageMbps, result.BytesReceived)
+	}
+	if test := result.TestedURLs[server.URL+"/speedtest/download?size=4MB"]; test.Streams != 2 {
+		t.Errorf("Streams = %d, want 2", test.Streams)
+	}
+}
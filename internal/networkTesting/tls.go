@@ -0,0 +1,161 @@
+package networkTesting
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+type TLSTestResult struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Endpoints []TLSEndpointResult `json:"endpoints"`
+	Status    string              `json:"status"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// TLSEndpointResult describes one handshake. DaysUntilExpiry is taken from
+// whichever certificate in the served chain expires first, since an expired
+// intermediate breaks clients just as surely as an expired leaf.
+type TLSEndpointResult struct {
+	Endpoint        string           `json:"endpoint"`
+	Version         string           `json:"version"`
+	CipherSuite     string           `json:"cipher_suite"`
+	ALPN            string           `json:"alpn,omitempty"`
+	HandshakeTime   time.Duration    `json:"handshake_time"`
+	Chain           []TLSCertificate `json:"chain"`
+	DaysUntilExpiry int              `json:"days_until_expiry"`
+	Valid           bool             `json:"valid"`
+	ValidationError string           `json:"validation_error,omitempty"`
+	Failed          bool             `json:"failed"`
+	Error           string           `json:"error,omitempty"`
+}
+
+type TLSCertificate struct {
+	Subject         string    `json:"subject"`
+	Issuer          string    `json:"issuer"`
+	NotAfter        time.Time `json:"not_after"`
+	DaysUntilExpiry int       `json:"days_until_expiry"`
+}
+
+func (t *NetworkTester) RunTLSTest(ctx context.Context) (*TLSTestResult, error) {
+	cfg := t.config.Tests.TLS
+	if len(cfg.Endpoints) == 0 {
+		return nil, fmt.Errorf("no TLS endpoints configured")
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	result := &TLSTestResult{
+		Timestamp: time.Now(),
+		Endpoints: make([]TLSEndpointResult, 0, len(cfg.Endpoints)),
+	}
+
+	failures, warnings := 0, 0
+	for _, endpoint := range cfg.Endpoints {
+		endpointResult := inspectTLSEndpoint(ctx, endpoint, nil, timeout)
+		if ctx.Err() != nil {
+			break
+		}
+		switch {
+		case endpointResult.Failed:
+			failures++
+		case !endpointResult.Valid || endpointResult.DaysUntilExpiry < cfg.ExpiryWarningDays:
+			warnings++
+		}
+		result.Endpoints = append(result.Endpoints, endpointResult)
+	}
+
+	switch {
+	case failures == len(result.Endpoints):
+		result.Status = "FAILED"
+		result.Error = "no TLS handshakes succeeded"
+		return result, errors.New(result.Error)
+	case failures > 0 || warnings > 0:
+		result.Status = "PARTIAL"
+	default:
+		result.Status = "SUCCESS"
+	}
+
+	return result, nil
+}
+
+// inspectTLSEndpoint handshakes without verification so that the chain of a
+// misconfigured server can still be recorded, then verifies it separately.
+// A nil roots pool means the system roots.
+func inspectTLSEndpoint(ctx context.Context, endpoint string, roots *x509.CertPool, timeout time.Duration) TLSEndpointResult {
+	result := TLSEndpointResult{Endpoint: endpoint}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host, port = endpoint, "443"
+	}
+
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		result.Failed = true
+		result.Error = fmt.Sprintf("failed to connect: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         host,
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		result.Failed = true
+		result.Error = fmt.Sprintf("handshake failed: %v", err)
+		return result
+	}
+	result.HandshakeTime = time.Since(start)
+
+	state := tlsConn.ConnectionState()
+	result.Version = tls.VersionName(state.Version)
+	result.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	result.ALPN = state.NegotiatedProtocol
+
+	if len(state.PeerCertificates) == 0 {
+		result.ValidationError = "server presented no certificates"
+		return result
+	}
+
+	now := time.Now()
+	for i, cert := range state.PeerCertificates {
+		days := int(cert.NotAfter.Sub(now).Hours() / 24)
+		result.Chain = append(result.Chain, TLSCertificate{
+			Subject:         cert.Subject.String(),
+			Issuer:          cert.Issuer.String(),
+			NotAfter:        cert.NotAfter,
+			DaysUntilExpiry: days,
+		})
+		if i == 0 || days < result.DaysUntilExpiry {
+			result.DaysUntilExpiry = days
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		result.ValidationError = err.Error()
+	} else {
+		result.Valid = true
+	}
+
+	return result
+}
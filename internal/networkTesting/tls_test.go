@@ -0,0 +1,80 @@
+package networkTesting
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspectTLSEndpoint(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	endpoint := server.Listener.Addr().String()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	t.Run("trusted", func(t *testing.T) {
+		result := inspectTLSEndpoint(context.Background(), endpoint, roots, 2*time.Second)
+
+		if result.Failed {
+			t.Fatalf("Expected handshake to succeed, got error: %s", result.Error)
+		}
+		if !result.Valid {
+			t.Errorf("Expected valid chain, got validation error: %s", result.ValidationError)
+		}
+		if !strings.HasPrefix(result.Version, "TLS") {
+			t.Errorf("Version = %q, want a TLS version name", result.Version)
+		}
+		if result.CipherSuite == "" {
+			t.Error("Expected cipher suite to be recorded")
+		}
+		if result.ALPN != "h2" {
+			t.Errorf("ALPN = %q, want h2", result.ALPN)
+		}
+		if result.HandshakeTime <= 0 {
+			t.Errorf("Expected handshake time > 0, got %v", result.HandshakeTime)
+		}
+		if len(result.Chain) == 0 {
+			t.Fatal("Expected certificate chain to be recorded")
+		}
+		if result.DaysUntilExpiry != result.Chain[0].DaysUntilExpiry || result.DaysUntilExpiry <= 0 {
+			t.Errorf("DaysUntilExpiry = %d, chain reports %d", result.DaysUntilExpiry, result.Chain[0].DaysUntilExpiry)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		result := inspectTLSEndpoint(context.Background(), endpoint, x509.NewCertPool(), 2*time.Second)
+
+		if result.Failed {
+			t.Fatalf("Untrusted chain should still complete the handshake, got: %s", result.Error)
+		}
+		if result.Valid || result.ValidationError == "" {
+			t.Errorf("Expected validation error for untrusted chain, got valid=%v", result.Valid)
+		}
+		if len(result.Chain) == 0 {
+			t.Error("Expected chain to be recorded even when invalid")
+		}
+	})
+
+	t.Run("closed port", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to reserve port: %v", err)
+		}
+		addr := listener.Addr().String()
+		listener.Close()
+
+		result := inspectTLSEndpoint(context.Background(), addr, roots, time.Second)
+		if !result.Failed {
+			t.Error("Expected failure connecting to a closed port")
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package networkTesting
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// UDPTestResult describes one stream of datagrams sent to a reflector.
+//
+// Loss, reordering, duplicates and jitter are all measured by the reflector
+// as the stream arrives, so they describe the outbound direction only; run
+// the test from the other site to measure the way back. Jitter is the RFC
+// 3550 interarrival jitter of the one-way transit time, which a clock offset
+// between the two hosts cancels out of. ThroughputMbps is the UDP payload
+// rate the reflector received, against the BitrateKbps that was offered.
+type UDPTestResult struct {
+	Timestamp      time.Time     `json:"timestamp"`
+	Target         string        `json:"target"`
+	BitrateKbps    int           `json:"bitrate_kbps"`
+	PacketSize     int           `json:"packet_size"`
+	Duration       time.Duration `json:"duration"`
+	Sent           int           `json:"sent"`
+	Received       int           `json:"received"`
+	Lost           int           `json:"lost"`
+	LossPercent    float64       `json:"loss_percent"`
+	Reordered      int           `json:"reordered"`
+	Duplicates     int           `json:"duplicates"`
+	Jitter         time.Duration `json:"jitter"`
+	SendMbps       float64       `json:"send_mbps"`
+	ThroughputMbps float64       `json:"throughput_mbps"`
+	Status         string        `json:"status"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// Every datagram starts with a fixed header: the magic, a version, the
+// message kind, the sender's session ID, a sequence number and the send time
+// in Unix nanoseconds, all big-endian. Data messages are padded out to the
+// configured packet size.
+const (
+	udpMagic     = "GNTU"
+	udpVersion   = 1
+	udpHeaderLen = 22
+
+	udpMsgData   = 1
+	udpMsgHello  = 2
+	udpMsgReport = 3
+
+	// udpControlAttempts is how many times hello and report requests are
+	// sent before the reflector is given up on.
+	udpControlAttempts = 3
+)
+
+type udpHeader struct {
+	kind    byte
+	session uint32
+	seq     uint32
+	sent    int64
+}
+
+func (h udpHeader) marshal(b []byte) {
+	copy(b, udpMagic)
+	b[4] = udpVersion
+	b[5] = h.kind
+	binary.BigEndian.PutUint32(b[6:], h.session)
+	binary.BigEndian.PutUint32(b[10:], h.seq)
+	binary.BigEndian.PutUint64(b[14:], uint64(h.sent))
+}
+
+func parseUDPHeader(b []byte) (udpHeader, bool) {
+	if len(b) < udpHeaderLen || string(b[:4]) != udpMagic || b[4] != udpVersion {
+		return udpHeader{}, false
+	}
+	return udpHeader{
+		kind:    b[5],
+		session: binary.BigEndian.Uint32(b[6:]),
+		seq:     binary.BigEndian.Uint32(b[10:]),
+		sent:    int64(binary.BigEndian.Uint64(b[14:])),
+	}, true
+}
+
+// udpReport is the reflector's view of a session, sent back as JSON after
+// the header of a report message.
+type udpReport struct {
+	Received    int           `json:"received"`
+	Reordered   int           `json:"reordered"`
+	Duplicates  int           `json:"duplicates"`
+	Jitter      time.Duration `json:"jitter"`
+	ReceiveSpan time.Duration `json:"receive_span"`
+}
+
+func (t *NetworkTester) RunUDPTest(ctx context.Context) (*UDPTestResult, error) {
+	cfg := t.config.Tests.UDP
+	if cfg.Target == "" {
+		return nil, fmt.Errorf("no UDP reflector target configured: start one with the reflector subcommand and set tests.udp.target to it")
+	}
+	if cfg.PacketSize < udpHeaderLen {
+		return nil, fmt.Errorf("UDP packet size %d is smaller than the %d-byte header", cfg.PacketSize, udpHeaderLen)
+	}
+	if cfg.BitrateKbps <= 0 || cfg.DurationSeconds <= 0 {
+		return nil, fmt.Errorf("UDP bitrate and duration must both be positive")
+	}
+
+	result := &UDPTestResult{
+		Timestamp:   time.Now(),
+		Target:      cfg.Target,
+		BitrateKbps: cfg.BitrateKbps,
+		PacketSize:  cfg.PacketSize,
+	}
+
+	if err := t.runUDPStream(ctx, result); err != nil {
+		result.Status = "FAILED"
+		result.Error = err.Error()
+		return result, err
+	}
+
+	result.Status = "SUCCESS"
+	return result, nil
+}
+
+// runUDPStream still asks for the reflector's report when ctx cuts the
+// stream short, so a cancelled run keeps what was measured.
+func (t *NetworkTester) runUDPStream(ctx context.Context, result *UDPTestResult) error {
+	cfg := t.config.Tests.UDP
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+
+	conn, err := net.Dial("udp", cfg.Target)
+	if err != nil {
+		return fmt.Errorf("failed to open UDP socket to %s: %w", cfg.Target, err)
+	}
+	defer conn.Close()
+
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return fmt.Errorf("failed to pick a session ID: %w", err)
+	}
+	session := binary.BigEndian.Uint32(id[:])
+
+	if _, err := udpControl(conn, udpHeader{kind: udpMsgHello, session: session}, timeout); err != nil {
+		return fmt.Errorf("no reflector answering at %s: %w", cfg.Target, err)
+	}
+
+	total := max(int(int64(cfg.BitrateKbps)*1000*int64(cfg.DurationSeconds)/int64(cfg.PacketSize*8)), 1)
+	interval := time.Duration(int64(cfg.PacketSize*8) * int64(time.Second) / (int64(cfg.BitrateKbps) * 1000))
+
+	sent, elapsed := sendUDPStream(ctx, conn, session, total, cfg.PacketSize, interval)
+	result.Sent = sent
+	result.Duration = elapsed
+	if elapsed > 0 {
+		result.SendMbps = float64(sent*cfg.PacketSize*8) / elapsed.Seconds() / 1e6
+	}
+
+	body, err := udpControl(conn, udpHeader{kind: udpMsgReport, session: session, seq: uint32(sent)}, timeout)
+	if err != nil {
+		return fmt.Errorf("reflector did not report on the stream: %w", err)
+	}
+	var report udpReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return fmt.Errorf("failed to parse reflector report: %w", err)
+	}
+
+	applyUDPReport(result, report)
+	return nil
+}
+
+// sendUDPStream paces total datagrams interval apart from a fixed start, so
+// a slow write or an oversleep is caught up on rather than stretching the
+// stream. It returns how many were sent, fewer if ctx ended the stream,
+// and how long that took.
+func sendUDPStream(ctx context.Context, conn net.Conn, session uint32, total, size int, interval time.Duration) (int, time.Duration) {
+	packet := make([]byte, size)
+	start := time.Now()
+
+	for seq := 0; seq < total; seq++ {
+		wait := time.Until(start.Add(time.Duration(seq) * interval))
+		if ctx.Err() != nil || (wait > 0 && !sleepContext(ctx, wait)) {
+			return seq, time.Since(start)
+		}
+		udpHeader{kind: udpMsgData, session: session, seq: uint32(seq), sent: time.Now().UnixNano()}.marshal(packet)
+		// A failed write (a full send buffer, or an ICMP error left by an
+		// earlier datagram) still counts as sent: the datagram is lost,
+		// just before the wire rather than on it.
+		conn.Write(packet)
+	}
+
+	return total, time.Since(start)
+}
+
+// udpControl sends a control message and waits for the reflector's answer to
+// it, retrying a few times since either datagram may be lost. It returns the
+// answer's body.
+func udpControl(conn net.Conn, h udpHeader, timeout time.Duration) ([]byte, error) {
+	msg := make([]byte, udpHeaderLen)
+	h.marshal(msg)
+	buf := make([]byte, 64*1024)
+
+	var lastErr error
+	for attempt := 0; attempt < udpControlAttempts; attempt++ {
+		if _, err := conn.Write(msg); err != nil {
+			lastErr = err
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				lastErr = err
+				break
+			}
+			reply, ok := parseUDPHeader(buf[:n])
+			if ok && reply.kind == h.kind && reply.session == h.session {
+				return append([]byte(nil), buf[udpHeaderLen:n]...), nil
+			}
+		}
+	}
+
+	return nil, lastErr
+}
+
+func applyUDPReport(result *UDPTestResult, report udpReport) {
+	result.Received = report.Received
+	result.Reordered = report.Reordered
+	result.Duplicates = report.Duplicates
+	result.Jitter = report.Jitter
+	result.Lost = max(result.Sent-report.Received, 0)
+	if result.Sent > 0 {
+		result.LossPercent = float64(result.Lost) / float64(result.Sent) * 100
+	}
+	// The span runs from the first arrival to the last, so it covers every
+	// datagram but the first.
+	if report.Received > 1 && report.ReceiveSpan > 0 {
+		result.ThroughputMbps = float64((report.Received-1)*result.PacketSize*8) / report.ReceiveSpan.Seconds() / 1e6
+	}
+}
@@ -0,0 +1,209 @@
+package networkTesting
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	// udpSessionIdle is how long a session's statistics are kept after its
+	// last datagram, long enough for the client to retry its report request.
+	udpSessionIdle = time.Minute
+
+	// udpMaxSessions and udpMaxSeq bound the memory one reflector can be
+	// made to use by streams it didn't ask for.
+	udpMaxSessions = 256
+	udpMaxSeq      = 1 << 24
+)
+
+// UDPReflector is the far end of the UDP test: it sinks each stream,
+// tracking loss, order and transit jitter per session, and answers the
+// client's hello and report requests. Run it with the "reflector"
+// subcommand on the host you want to test towards.
+type UDPReflector struct {
+	conn net.PacketConn
+
+	// sessions is only touched by Serve's goroutine.
+	sessions map[udpSessionKey]*udpSession
+}
+
+// Sessions are keyed by source address as well as ID so two clients that
+// happen to pick the same ID can't mix their statistics.
+type udpSessionKey struct {
+	addr string
+	id   uint32
+}
+
+func ListenUDPReflector(addr string) (*UDPReflector, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if udp, ok := conn.(*net.UDPConn); ok {
+		// A deep receive buffer keeps bursts from being dropped by this
+		// host rather than the network under test.
+		udp.SetReadBuffer(4 * 1024 * 1024)
+	}
+
+	return &UDPReflector{
+		conn:     conn,
+		sessions: make(map[udpSessionKey]*udpSession),
+	}, nil
+}
+
+func (r *UDPReflector) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+func (r *UDPReflector) Close() error {
+	return r.conn.Close()
+}
+
+// Serve handles datagrams until the reflector is closed, which it reports
+// as a nil error.
+func (r *UDPReflector) Serve() error {
+	buf := make([]byte, 64*1024)
+	lastSweep := time.Now()
+
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		arrived := time.Now()
+
+		h, ok := parseUDPHeader(buf[:n])
+		if !ok {
+			continue
+		}
+		key := udpSessionKey{addr: from.String(), id: h.session}
+
+		switch h.kind {
+		case udpMsgHello:
+			if r.session(key, arrived, true) != nil {
+				r.reply(h, nil, from)
+			}
+		case udpMsgData:
+			if s := r.session(key, arrived, false); s != nil {
+				s.record(h.seq, time.Unix(0, h.sent), arrived)
+			}
+		case udpMsgReport:
+			if s := r.session(key, arrived, false); s != nil {
+				body, err := json.Marshal(s.report())
+				if err != nil {
+					log.Printf("UDP reflector: failed to encode report: %v", err)
+					continue
+				}
+				r.reply(h, body, from)
+			}
+		}
+
+		if arrived.Sub(lastSweep) > udpSessionIdle {
+			r.expire(arrived)
+			lastSweep = arrived
+		}
+	}
+}
+
+// session looks up a session, creating it on hello. Data and report requests
+// for a session that never said hello, or was expired, are ignored.
+func (r *UDPReflector) session(key udpSessionKey, now time.Time, create bool) *udpSession {
+	s, ok := r.sessions[key]
+	if !ok {
+		if !create {
+			return nil
+		}
+		if len(r.sessions) >= udpMaxSessions {
+			r.expire(now)
+			if len(r.sessions) >= udpMaxSessions {
+				return nil
+			}
+		}
+		s = &udpSession{}
+		r.sessions[key] = s
+	}
+	s.lastSeen = now
+	return s
+}
+
+func (r *UDPReflector) expire(now time.Time) {
+	for key, s := range r.sessions {
+		if now.Sub(s.lastSeen) > udpSessionIdle {
+			delete(r.sessions, key)
+		}
+	}
+}
+
+func (r *UDPReflector) reply(h udpHeader, body []byte, to net.Addr) {
+	msg := make([]byte, udpHeaderLen+len(body))
+	udpHeader{kind: h.kind, session: h.session, seq: h.seq, sent: time.Now().UnixNano()}.marshal(msg)
+	copy(msg[udpHeaderLen:], body)
+	if _, err := r.conn.WriteTo(msg, to); err != nil {
+		log.Printf("UDP reflector: failed to reply to %s: %v", to, err)
+	}
+}
+
+// udpSession accumulates the statistics for one incoming stream.
+type udpSession struct {
+	received   int
+	reordered  int
+	duplicates int
+
+	seen    []uint64 // bitmap of sequence numbers received
+	highest uint32
+
+	jitter      float64
+	lastTransit time.Duration
+
+	first, last time.Time
+	lastSeen    time.Time
+}
+
+// record notes one data datagram. A datagram is reordered when a later
+// sequence number has already arrived. Jitter is RFC 3550's, over arrival
+// order, from the difference between the send and arrival clocks.
+func (s *udpSession) record(seq uint32, sent, arrived time.Time) {
+	if seq >= udpMaxSeq {
+		return
+	}
+
+	word, bit := seq/64, uint64(1)<<(seq%64)
+	if int(word) >= len(s.seen) {
+		s.seen = append(s.seen, make([]uint64, int(word)+1-len(s.seen))...)
+	}
+	if s.seen[word]&bit != 0 {
+		s.duplicates++
+		return
+	}
+	s.seen[word] |= bit
+
+	transit := arrived.Sub(sent)
+	if s.received == 0 {
+		s.first = arrived
+	} else {
+		if seq < s.highest {
+			s.reordered++
+		}
+		s.jitter += (float64(abs(transit-s.lastTransit)) - s.jitter) / 16
+	}
+	s.highest = max(s.highest, seq)
+	s.lastTransit = transit
+	s.last = arrived
+	s.received++
+}
+
+func (s *udpSession) report() udpReport {
+	return udpReport{
+		Received:    s.received,
+		Reordered:   s.reordered,
+		Duplicates:  s.duplicates,
+		Jitter:      time.Duration(s.jitter),
+		ReceiveSpan: s.last.Sub(s.first),
+	}
+}
@@ -0,0 +1,166 @@
+package networkTesting
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func startUDPReflector(t *testing.T) *UDPReflector {
+	reflector, err := ListenUDPReflector("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenUDPReflector failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- reflector.Serve() }()
+	t.Cleanup(func() {
+		reflector.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned %v after Close, want nil", err)
+		}
+	})
+	return reflector
+}
+
+func udpTester(target string) *NetworkTester {
+	return NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			UDP: config.UDPConfig{
+				Target:          target,
+				BitrateKbps:     800,
+				PacketSize:      500,
+				DurationSeconds: 1,
+				TimeoutSeconds:  1,
+			},
+		},
+	})
+}
+
+func TestRunUDPTestOverLoopback(t *testing.T) {
+	reflector := startUDPReflector(t)
+
+	result, err := udpTester(reflector.Addr().String()).RunUDPTest(context.Background())
+	if err != nil {
+		t.Fatalf("RunUDPTest returned error: %v", err)
+	}
+
+	if result.Status != "SUCCESS" {
+		t.Errorf("Status = %s, want SUCCESS", result.Status)
+	}
+	if result.Sent != 200 {
+		t.Errorf("Sent = %d, want 200 (800kbps of 500-byte datagrams for 1s)", result.Sent)
+	}
+	if result.Received != result.Sent || result.Lost != 0 {
+		t.Errorf("Received %d of %d with %d lost, want everything over loopback", result.Received, result.Sent, result.Lost)
+	}
+	if result.Duplicates != 0 {
+		t.Errorf("Duplicates = %d, want 0", result.Duplicates)
+	}
+	if result.ThroughputMbps < 0.6 || result.ThroughputMbps > 1.2 {
+		t.Errorf("ThroughputMbps = %.2f, want about 0.8", result.ThroughputMbps)
+	}
+	if result.Duration < 900*time.Millisecond {
+		t.Errorf("Duration = %v, want the stream paced over about 1s", result.Duration)
+	}
+}
+
+func TestRunUDPTestNoReflector(t *testing.T) {
+	// Reserve a port and free it, so nothing is listening there.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := conn.LocalAddr().String()
+	conn.Close()
+
+	result, err := udpTester(target).RunUDPTest(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error with no reflector listening")
+	}
+	if result == nil || result.Status != "FAILED" || result.Sent != 0 {
+		t.Errorf("result = %+v, want FAILED before any data was sent", result)
+	}
+}
+
+func TestRunUDPTestNotConfigured(t *testing.T) {
+	result, err := udpTester("").RunUDPTest(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no UDP reflector target configured") {
+		t.Fatalf("RunUDPTest returned %v, want a not-configured error", err)
+	}
+	if result != nil {
+		t.Errorf("result = %+v, want nil when nothing was run", result)
+	}
+}
+
+func TestUDPSessionRecord(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	transit := []time.Duration{10, 10, 10, 30, 10, 10}
+	seqs := []uint32{0, 1, 3, 2, 2, 4}
+
+	var s udpSession
+	for i, seq := range seqs {
+		sent := base.Add(time.Duration(seq) * 20 * time.Millisecond)
+		s.record(seq, sent, sent.Add(transit[i]*time.Millisecond))
+	}
+
+	report := s.report()
+	if report.Received != 5 {
+		t.Errorf("Received = %d, want 5", report.Received)
+	}
+	if report.Reordered != 1 {
+		t.Errorf("Reordered = %d, want 1 (seq 2 after seq 3)", report.Reordered)
+	}
+	if report.Duplicates != 1 {
+		t.Errorf("Duplicates = %d, want 1", report.Duplicates)
+	}
+
+	// Transit 10, 10, 10, 30, 10 in arrival order: J = 20/16, then
+	// J += (20 - J)/16.
+	j := 20.0 / 16
+	j += (20 - j) / 16
+	if want := time.Duration(j * float64(time.Millisecond)); report.Jitter != want {
+		t.Errorf("Jitter = %v, want %v", report.Jitter, want)
+	}
+	// Seq 0 arrives at 10ms and seq 4, the last, at 90ms.
+	if want := 80 * time.Millisecond; report.ReceiveSpan != want {
+		t.Errorf("ReceiveSpan = %v, want %v", report.ReceiveSpan, want)
+	}
+}
+
+func TestUDPSessionIgnoresClockOffset(t *testing.T) {
+	// The sender's clock running an hour ahead shifts every transit time
+	// equally, which jitter mustn't see.
+	base := time.Unix(1700000000, 0)
+	var s udpSession
+	for seq := uint32(0); seq < 10; seq++ {
+		arrived := base.Add(time.Duration(seq) * 20 * time.Millisecond)
+		s.record(seq, arrived.Add(time.Hour), arrived)
+	}
+
+	if report := s.report(); report.Jitter != 0 {
+		t.Errorf("Jitter = %v, want 0 for a constant transit time", report.Jitter)
+	}
+}
+
+func TestUDPHeaderRoundTrip(t *testing.T) {
+	want := udpHeader{kind: udpMsgData, session: 0xdeadbeef, seq: 42, sent: 1700000000123456789}
+	b := make([]byte, 100)
+	want.marshal(b)
+
+	got, ok := parseUDPHeader(b)
+	if !ok || got != want {
+		t.Errorf("parseUDPHeader = %+v, %v; want %+v, true", got, ok, want)
+	}
+
+	if _, ok := parseUDPHeader(b[:udpHeaderLen-1]); ok {
+		t.Error("Expected a short datagram to be rejected")
+	}
+	b[0] = 'X'
+	if _, ok := parseUDPHeader(b); ok {
+		t.Error("Expected a datagram without the magic to be rejected")
+	}
+}
@@ -0,0 +1,130 @@
+package networkTesting
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// payloadBlockBytes is the size of the random block that uploads and the
+// speed-test server repeat to make up a transfer of any size.
+const payloadBlockBytes = 1024 * 1024
+
+func newPayloadBlock() ([]byte, error) {
+	block := make([]byte, payloadBlockBytes)
+	if _, err := rand.Read(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// payloadReader streams size bytes by repeating block, so an upload of any
+// size costs one block of memory and stays incompressible.
+type payloadReader struct {
+	block     []byte
+	remaining int64
+	offset    int
+}
+
+func newPayloadReader(block []byte, size int64) *payloadReader {
+	return &payloadReader{block: block, remaining: size}
+}
+
+func (p *payloadReader) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		return 0, io.EOF
+	}
+	b = b[:min(int64(len(b)), p.remaining)]
+	n := 0
+	for n < len(b) {
+		copied := copy(b[n:], p.block[p.offset:])
+		n += copied
+		p.offset = (p.offset + copied) % len(p.block)
+	}
+	p.remaining -= int64(n)
+	return n, nil
+}
+
+// uploadStream is one upload connection that counts bytes as they are
+// written to the socket, so a rate can be taken without waiting for, or
+// counting, the server's response.
+type uploadStream struct {
+	client    *http.Client
+	written   *atomic.Int64
+	lastWrite atomic.Int64 // Unix nanoseconds
+}
+
+// newUploadStream gives the stream its own transport, so parallel streams
+// really are separate connections, each reused across uploads. Writes are
+// added to written.
+func newUploadStream(written *atomic.Int64) *uploadStream {
+	s := &uploadStream{written: written}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	s.client = &http.Client{
+		Timeout: 5 * time.Minute,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				conn, err := dialer.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				return &countingConn{Conn: conn, stream: s}, nil
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 1,
+		},
+	}
+	return s
+}
+
+// upload POSTs size bytes of block to url and drains the response.
+func (s *uploadStream) upload(ctx context.Context, url string, block []byte, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, newPayloadReader(block, size))
+	if err != nil {
+		return fmt.Errorf("failed to setup request: %v", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upload failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// lastWriteTime is when the stream last handed bytes to the kernel, which
+// for a finished upload is when its body was sent.
+func (s *uploadStream) lastWriteTime() time.Time {
+	return time.Unix(0, s.lastWrite.Load())
+}
+
+func (s *uploadStream) close() {
+	s.client.CloseIdleConnections()
+}
+
+type countingConn struct {
+	net.Conn
+	stream *uploadStream
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stream.written.Add(int64(n))
+	c.stream.lastWrite.Store(time.Now().UnixNano())
+	return n, err
+}
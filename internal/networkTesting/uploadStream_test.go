@@ -0,0 +1,109 @@
+package networkTesting
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/config"
+)
+
+func TestPayloadReader(t *testing.T) {
+	block := []byte("0123456789")
+	for _, size := range []int64{0, 7, 10, 25} {
+		got, err := io.ReadAll(newPayloadReader(block, size))
+		if err != nil {
+			t.Fatalf("ReadAll(%d) failed: %v", size, err)
+		}
+		want := bytes.Repeat(block, 3)[:size]
+		if !bytes.Equal(got, want) {
+			t.Errorf("payload of %d = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestMeasureSingleUploadExcludesServerTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(500 * time.Millisecond) // A slow backend behind the upload
+	}))
+	defer server.Close()
+
+	block, err := newPayloadBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tester := NewNetworkTester(&config.Config{})
+
+	start := time.Now()
+	result := tester.measureSingleUpload(context.Background(), server.URL, block, 2*1024*1024)
+	if result.Error != nil {
+		t.Fatalf("measureSingleUpload returned error: %v", result.Error)
+	}
+
+	if time.Since(start) < 500*time.Millisecond {
+		t.Fatal("Expected the upload to wait for the server's answer")
+	}
+	if result.ElapsedTime >= 400*time.Millisecond {
+		t.Errorf("ElapsedTime = %v, want the server's 500ms left out", result.ElapsedTime)
+	}
+	if result.BytesReceived != 2*1024*1024 {
+		t.Errorf("BytesReceived = %d, want %d", result.BytesReceived, 2*1024*1024)
+	}
+}
+
+func TestMeasureUploadSpeedDurationMode(t *testing.T) {
+	s, err := NewSpeedTestServer(DefaultSpeedTestMaxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns, uploads atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads.Add(1)
+		s.ServeUpload(w, r)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tester := NewNetworkTester(&config.Config{
+		Tests: config.TestConfigs{
+			SpeedTestURLs: config.SpeedTestURLs{
+				UploadURLs:       []string{server.URL},
+				UploadSizeMB:     1,
+				Mode:             "duration",
+				Streams:          3,
+				DurationSeconds:  1,
+				SampleIntervalMs: 100,
+			},
+		},
+	})
+
+	result, err := tester.MeasureUploadSpeed(context.Background())
+	if err != nil {
+		t.Fatalf("MeasureUploadSpeed returned error: %v", err)
+	}
+
+	if !result.Sampled() || result.AverageMbps <= 0 {
+		t.Errorf("got %.2f Mbps, sampled %v; want a sampled rate > 0", result.AverageMbps, result.Sampled())
+	}
+	if result.BytesReceived <= 1024*1024 {
+		t.Errorf("BytesReceived = %d, want several 1MB uploads", result.BytesReceived)
+	}
+	if uploads.Load() <= 3 {
+		t.Errorf("server saw %d uploads, want streams to keep uploading for the duration", uploads.Load())
+	}
+	if conns.Load() != 3 {
+		t.Errorf("server saw %d connections, want one per stream, reused", conns.Load())
+	}
+}
@@ -1,7 +1,10 @@
 package networkTesting
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sort"
 	"time"
 )
 
@@ -20,6 +23,19 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dm %.1fs", minutes, remainingSeconds)
 }
 
+// sleepContext waits for d, or until ctx is done, and reports whether the
+// full wait elapsed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func formatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -34,3 +50,24 @@ func formatBytes(bytes int64) string {
 
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
+
+// percentile returns the nearest-rank p-th percentile (0-100) of values
+// without modifying the slice.
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
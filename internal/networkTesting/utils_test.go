@@ -0,0 +1,37 @@
+package networkTesting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []time.Duration{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+
+	tests := []struct {
+		name string
+		p    float64
+		want time.Duration
+	}{
+		{name: "minimum", p: 0, want: 1},
+		{name: "median", p: 50, want: 5},
+		{name: "p90", p: 90, want: 9},
+		{name: "p95", p: 95, want: 10},
+		{name: "maximum", p: 100, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(values, tt.p); got != tt.want {
+				t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+
+	if values[0] != 5 {
+		t.Error("percentile modified its input")
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
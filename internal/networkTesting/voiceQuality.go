@@ -0,0 +1,84 @@
+package networkTesting
+
+import (
+	"math"
+	"time"
+)
+
+// The E-model (ITU-T G.107) rates a voice call as R = R0 - Id - Ie,eff,
+// where R0 is the best a narrowband call can score, Id the impairment from
+// delay and Ie,eff the impairment from the codec and packet loss. This
+// implementation assumes G.711 with packet loss concealment, the codec
+// most VoIP deployments fall back to, and uses the Cole & Rosenbluth
+// simplification of the delay term.
+const (
+	// eModelR0 is R with every G.107 parameter at its default.
+	eModelR0 = 93.2
+
+	// g711Ie and g711Bpl are the equipment impairment and packet loss
+	// robustness factors for G.711 with PLC (ITU-T G.113 Appendix I).
+	g711Ie  = 0.0
+	g711Bpl = 25.1
+
+	// g711Packetisation is the one-way delay a 20ms G.711 frame adds
+	// before it ever reaches the network.
+	g711Packetisation = 20 * time.Millisecond
+)
+
+// voiceQuality estimates the R-factor and MOS of a call over a path with
+// the given round trip time, jitter and loss. One-way delay is taken as
+// half the RTT plus a jitter buffer twice the jitter plus packetisation.
+// burstR is G.107's burst ratio: 1 for random loss, higher when losses
+// cluster.
+func voiceQuality(rtt, jitter time.Duration, lossPercent, burstR float64) (rFactor, mos float64) {
+	delay := rtt/2 + 2*jitter + g711Packetisation
+	rFactor = eModelRFactor(float64(delay)/float64(time.Millisecond), lossPercent, burstR)
+	return rFactor, mosFromRFactor(rFactor)
+}
+
+// eModelRFactor computes R for a one-way mouth-to-ear delay in ms and a
+// packet loss percentage, clamped to the 0-100 scale.
+func eModelRFactor(delayMs, lossPercent, burstR float64) float64 {
+	id := 0.024 * delayMs
+	if delayMs > 177.3 {
+		id += 0.11 * (delayMs - 177.3)
+	}
+
+	burstR = max(burstR, 1)
+	ieEff := g711Ie + (95-g711Ie)*lossPercent/(lossPercent/burstR+g711Bpl)
+
+	return min(max(eModelR0-id-ieEff, 0), 100)
+}
+
+// mosFromRFactor converts R to an estimated mean opinion score on the 1-4.5
+// scale (ITU-T G.107 Annex B).
+func mosFromRFactor(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	default:
+		return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+	}
+}
+
+// burstRatio derives G.107's BurstR from a run's losses: the observed mean
+// loss burst length over the mean a random loss process would give at the
+// same loss rate. Loss spread more evenly than random is treated as random,
+// since the E-model isn't defined below 1.
+func burstRatio(sent, lost, bursts int) float64 {
+	if sent == 0 || lost == 0 || bursts == 0 || lost == sent {
+		return 1
+	}
+	p := float64(lost) / float64(sent)
+	meanBurst := float64(lost) / float64(bursts)
+	return max(meanBurst*(1-p), 1)
+}
+
+// roundTo rounds v to the given number of decimal places, which is all the
+// precision an estimate like MOS deserves.
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,94 @@
+package networkTesting
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMOSFromRFactor(t *testing.T) {
+	// Boundaries of the ITU-T G.109 user satisfaction categories.
+	tests := []struct {
+		r    float64
+		want float64
+	}{
+		{-5, 1},
+		{0, 1},
+		{50, 2.58},
+		{60, 3.10},
+		{70, 3.60},
+		{80, 4.02},
+		{90, 4.34},
+		{93.2, 4.41}, // G.107 defaults
+		{100, 4.5},
+		{120, 4.5},
+	}
+
+	for _, tt := range tests {
+		if got := mosFromRFactor(tt.r); math.Abs(got-tt.want) > 0.005 {
+			t.Errorf("mosFromRFactor(%v) = %.3f, want %.2f", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestEModelRFactor(t *testing.T) {
+	tests := []struct {
+		name        string
+		delayMs     float64
+		lossPercent float64
+		burstR      float64
+		want        float64
+	}{
+		{"G.107 defaults", 0, 0, 1, 93.2},
+		{"150ms one-way", 150, 0, 1, 89.6},
+		{"177.3ms knee", 177.3, 0, 1, 88.9448},
+		{"300ms one-way", 300, 0, 1, 72.503},
+		{"1% random loss", 0, 1, 1, 89.5602},
+		{"2% random loss", 0, 2, 1, 86.1889},
+		{"2% loss in bursts of 2", 0, 2, 2, 85.9203},
+		{"burst ratio below 1 treated as random", 0, 2, 0.5, 86.1889},
+		{"150ms and 1% loss", 150, 1, 1, 85.9602},
+		{"total loss clamps at 0", 800, 100, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eModelRFactor(tt.delayMs, tt.lossPercent, tt.burstR); math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("eModelRFactor(%v, %v, %v) = %.4f, want %.4f", tt.delayMs, tt.lossPercent, tt.burstR, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVoiceQuality(t *testing.T) {
+	// 40ms RTT and 5ms jitter: 20 + 10 + 20 = 50ms one way.
+	r, mos := voiceQuality(40*time.Millisecond, 5*time.Millisecond, 0, 1)
+	if math.Abs(r-92) > 0.001 {
+		t.Errorf("R = %.4f, want 92", r)
+	}
+	if math.Abs(mos-mosFromRFactor(92)) > 1e-9 {
+		t.Errorf("MOS = %.4f, want %.4f", mos, mosFromRFactor(92))
+	}
+}
+
+func TestBurstRatio(t *testing.T) {
+	tests := []struct {
+		name               string
+		sent, lost, bursts int
+		want               float64
+	}{
+		{"no loss", 100, 0, 0, 1},
+		{"isolated losses", 100, 4, 4, 1},
+		{"pairs at 10% loss", 100, 10, 5, 1.8},
+		{"one burst of 10", 100, 10, 1, 9},
+		{"everything lost", 10, 10, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := burstRatio(tt.sent, tt.lost, tt.bursts); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("burstRatio(%d, %d, %d) = %v, want %v", tt.sent, tt.lost, tt.bursts, got, tt.want)
+			}
+		})
+	}
+}
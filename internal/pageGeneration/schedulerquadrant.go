@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
 	"github.com/oshaw1/go-net-test/internal/scheduler"
 )
 
@@ -32,3 +33,14 @@ func (pg *PageGenerator) RenderSchedule(w http.ResponseWriter, data *SchedulerQu
 	w.Write(buf.Bytes())
 	return nil
 }
+
+// RenderQueue renders the tests waiting for or holding a turn to run.
+func (pg *PageGenerator) RenderQueue(w http.ResponseWriter, tests []networkTesting.RunningTest) error {
+	var buf bytes.Buffer
+	if err := pg.templates.ExecuteTemplate(&buf, "queue", tests); err != nil {
+		return err
+	}
+
+	w.Write(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed Task.Cron expression. It's either a standard
+// five-field expression (minute hour day-of-month month day-of-week), one of
+// the @hourly-style aliases, or "every N minutes" / "every N hours".
+// Cron fields are matched against the server's local time.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny record a day field starting with "*". As in Vixie
+	// cron, when both day fields are restricted a day matching either one
+	// fires.
+	domAny, dowAny bool
+
+	// every is set for "every N minutes" schedules, which fire on a fixed
+	// grid of that spacing, so "every 15 minutes" runs at :00, :15, :30 and
+	// :45.
+	every time.Duration
+}
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var everyPattern = regexp.MustCompile(`^every\s+(\d+)\s+(minutes?|hours?)$`)
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 is accepted as Sunday alongside 0.
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// cronSearchYears bounds how far ahead Next looks for a match, so an
+// expression such as "0 0 30 2 *" that names no real date ends the search.
+const cronSearchYears = 5
+
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.ToLower(strings.TrimSpace(expr))
+
+	if m := everyPattern.FindStringSubmatch(spec); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid cron expression %q: interval must be at least 1", expr)
+		}
+		unit := time.Minute
+		if strings.HasPrefix(m[2], "hour") {
+			unit = time.Hour
+		}
+		return &CronSchedule{every: time.Duration(n) * unit}, nil
+	}
+
+	if alias, ok := cronAliases[spec]; ok {
+		spec = alias
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("invalid cron expression %q: unknown alias, use @yearly, @monthly, @weekly, @daily or @hourly", expr)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+
+	var c CronSchedule
+	sets := [5]*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, field := range fields {
+		set, err := cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		*sets[i] = set
+	}
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid cron expression %q: never matches a real date", expr)
+	}
+	return &c, nil
+}
+
+// parse turns one field (a comma-separated list of values, ranges and
+// steps) into a bitset of the values it allows.
+func (f cronField) parse(spec string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(spec, ",") {
+		rng, stepSpec, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepSpec)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepSpec)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			start, end, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(start); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if hi, err = f.value(end); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				hi = lo
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: range %q runs backwards", f.name, rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %q is not a number from %d to %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first run strictly after t, or the zero time if there
+// isn't one in the next five years.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Truncate(c.every).Add(c.every)
+	}
+
+	t = t.In(time.Local).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case c.month&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, time.Local)
+		case !c.dayMatches(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, time.Local)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, time.Local)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,224 @@
+package scheduler
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * *", "want 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"0 0 0 * *", "day of month"},
+		{"0 0 * 13 *", "month"},
+		{"0 0 * * 8", "day of week"},
+		{"*/0 * * * *", "invalid step"},
+		{"0 17-9 * * *", "runs backwards"},
+		{"0 0 30 2 *", "never matches"},
+		{"@fortnightly", "unknown alias"},
+		{"every 0 minutes", "at least 1"},
+		{"anually", "want 5 fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Friday afternoon.
+	from := time.Date(2026, 3, 13, 16, 20, 30, 0, time.Local)
+
+	tests := []struct {
+		expr string
+		want []time.Time
+	}{
+		{
+			expr: "0 9-17 * * mon-fri",
+			want: []time.Time{
+				time.Date(2026, 3, 13, 17, 0, 0, 0, time.Local),
+				time.Date(2026, 3, 16, 9, 0, 0, 0, time.Local),
+				time.Date(2026, 3, 16, 10, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			expr: "@hourly",
+			want: []time.Time{
+				time.Date(2026, 3, 13, 17, 0, 0, 0, time.Local),
+				time.Date(2026, 3, 13, 18, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			expr: "*/20 16 * * *",
+			want: []time.Time{
+				time.Date(2026, 3, 13, 16, 40, 0, 0, time.Local),
+				time.Date(2026, 3, 14, 16, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			// Both day fields are restricted, so either one matching fires.
+			expr: "30 2 1 * 0",
+			want: []time.Time{
+				time.Date(2026, 3, 15, 2, 30, 0, 0, time.Local),
+				time.Date(2026, 3, 22, 2, 30, 0, 0, time.Local),
+				time.Date(2026, 3, 29, 2, 30, 0, 0, time.Local),
+				time.Date(2026, 4, 1, 2, 30, 0, 0, time.Local),
+			},
+		},
+		{
+			expr: "0 0 1 jan,jul 7",
+			want: []time.Time{
+				time.Date(2026, 7, 1, 0, 0, 0, 0, time.Local),
+				time.Date(2026, 7, 5, 0, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			expr: "every 15 minutes",
+			want: []time.Time{
+				from.Truncate(15 * time.Minute).Add(15 * time.Minute),
+				from.Truncate(15 * time.Minute).Add(30 * time.Minute),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			next := from
+			for _, want := range tt.want {
+				next = cron.Next(next)
+				assert.True(t, want.Equal(next), "got %v, want %v", next, want)
+			}
+		})
+	}
+}
+
+func TestUpdateNextRunTimeCron(t *testing.T) {
+	scheduler := NewScheduler(&fakeRunner{}, "")
+	task := &Task{
+		DateTime:  time.Now().Add(-time.Hour),
+		Recurring: true,
+		Cron:      "*/5 * * * *",
+		Active:    true,
+	}
+
+	scheduler.updateNextRunTime(task)
+
+	assert.True(t, task.Active)
+	assert.True(t, task.DateTime.After(time.Now()))
+	assert.True(t, task.DateTime.Before(time.Now().Add(5*time.Minute)))
+	assert.Zero(t, task.DateTime.Minute()%5)
+}
+
+func TestUpdateNextRunTimeUnknownInterval(t *testing.T) {
+	scheduler := NewScheduler(&fakeRunner{}, "")
+	task := &Task{
+		DateTime:  time.Now().Add(-time.Hour),
+		Recurring: true,
+		Interval:  "fortnightly",
+		Active:    true,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.updateNextRunTime(task)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("updateNextRunTime did not return for an unknown interval")
+	}
+	assert.False(t, task.Active)
+}
+
+func TestTaskValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr bool
+	}{
+		{"One-off task", Task{}, false},
+		{"Known interval", Task{Recurring: true, Interval: "weekly"}, false},
+		{"Legacy spelling", Task{Recurring: true, Interval: "anually"}, false},
+		{"Unknown interval", Task{Recurring: true, Interval: "fortnightly"}, true},
+		{"Recurring without interval", Task{Recurring: true}, true},
+		{"Valid cron", Task{Cron: "0 9-17 * * 1-5"}, false},
+		{"Invalid cron", Task{Cron: "0 9-17 * *"}, true},
+		{"Day chart", Task{ChartType: "download", RecentDays: 0}, false},
+		{"Negative recent days", Task{ChartType: "download", RecentDays: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.task.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTaskNextRuns(t *testing.T) {
+	start := time.Date(2026, 3, 13, 9, 0, 0, 0, time.Local)
+
+	weekly := &Task{DateTime: start, Recurring: true, Interval: "weekly", Active: true}
+	runs := weekly.NextRuns(5)
+	if assert.Len(t, runs, 5) {
+		assert.True(t, runs[4].Equal(start.AddDate(0, 0, 28)))
+	}
+
+	cron := &Task{DateTime: start, Recurring: true, Cron: "0 9 * * mon-fri", Active: true}
+	runs = cron.NextRuns(5)
+	if assert.Len(t, runs, 5) {
+		// Friday, then the following Monday to Thursday.
+		assert.True(t, runs[1].Equal(start.AddDate(0, 0, 3)))
+		assert.True(t, runs[4].Equal(start.AddDate(0, 0, 6)))
+	}
+
+	oneOff := &Task{DateTime: start, Active: true}
+	assert.Len(t, oneOff.NextRuns(5), 1)
+
+	inactive := &Task{DateTime: start, Recurring: true, Interval: "daily"}
+	assert.Empty(t, inactive.NextRuns(5))
+}
+
+func TestAddTaskAlignsCron(t *testing.T) {
+	schedulePath := "schedules.json"
+	defer os.RemoveAll(schedulePath)
+	scheduler := NewScheduler(&fakeRunner{}, schedulePath)
+
+	task := &Task{
+		Name:     "business hours",
+		TestType: "latency",
+		DateTime: time.Now().Add(-24 * time.Hour),
+		Cron:     "0 9-17 * * mon-fri",
+		Active:   true,
+	}
+	assert.NoError(t, scheduler.AddTask("1", task))
+
+	assert.True(t, task.Recurring)
+	assert.True(t, task.DateTime.After(time.Now()))
+	assert.Zero(t, task.DateTime.Minute())
+	assert.True(t, task.DateTime.Hour() >= 9 && task.DateTime.Hour() <= 17)
+	assert.NotEqual(t, time.Saturday, task.DateTime.Weekday())
+	assert.NotEqual(t, time.Sunday, task.DateTime.Weekday())
+}
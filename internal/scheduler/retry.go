@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+// Failures a RetryPolicy can retry on.
+const (
+	RetryOnAny           = "any"
+	RetryOnNetwork       = "network"         // refused connections, failed lookups, timeouts
+	RetryOnAllURLsFailed = "all_urls_failed" // a download or upload test with no URL measured
+)
+
+const (
+	maxRetryAttempts = 10
+	defaultBackoff   = time.Minute
+	maxBackoff       = 24 * time.Hour // Longest wait between attempts, however far the doubling gets
+)
+
+// RetryPolicy controls how a task's failed runs are retried.
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"max_attempts"`              // Runs in all, counting the first; 1 or less never retries
+	BackoffSeconds int      `json:"backoff_seconds,omitempty"` // Wait before the first retry, doubling for each after up to a day; a minute if unset
+	RetryOn        []string `json:"retry_on,omitempty"`        // Failures worth retrying; any failure if unset
+}
+
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts < 0 || p.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("max attempts must be from 0 to %d, got %d", maxRetryAttempts, p.MaxAttempts)
+	}
+	if p.BackoffSeconds < 0 || p.BackoffSeconds > int(maxBackoff/time.Second) {
+		return fmt.Errorf("backoff seconds must be from 0 to %d, got %d", int(maxBackoff/time.Second), p.BackoffSeconds)
+	}
+	for _, on := range p.RetryOn {
+		switch on {
+		case RetryOnAny, RetryOnNetwork, RetryOnAllURLsFailed:
+		default:
+			return fmt.Errorf("unknown retry condition %q: use %s, %s or %s", on, RetryOnAny, RetryOnNetwork, RetryOnAllURLsFailed)
+		}
+	}
+	return nil
+}
+
+// shouldRetry reports whether a run that failed with err on attempt should
+// be tried again. Cancelled runs never are.
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if p == nil || err == nil || attempt >= p.MaxAttempts || errors.Is(err, networkTesting.ErrTestCancelled) {
+		return false
+	}
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	for _, on := range p.RetryOn {
+		switch {
+		case on == RetryOnAny,
+			on == RetryOnNetwork && networkTesting.IsNetworkError(err),
+			on == RetryOnAllURLsFailed && errors.Is(err, networkTesting.ErrAllURLsFailed):
+			return true
+		}
+	}
+	return false
+}
+
+// backoff is how long to wait after attempt fails before the next one,
+// never more than maxBackoff.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := defaultBackoff
+	if p.BackoffSeconds > 0 {
+		delay = time.Duration(p.BackoffSeconds) * time.Second
+	}
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// scheduleRetry runs attempt of the task with ID id after delay, off the
+// tick loop, from a fresh copy of it. task is the copy the failed attempt
+// ran with. The retry is dropped if the scheduler stops, the task is
+// deleted, deactivated or edited, or its next scheduled run starts first.
+func (s *Scheduler) scheduleRetry(id string, task Task, attempt int, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		s.Mu.RLock()
+		var dropped string
+		live, ok := s.Schedule[id]
+		switch {
+		case !ok:
+			dropped = "it was deleted"
+		case !sameTime(live.LastRan, task.LastRan):
+			dropped = "its next run has started"
+		case !live.Active && (live.Recurring || live.Cron != ""):
+			dropped = "it was deactivated"
+		case editedSince(&task, live):
+			dropped = "it was edited"
+		default:
+			task = *live
+		}
+		s.Mu.RUnlock()
+
+		if dropped != "" {
+			log.Printf("Dropping retry of task %q: %s", task.Name, dropped)
+			return
+		}
+		s.execute(id, task, attempt)
+	})
+}
+
+// editedSince reports whether after would run differently from before: a
+// different job, or a different retry policy.
+func editedSince(before, after *Task) bool {
+	return before.TestType != after.TestType ||
+		before.ChartType != after.ChartType ||
+		before.RecentDays != after.RecentDays ||
+		!reflect.DeepEqual(before.Retry, after.Retry)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
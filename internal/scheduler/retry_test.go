@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	netErr := fmt.Errorf("test execution failed: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	allFailed := fmt.Errorf("test execution failed: %w", fmt.Errorf("%w: unexpected status code: 503", networkTesting.ErrAllURLsFailed))
+	otherErr := errors.New("no DNS hostnames configured")
+
+	tests := []struct {
+		name    string
+		policy  *RetryPolicy
+		attempt int
+		err     error
+		want    bool
+	}{
+		{name: "No policy", policy: nil, attempt: 1, err: otherErr, want: false},
+		{name: "Succeeded", policy: &RetryPolicy{MaxAttempts: 3}, attempt: 1, err: nil, want: false},
+		{name: "Any failure by default", policy: &RetryPolicy{MaxAttempts: 3}, attempt: 1, err: otherErr, want: true},
+		{name: "Out of attempts", policy: &RetryPolicy{MaxAttempts: 3}, attempt: 3, err: otherErr, want: false},
+		{name: "Cancelled", policy: &RetryPolicy{MaxAttempts: 3}, attempt: 1, err: networkTesting.ErrTestCancelled, want: false},
+		{name: "Network error on network", policy: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryOnNetwork}}, attempt: 1, err: netErr, want: true},
+		{name: "Other error on network", policy: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryOnNetwork}}, attempt: 1, err: otherErr, want: false},
+		{name: "All URLs failed", policy: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryOnAllURLsFailed}}, attempt: 1, err: allFailed, want: true},
+		{name: "Network error on all URLs failed", policy: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryOnAllURLsFailed}}, attempt: 1, err: netErr, want: false},
+		{name: "Either condition", policy: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryOnAllURLsFailed, RetryOnNetwork}}, attempt: 1, err: netErr, want: true},
+		{name: "Explicit any", policy: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryOnAny}}, attempt: 1, err: otherErr, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.shouldRetry(tt.attempt, tt.err))
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{BackoffSeconds: 30}
+	assert.Equal(t, 30*time.Second, p.backoff(1))
+	assert.Equal(t, time.Minute, p.backoff(2))
+	assert.Equal(t, 2*time.Minute, p.backoff(3))
+
+	assert.Equal(t, defaultBackoff, (&RetryPolicy{}).backoff(1))
+
+	day := &RetryPolicy{BackoffSeconds: 6 * 60 * 60}
+	assert.Equal(t, 24*time.Hour, day.backoff(3))
+	assert.Equal(t, maxBackoff, day.backoff(4), "doubling stops at the cap")
+	assert.Equal(t, maxBackoff, day.backoff(100), "a large attempt doesn't overflow")
+	assert.Equal(t, maxBackoff, (&RetryPolicy{BackoffSeconds: int(maxBackoff / time.Second)}).backoff(10))
+}
+
+func TestTaskValidateRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   *RetryPolicy
+		wantErr string
+	}{
+		{name: "Valid", retry: &RetryPolicy{MaxAttempts: 3, BackoffSeconds: 60, RetryOn: []string{RetryOnNetwork}}},
+		{name: "Too many attempts", retry: &RetryPolicy{MaxAttempts: 11}, wantErr: "max attempts"},
+		{name: "Negative backoff", retry: &RetryPolicy{MaxAttempts: 2, BackoffSeconds: -1}, wantErr: "backoff"},
+		{name: "Backoff over a day", retry: &RetryPolicy{MaxAttempts: 2, BackoffSeconds: 86401}, wantErr: "backoff"},
+		{name: "Negative attempts", retry: &RetryPolicy{MaxAttempts: -1}, wantErr: "max attempts"},
+		{name: "Unknown condition", retry: &RetryPolicy{MaxAttempts: 2, RetryOn: []string{"dns"}}, wantErr: `unknown retry condition "dns"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := Task{Name: "speed", TestType: "download", Retry: tt.retry}
+			err := task.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestExecuteRetries(t *testing.T) {
+	schedulePath := "schedules.json"
+	defer os.RemoveAll(schedulePath)
+
+	runner := &fakeRunner{err: errors.New("speed test server busy"), failFirst: 1}
+	scheduler := NewScheduler(runner, schedulePath)
+	defer scheduler.Stop()
+
+	task := &Task{Name: "upload", TestType: "upload", Active: true, Recurring: true, Interval: "weekly",
+		Retry: &RetryPolicy{MaxAttempts: 3, BackoffSeconds: 1}}
+	scheduler.Schedule["1"] = task
+
+	first := scheduler.execute("1", *task, 1)
+	assert.Equal(t, "FAILED", first.Status)
+	require.NotNil(t, first.RetryAt, "a failed run with attempts left is retried")
+
+	assert.Eventually(t, func() bool { return len(runner.runs("1")) == 2 }, 3*time.Second, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	runs := runner.runs("1")
+	require.Len(t, runs, 2, "no more attempts once one succeeds")
+	assert.Equal(t, 1, runs[0].Attempt)
+	assert.Equal(t, 2, runs[1].Attempt)
+	assert.Equal(t, "SUCCESS", runs[1].Status)
+	assert.Nil(t, runs[1].RetryAt)
+
+	scheduler.Mu.RLock()
+	defer scheduler.Mu.RUnlock()
+	assert.Equal(t, 2, task.LastResult.Attempt)
+}
+
+func TestRetryDropped(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *Scheduler, task *Task)
+	}{
+		{
+			name:   "Task deleted",
+			change: func(s *Scheduler, task *Task) { delete(s.Schedule, "1") },
+		},
+		{
+			name: "Next run started",
+			change: func(s *Scheduler, task *Task) {
+				now := time.Now()
+				task.LastRan = &now
+			},
+		},
+		{
+			name:   "Task deactivated",
+			change: func(s *Scheduler, task *Task) { task.Active = false },
+		},
+		{
+			name:   "Task edited",
+			change: func(s *Scheduler, task *Task) { task.TestType = "download" },
+		},
+		{
+			name: "Task replaced with another job",
+			change: func(s *Scheduler, task *Task) {
+				replaced := *task
+				replaced.TestType = "dns"
+				s.Schedule["1"] = &replaced
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedulePath := "schedules.json"
+			defer os.RemoveAll(schedulePath)
+
+			runner := &fakeRunner{err: errors.New("speed test server busy")}
+			scheduler := NewScheduler(runner, schedulePath)
+			defer scheduler.Stop()
+
+			task := &Task{Name: "upload", TestType: "upload", Active: true, Recurring: true, Interval: "weekly",
+				Retry: &RetryPolicy{MaxAttempts: 2, BackoffSeconds: 1}}
+			scheduler.Schedule["1"] = task
+
+			scheduler.execute("1", *task, 1)
+			scheduler.Mu.Lock()
+			tt.change(scheduler, task)
+			scheduler.Mu.Unlock()
+
+			time.Sleep(1300 * time.Millisecond)
+			assert.Len(t, runner.ran(), 1, "the retry shouldn't run")
+		})
+	}
+}
+
+func TestRetrySurvivesReimport(t *testing.T) {
+	schedulePath := "schedules.json"
+	defer os.RemoveAll(schedulePath)
+
+	runner := &fakeRunner{err: errors.New("speed test server busy")}
+	scheduler := NewScheduler(runner, schedulePath)
+	defer scheduler.Stop()
+
+	ran := time.Now()
+	task := &Task{Name: "upload", TestType: "upload", Active: true, Recurring: true, Interval: "weekly", LastRan: &ran,
+		Retry: &RetryPolicy{MaxAttempts: 2, BackoffSeconds: 1}}
+	scheduler.Schedule["1"] = task
+
+	scheduler.execute("1", *task, 1)
+
+	// Importing the schedule again swaps in an identical task under the
+	// same ID; the retry still belongs to it.
+	scheduler.Mu.Lock()
+	reimported := *task
+	lastRan := *task.LastRan
+	reimported.LastRan = &lastRan
+	reimported.Retry = &RetryPolicy{MaxAttempts: 2, BackoffSeconds: 1}
+	scheduler.Schedule["1"] = &reimported
+	scheduler.Mu.Unlock()
+
+	assert.Eventually(t, func() bool { return len(runner.ran()) == 2 }, 3*time.Second, 50*time.Millisecond)
+}
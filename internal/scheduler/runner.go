@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
+)
+
+// JobRunner does the work behind scheduled tasks. It's called in-process,
+// so a long test isn't cut off by an HTTP timeout and its errors reach the
+// scheduler.
+type JobRunner interface {
+	// RunTest runs and saves a test, returning the saved result's ID. It
+	// may wait for other tests first; source names the task while it does.
+	RunTest(ctx context.Context, testType, source string) (int64, error)
+	// GenerateCharts charts testType's results for the day of date,
+	// returning the saved charts' space-separated paths.
+	GenerateCharts(testType string, date time.Time) (string, error)
+	// GenerateHistoricCharts charts testType's results over the last days
+	// days, returning the saved charts' space-separated paths.
+	GenerateHistoricCharts(testType string, days int) (string, error)
+	// RecordRun adds a finished run of the task with ID taskID to its
+	// history.
+	RecordRun(taskID, taskName string, result JobResult) error
+}
+
+// JobResult records how one scheduled run of a task went.
+type JobResult struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Status    string        `json:"status"` // SUCCESS, FAILED or CANCELLED
+	Error     string        `json:"error,omitempty"`
+	TestID    int64         `json:"test_id,omitempty"`
+	Charts    string        `json:"charts,omitempty"`
+	Attempt   int           `json:"attempt"`            // 1 for the scheduled run, counting up through its retries
+	RetryAt   *time.Time    `json:"retry_at,omitempty"` // When the next attempt is due, if the run is being retried
+}
+
+// execute runs attempt of a due task through the runner, records the
+// outcome on the task and in its run history, and queues the next attempt
+// if the task's retry policy calls for one. task is a copy taken under s.Mu,
+// so the task can be edited while it runs; only the result is written back.
+func (s *Scheduler) execute(id string, task Task, attempt int) JobResult {
+	result := JobResult{StartedAt: time.Now(), Attempt: attempt}
+
+	var err error
+	switch {
+	case task.TestType != "":
+		result.TestID, err = s.runner.RunTest(s.ctx, task.TestType, task.Name)
+	case task.RecentDays > 0:
+		result.Charts, err = s.runner.GenerateHistoricCharts(task.ChartType, task.RecentDays)
+	default:
+		result.Charts, err = s.runner.GenerateCharts(task.ChartType, result.StartedAt)
+	}
+	result.Duration = time.Since(result.StartedAt).Round(time.Millisecond)
+
+	switch {
+	case errors.Is(err, networkTesting.ErrTestCancelled):
+		result.Status = networkTesting.StatusCancelled
+	case err != nil:
+		result.Status = "FAILED"
+	default:
+		result.Status = "SUCCESS"
+	}
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("Scheduled task %q %s after %s (attempt %d): %v", task.Name, result.Status, result.Duration, attempt, err)
+	} else {
+		log.Printf("Scheduled task %q succeeded in %s (test id %d, charts %q)", task.Name, result.Duration, result.TestID, result.Charts)
+	}
+
+	if task.Retry.shouldRetry(attempt, err) && s.ctx.Err() == nil {
+		delay := task.Retry.backoff(attempt)
+		retryAt := time.Now().Add(delay)
+		result.RetryAt = &retryAt
+		log.Printf("Retrying task %q in %s (attempt %d of %d)", task.Name, delay, attempt+1, task.Retry.MaxAttempts)
+		s.scheduleRetry(id, task, attempt+1, delay)
+	}
+
+	s.Mu.Lock()
+	if live, ok := s.Schedule[id]; ok {
+		live.LastResult = &result
+	}
+	s.Mu.Unlock()
+
+	// Written outside the lock, so a slow database never holds up the
+	// schedule or the handlers waiting on it.
+	if err := s.runner.RecordRun(id, task.Name, result); err != nil {
+		log.Printf("Failed to record run of task %q: %v", task.Name, err)
+	}
+	if err := s.ExportSchedule(s.schedulePath); err != nil {
+		log.Printf("Failed to export schedule: %v", err)
+	}
+	return result
+}
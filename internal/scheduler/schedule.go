@@ -24,6 +24,9 @@ func (s *Scheduler) ExportSchedule(filename string) error {
 		return fmt.Errorf("failed to marshal schedules: %w", err)
 	}
 
+	s.exportMu.Lock()
+	defer s.exportMu.Unlock()
+
 	if err := os.WriteFile(file, data, 0644); err != nil {
 		return fmt.Errorf("failed to write schedules file: %w", err)
 	}
@@ -50,6 +53,23 @@ func (s *Scheduler) ImportSchedule(filename string) error {
 	return nil
 }
 
+// AddTask saves a new task under id. The task should already have passed
+// Validate.
+func (s *Scheduler) AddTask(id string, task *Task) error {
+	if task.Cron != "" {
+		task.alignToCron(time.Now())
+	}
+
+	s.Mu.Lock()
+	s.Schedule[id] = task
+	s.Mu.Unlock()
+
+	if err := s.ExportSchedule(s.schedulePath); err != nil {
+		return fmt.Errorf("failed to export schedule: %w", err)
+	}
+	return nil
+}
+
 func (s *Scheduler) DeleteSchedule(id string) error {
 	s.Mu.Lock()
 
@@ -66,8 +86,10 @@ func (s *Scheduler) DeleteSchedule(id string) error {
 }
 
 func (s *Scheduler) EditTask(taskID string, updatedTask Task) (*Task, error) {
+	s.Mu.Lock()
 	task, exists := s.Schedule[taskID]
 	if !exists {
+		s.Mu.Unlock()
 		fmt.Printf("DEBUG: Task %s not found\n", taskID)
 		return nil, fmt.Errorf("task with ID %s not found", taskID)
 	}
@@ -76,6 +98,8 @@ func (s *Scheduler) EditTask(taskID string, updatedTask Task) (*Task, error) {
 	task.Recurring = updatedTask.Recurring
 	task.Active = updatedTask.Active
 	task.Interval = updatedTask.Interval
+	task.Cron = updatedTask.Cron
+	task.Retry = updatedTask.Retry
 
 	if updatedTask.TestType != "" {
 		task.TestType = updatedTask.TestType
@@ -92,12 +116,17 @@ func (s *Scheduler) EditTask(taskID string, updatedTask Task) (*Task, error) {
 		hour, min, sec := updatedTask.DateTime.Clock()
 		task.DateTime = time.Date(year, month, day, hour, min, sec, 0, time.Local)
 	}
+	if task.Cron != "" {
+		task.alignToCron(time.Now())
+	}
+	edited := *task
+	s.Mu.Unlock()
 
-	fmt.Printf("DEBUG: Final task: %+v\n", task)
+	fmt.Printf("DEBUG: Final task: %+v\n", edited)
 
 	if err := s.ExportSchedule(s.schedulePath); err != nil {
 		return nil, fmt.Errorf("failed to export schedule: %w", err)
 	}
 
-	return task, nil
+	return &edited, nil
 }
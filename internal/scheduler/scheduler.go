@@ -1,44 +1,54 @@
 package scheduler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 )
 
 type Task struct {
-	Name       string     `json:"name"`
-	TestType   string     `json:"test_type,omitempty"`
-	ChartType  string     `json:"chart_type,omitempty"`
-	RecentDays int        `json:"recent_days,omitempty"`
-	DateTime   time.Time  `json:"datetime"`
-	Recurring  bool       `json:"recurring"`
-	Interval   string     `json:"interval,omitempty"`
-	Active     bool       `json:"active"`
-	LastRan    *time.Time `json:"last_ran,omitempty"`
-	CreatedOn  time.Time  `json:"created_on"`
+	Name       string       `json:"name"`
+	TestType   string       `json:"test_type,omitempty"`
+	ChartType  string       `json:"chart_type,omitempty"`
+	RecentDays int          `json:"recent_days,omitempty"` // Chart this many days back; 0 charts the day of the run
+	DateTime   time.Time    `json:"datetime"`
+	Recurring  bool         `json:"recurring"`
+	Interval   string       `json:"interval,omitempty"`
+	Cron       string       `json:"cron,omitempty"` // Takes precedence over Interval; see ParseCron
+	Retry      *RetryPolicy `json:"retry,omitempty"`
+	Active     bool         `json:"active"`
+	LastRan    *time.Time   `json:"last_ran,omitempty"`
+	LastResult *JobResult   `json:"last_result,omitempty"`
+	CreatedOn  time.Time    `json:"created_on"`
 }
 
 type Scheduler struct {
 	Schedule     map[string]*Task
-	client       *http.Client
-	baseURL      string
+	runner       JobRunner
 	Mu           sync.RWMutex
 	done         chan struct{}
 	schedulePath string
+
+	// ctx is cancelled on Stop, winding down any test still running.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// exportMu keeps concurrent exports from interleaving their writes.
+	exportMu sync.Mutex
 }
 
-func NewScheduler(baseURL string, schedulePath string) *Scheduler {
+func NewScheduler(runner JobRunner, schedulePath string) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	s := &Scheduler{
 		Schedule:     make(map[string]*Task),
-		client:       &http.Client{Timeout: 30 * time.Second},
-		baseURL:      baseURL,
+		runner:       runner,
 		done:         make(chan struct{}),
 		schedulePath: schedulePath,
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 	return s
 }
@@ -64,6 +74,7 @@ func (s *Scheduler) Stop() {
 		return
 	default:
 		s.ExportSchedule(s.schedulePath)
+		s.cancel()
 		close(s.done)
 	}
 }
@@ -82,103 +93,156 @@ func (s *Scheduler) run() {
 	}
 }
 
+// checkAndExecuteSchedule starts every due task and moves it on to its next
+// run. The schedule is exported once the lock is released, as the tasks
+// themselves take it to record their results.
 func (s *Scheduler) checkAndExecuteSchedule() {
-	s.Mu.RLock()
-	defer s.Mu.RUnlock()
+	s.Mu.Lock()
 
 	now := time.Now().Format(time.RFC3339)
 	nowTime, _ := time.Parse(time.RFC3339, now)
 
-	for _, schedule := range s.Schedule {
+	started := 0
+	for id, schedule := range s.Schedule {
 		if !schedule.Active || !schedule.DateTime.Before(nowTime) {
 			continue
 		}
 
 		schedule.LastRan = &nowTime
+		go s.execute(id, *schedule, 1)
+		started++
 
-		if schedule.ChartType != "" {
-			if schedule.RecentDays >= 0 {
-				go s.executeHistoricChart(schedule)
-			} else {
-				go s.executeChart(schedule)
-			}
-		}
-		if schedule.TestType != "" {
-			go s.executeTest(schedule)
-		}
-
-		if schedule.Recurring {
+		if schedule.Recurring || schedule.Cron != "" {
 			s.updateNextRunTime(schedule)
 		} else {
 			schedule.Active = false
 		}
+	}
+	s.Mu.Unlock()
+
+	if started > 0 {
 		s.ExportSchedule(s.schedulePath)
 	}
 }
 
-func (s *Scheduler) updateNextRunTime(schedule *Task) {
-	now := time.Now()
+// intervals maps each Task.Interval to the years, months and days between
+// runs. The misspelled "anually" and "bianually" are what the dashboard's
+// form used to send, so tasks saved with them still run.
+var intervals = map[string][3]int{
+	"daily":      {0, 0, 1},
+	"weekly":     {0, 0, 7},
+	"monthly":    {0, 1, 0},
+	"bimonthly":  {0, 2, 0},
+	"biannually": {0, 6, 0},
+	"bianually":  {0, 6, 0},
+	"annually":   {1, 0, 0},
+	"anually":    {1, 0, 0},
+}
 
-	// First bring the date up to current if it's in the past
-	for schedule.DateTime.Before(now) {
-		switch schedule.Interval {
-		case "daily":
-			schedule.DateTime = schedule.DateTime.AddDate(0, 0, 1)
-		case "weekly":
-			schedule.DateTime = schedule.DateTime.AddDate(0, 0, 7)
-		case "monthly":
-			schedule.DateTime = schedule.DateTime.AddDate(0, 1, 0)
-		case "bimonthly":
-			schedule.DateTime = schedule.DateTime.AddDate(0, 2, 0)
-		case "biannually":
-			schedule.DateTime = schedule.DateTime.AddDate(0, 6, 0)
-		case "annually":
-			schedule.DateTime = schedule.DateTime.AddDate(1, 0, 0)
+// Validate checks the task's schedule and retry policy, so a bad cron
+// expression or interval is rejected when the task is saved rather than
+// when it's due.
+func (t *Task) Validate() error {
+	if t.RecentDays < 0 {
+		return fmt.Errorf("recent days can't be negative, got %d: use 0 to chart the day of the run", t.RecentDays)
+	}
+	if t.Retry != nil {
+		if err := t.Retry.validate(); err != nil {
+			return err
 		}
 	}
-}
-
-func (s *Scheduler) executeTest(schedule *Task) error {
-	url := fmt.Sprintf("%s/networktest?test=%s", s.baseURL, schedule.TestType)
-	resp, err := s.client.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to execute test: %w", err)
+	if t.Cron != "" {
+		_, err := ParseCron(t.Cron)
+		return err
+	}
+	if !t.Recurring {
+		return nil
+	}
+	if _, ok := intervals[t.Interval]; !ok {
+		return fmt.Errorf("unknown interval %q: use daily, weekly, monthly, bimonthly, biannually, annually or a cron expression", t.Interval)
 	}
-	defer resp.Body.Close()
 	return nil
 }
 
-func (s *Scheduler) executeChart(schedule *Task) error {
-	currentDate := time.Now().Format("2006-01-02")
-	url := fmt.Sprintf("%s/charts/generate?test=%s&date=%s", s.baseURL, schedule.ChartType, currentDate)
-
-	resp, err := s.client.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to generate chart: %w", err)
+// NextRuns returns up to n upcoming run times, starting with the one the
+// task is waiting for. Inactive tasks have none and one-off tasks just the
+// one.
+func (t *Task) NextRuns(n int) []time.Time {
+	if !t.Active || n <= 0 {
+		return nil
+	}
+	runs := []time.Time{t.DateTime}
+	if t.Cron == "" && !t.Recurring {
+		return runs
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("chart generation failed with status %d: %s", resp.StatusCode, string(body))
+	var cron *CronSchedule
+	if t.Cron != "" {
+		var err error
+		if cron, err = ParseCron(t.Cron); err != nil {
+			return runs
+		}
 	}
+	step, ok := intervals[t.Interval]
+	for len(runs) < n {
+		last := runs[len(runs)-1]
+		var next time.Time
+		switch {
+		case cron != nil:
+			next = cron.Next(last)
+		case ok:
+			next = last.AddDate(step[0], step[1], step[2])
+		}
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+	}
+	return runs
+}
 
-	return nil
+// alignToCron makes a cron task recurring and moves its DateTime to its
+// first run at or after DateTime, or after now if DateTime has passed.
+func (t *Task) alignToCron(now time.Time) {
+	cron, err := ParseCron(t.Cron)
+	if err != nil {
+		return
+	}
+	from := t.DateTime
+	if from.Before(now) {
+		from = now
+	}
+	t.Recurring = true
+	t.Interval = ""
+	t.DateTime = cron.Next(from.Add(-time.Second))
 }
 
-func (s *Scheduler) executeHistoricChart(schedule *Task) error {
-	url := fmt.Sprintf("%s/charts/generate-historic?test=%s&days=%d", s.baseURL, schedule.ChartType, schedule.RecentDays)
+// updateNextRunTime moves a recurring task on to its next run. A task whose
+// schedule can't be worked out is deactivated rather than left to fire on
+// every tick.
+func (s *Scheduler) updateNextRunTime(schedule *Task) {
+	now := time.Now()
 
-	resp, err := s.client.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to generate chart: %w", err)
+	if schedule.Cron != "" {
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			log.Printf("Deactivating task %q: %v", schedule.Name, err)
+			schedule.Active = false
+			return
+		}
+		schedule.DateTime = cron.Next(now)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("chart generation failed with status %d: %s", resp.StatusCode, string(body))
+	step, ok := intervals[schedule.Interval]
+	if !ok {
+		log.Printf("Deactivating task %q: unknown interval %q", schedule.Name, schedule.Interval)
+		schedule.Active = false
+		return
 	}
 
-	return nil
+	// First bring the date up to current if it's in the past
+	for schedule.DateTime.Before(now) {
+		schedule.DateTime = schedule.DateTime.AddDate(step[0], step[1], step[2])
+	}
 }
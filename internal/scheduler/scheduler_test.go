@@ -1,32 +1,88 @@
 package scheduler
 
 import (
-	"net/http"
-	"net/http/httptest"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/oshaw1/go-net-test/internal/networkTesting"
 	"github.com/stretchr/testify/assert"
 )
 
+// fakeRunner records the jobs it's asked to run and fails them with err,
+// or only the first failFirst of them if that's set.
+type fakeRunner struct {
+	mu        sync.Mutex
+	jobs      []string
+	err       error
+	failFirst int
+	history   map[string][]JobResult
+}
+
+func (f *fakeRunner) record(job string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs = append(f.jobs, job)
+	if f.failFirst > 0 && len(f.jobs) > f.failFirst {
+		return nil
+	}
+	return f.err
+}
+
+func (f *fakeRunner) ran() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.jobs...)
+}
+
+func (f *fakeRunner) RunTest(ctx context.Context, testType, source string) (int64, error) {
+	return 42, f.record("test:" + testType)
+}
+
+func (f *fakeRunner) GenerateCharts(testType string, date time.Time) (string, error) {
+	return "/charts/view?id=7", f.record("chart:" + testType)
+}
+
+func (f *fakeRunner) GenerateHistoricCharts(testType string, days int) (string, error) {
+	return "/charts/view?id=8", f.record(fmt.Sprintf("historic:%s:%d", testType, days))
+}
+
+func (f *fakeRunner) RecordRun(taskID, taskName string, result JobResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.history == nil {
+		f.history = make(map[string][]JobResult)
+	}
+	f.history[taskID] = append(f.history[taskID], result)
+	return nil
+}
+
+func (f *fakeRunner) runs(taskID string) []JobResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]JobResult(nil), f.history[taskID]...)
+}
+
 func TestNewScheduler(t *testing.T) {
-	baseURL := "http://test.com"
+	runner := &fakeRunner{}
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
-	scheduler := NewScheduler(baseURL, schedulePath)
+	scheduler := NewScheduler(runner, schedulePath)
 
 	assert.NotNil(t, scheduler)
-	assert.Equal(t, baseURL, scheduler.baseURL)
+	assert.Equal(t, runner, scheduler.runner)
 	assert.NotNil(t, scheduler.Schedule)
-	assert.NotNil(t, scheduler.client)
 	assert.NotNil(t, scheduler.done)
 }
 
 func TestUpdateNextRunTime(t *testing.T) {
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
-	scheduler := NewScheduler("http://test.com", schedulePath)
+	scheduler := NewScheduler(&fakeRunner{}, schedulePath)
 	now := time.Now()
 
 	tests := []struct {
@@ -95,15 +151,10 @@ func TestUpdateNextRunTime(t *testing.T) {
 }
 
 func TestLastRanTracking(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer server.Close()
-
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
 
-	scheduler := NewScheduler(server.URL, schedulePath)
+	scheduler := NewScheduler(&fakeRunner{}, schedulePath)
 	pastTime := time.Now().Add(-1 * time.Hour)
 
 	task := Task{
@@ -125,58 +176,149 @@ func TestLastRanTracking(t *testing.T) {
 	assert.True(t, scheduler.Schedule[task.Name].LastRan.After(pastTime))
 }
 
-func TestExecuteTest(t *testing.T) {
+func TestExecute(t *testing.T) {
 	tests := []struct {
-		name        string
-		statusCode  int
-		expectError bool
+		name       string
+		task       Task
+		err        error
+		wantJob    string
+		wantStatus string
+		wantTestID int64
+		wantCharts string
 	}{
 		{
-			name:        "Successful test execution",
-			statusCode:  http.StatusOK,
-			expectError: false,
+			name:       "Successful test",
+			task:       Task{Name: "dns", TestType: "dns"},
+			wantJob:    "test:dns",
+			wantStatus: "SUCCESS",
+			wantTestID: 42,
 		},
 		{
-			name:        "Failed test execution",
-			statusCode:  http.StatusInternalServerError,
-			expectError: false, // Note: Current implementation doesn't check status code
+			name:       "Failed test",
+			task:       Task{Name: "dns", TestType: "dns"},
+			err:        errors.New("no resolvers answered"),
+			wantJob:    "test:dns",
+			wantStatus: "FAILED",
+			wantTestID: 42,
+		},
+		{
+			name:       "Cancelled test",
+			task:       Task{Name: "bandwidth", TestType: "bandwidth"},
+			err:        networkTesting.ErrTestCancelled,
+			wantJob:    "test:bandwidth",
+			wantStatus: networkTesting.StatusCancelled,
+			wantTestID: 42,
+		},
+		{
+			name:       "Day chart",
+			task:       Task{Name: "chart", ChartType: "download", RecentDays: 0},
+			wantJob:    "chart:download",
+			wantStatus: "SUCCESS",
+			wantCharts: "/charts/view?id=7",
+		},
+		{
+			name:       "Historic chart",
+			task:       Task{Name: "chart", ChartType: "download", RecentDays: 90},
+			wantJob:    "historic:download:90",
+			wantStatus: "SUCCESS",
+			wantCharts: "/charts/view?id=8",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(tt.statusCode)
-			}))
-			defer server.Close()
-
 			schedulePath := "schedule.json"
 			defer os.RemoveAll(schedulePath)
-			scheduler := NewScheduler(server.URL, schedulePath)
-			task := &Task{
-				TestType: "jitter",
-			}
+			runner := &fakeRunner{err: tt.err}
+			scheduler := NewScheduler(runner, schedulePath)
+			scheduler.Schedule["1"] = &tt.task
+
+			result := scheduler.execute("1", tt.task, 1)
 
-			err := scheduler.executeTest(task)
-			if tt.expectError {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
+			assert.Equal(t, []string{tt.wantJob}, runner.ran())
+			assert.Equal(t, tt.wantStatus, result.Status)
+			assert.Equal(t, tt.wantTestID, result.TestID)
+			assert.Equal(t, tt.wantCharts, result.Charts)
+			if tt.err != nil {
+				assert.Equal(t, tt.err.Error(), result.Error)
 			}
+			assert.Equal(t, &result, tt.task.LastResult)
+			assert.Equal(t, []JobResult{result}, runner.runs("1"))
 		})
 	}
 }
 
-func TestCheckAndExecuteSchedules(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer server.Close()
+func TestExecuteRunsTaskAsItWasWhenDue(t *testing.T) {
+	schedulePath := "schedules.json"
+	defer os.RemoveAll(schedulePath)
+
+	runner := &fakeRunner{}
+	scheduler := NewScheduler(runner, schedulePath)
+	scheduler.Schedule["1"] = &Task{Name: "dns", TestType: "dns", DateTime: time.Now().Add(-time.Minute), Active: true}
+
+	scheduler.checkAndExecuteSchedule()
+	_, err := scheduler.EditTask("1", Task{Name: "upload", TestType: "upload", Active: true})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(runner.runs("1")) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"test:dns"}, runner.ran(), "the run started before the edit keeps the task it started with")
+
+	scheduler.Mu.RLock()
+	defer scheduler.Mu.RUnlock()
+	assert.NotNil(t, scheduler.Schedule["1"].LastResult)
+}
+
+// slowRecorder holds up RecordRun until release is closed, as a busy
+// database would.
+type slowRecorder struct {
+	fakeRunner
+	recording chan struct{}
+	release   chan struct{}
+}
 
+func (r *slowRecorder) RecordRun(taskID, taskName string, result JobResult) error {
+	close(r.recording)
+	<-r.release
+	return r.fakeRunner.RecordRun(taskID, taskName, result)
+}
+
+func TestExecuteRecordsRunOutsideLock(t *testing.T) {
+	schedulePath := "schedules.json"
+	defer os.RemoveAll(schedulePath)
+
+	runner := &slowRecorder{recording: make(chan struct{}), release: make(chan struct{})}
+	scheduler := NewScheduler(runner, schedulePath)
+	task := &Task{Name: "dns", TestType: "dns"}
+	scheduler.Schedule["1"] = task
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scheduler.execute("1", *task, 1)
+	}()
+	<-runner.recording
+
+	locked := make(chan struct{})
+	go func() {
+		scheduler.Mu.Lock()
+		scheduler.Mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Error("the scheduler lock was held while the run was being recorded")
+	}
+
+	close(runner.release)
+	<-done
+}
+
+func TestCheckAndExecuteSchedules(t *testing.T) {
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
 
-	scheduler := NewScheduler(server.URL, schedulePath)
+	scheduler := NewScheduler(&fakeRunner{}, schedulePath)
 	pastTime := time.Now().Add(-1 * time.Hour)
 	futureTime := time.Now().Add(1 * time.Hour)
 
@@ -223,7 +365,9 @@ func TestCheckAndExecuteSchedules(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			scheduler.Mu.Lock()
 			scheduler.Schedule[tt.task.Name] = &tt.task
+			scheduler.Mu.Unlock()
 			scheduler.checkAndExecuteSchedule()
 			time.Sleep(100 * time.Millisecond) // Allow goroutine to complete
 
@@ -236,7 +380,7 @@ func TestCheckAndExecuteSchedules(t *testing.T) {
 }
 
 func TestSchedulerStartStop(t *testing.T) {
-	scheduler := NewScheduler("http://test.com", "")
+	scheduler := NewScheduler(&fakeRunner{}, "")
 
 	scheduler.Start()
 	assert.NotPanics(t, func() {
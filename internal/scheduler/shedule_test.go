@@ -11,7 +11,7 @@ import (
 func TestExportImportSchedules(t *testing.T) {
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
-	s := NewScheduler("http://test.com", schedulePath)
+	s := NewScheduler(&fakeRunner{}, schedulePath)
 
 	testTime := time.Now().UTC() // Use UTC for consistent timezone
 	schedule := &Task{
@@ -28,7 +28,7 @@ func TestExportImportSchedules(t *testing.T) {
 	err := s.ExportSchedule(schedulePath)
 	assert.NoError(t, err)
 
-	s2 := NewScheduler("http://test.com", "test_schedules.json")
+	s2 := NewScheduler(&fakeRunner{}, "test_schedules.json")
 	err = s2.ImportSchedule(schedulePath)
 	assert.NoError(t, err)
 
@@ -36,7 +36,7 @@ func TestExportImportSchedules(t *testing.T) {
 }
 
 func TestExportSchedulesError(t *testing.T) {
-	s := NewScheduler("http://test.com", "")
+	s := NewScheduler(&fakeRunner{}, "")
 
 	err := s.ExportSchedule("")
 	assert.Error(t, err, "Expected an error when exporting to an invalid path")
@@ -45,7 +45,7 @@ func TestExportSchedulesError(t *testing.T) {
 func TestImportSchedulesError(t *testing.T) {
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
-	s := NewScheduler("http://test.com", schedulePath)
+	s := NewScheduler(&fakeRunner{}, schedulePath)
 	err := s.ImportSchedule("nonexistent.json")
 	assert.Error(t, err)
 }
@@ -53,7 +53,7 @@ func TestImportSchedulesError(t *testing.T) {
 func TestDeleteSchedule(t *testing.T) {
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
-	s := NewScheduler("http://test.com", schedulePath)
+	s := NewScheduler(&fakeRunner{}, schedulePath)
 	schedule := &Task{
 		Name:      "test123",
 		TestType:  "jitter",
@@ -76,7 +76,7 @@ func TestDeleteSchedule(t *testing.T) {
 func TestEditTask(t *testing.T) {
 	schedulePath := "schedules.json"
 	defer os.RemoveAll(schedulePath)
-	s := NewScheduler("http://test.com", schedulePath)
+	s := NewScheduler(&fakeRunner{}, schedulePath)
 
 	originalTask := &Task{
 		Name:      "test123",
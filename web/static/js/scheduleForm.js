@@ -21,6 +21,8 @@ window.showModal = function() {
         form.reset();
         if (window.ThemedSelect) window.ThemedSelect.refreshAll(form);
         updateFieldVisibility();
+        updateCronVisibility();
+        updateRetryVisibility();
     }
 
     // Update modal title
@@ -58,6 +60,12 @@ window.editTaskFromElement = function(scheduleElement) {
         datetime: scheduleElement.dataset.datetime,
         recurring: scheduleElement.dataset.recurring === 'true',
         interval: scheduleElement.dataset.interval,
+        cron: scheduleElement.dataset.cron,
+        retry: parseInt(scheduleElement.dataset.retryAttempts) > 1 ? {
+            max_attempts: parseInt(scheduleElement.dataset.retryAttempts),
+            backoff_seconds: parseInt(scheduleElement.dataset.retryBackoff) || 0,
+            retry_on: scheduleElement.dataset.retryOn ? scheduleElement.dataset.retryOn.split(',') : []
+        } : null,
         active: scheduleElement.dataset.active === 'true'
     };
     
@@ -96,6 +104,8 @@ window.closeModal = function() {
         form.reset();
         if (window.ThemedSelect) window.ThemedSelect.refreshAll(form);
         updateFieldVisibility();
+        updateCronVisibility();
+        updateRetryVisibility();
     }
     isEditMode = false;
     currentTaskId = null;
@@ -155,13 +165,28 @@ function populateForm(task) {
         recurringCheckbox.checked = task.recurring || false;
     }
     
-    if (task.interval) {
+    if (task.interval || task.cron) {
         const intervalSelect = document.getElementById('interval');
         if (intervalSelect) {
-            intervalSelect.value = task.interval;
+            intervalSelect.value = task.cron ? 'cron' : task.interval;
         }
     }
+
+    const cronInput = document.getElementById('cron');
+    if (cronInput) {
+        cronInput.value = task.cron || '';
+    }
     
+    const retryCheckbox = document.getElementById('retry');
+    if (retryCheckbox) {
+        retryCheckbox.checked = !!task.retry;
+    }
+    if (task.retry) {
+        document.getElementById('max_attempts').value = task.retry.max_attempts;
+        document.getElementById('backoff_seconds').value = task.retry.backoff_seconds || 60;
+        document.getElementById('retry_on').value = task.retry.retry_on[0] || 'any';
+    }
+
     const activeCheckbox = document.getElementById('active');
     if (activeCheckbox) {
         activeCheckbox.checked = task.active !== undefined ? task.active : true;
@@ -173,6 +198,8 @@ function populateForm(task) {
 
     // Update field visibility based on populated data
     updateFieldVisibility();
+    updateCronVisibility();
+    updateRetryVisibility();
     
     // Show/hide interval group based on recurring checkbox
     const intervalGroup = document.getElementById('interval-group');
@@ -187,6 +214,105 @@ function populateForm(task) {
     }
 }
 
+// toggleTaskHistory opens or closes a task's run history drawer, fetching
+// the runs afresh each time it opens.
+window.toggleTaskHistory = function(taskId, button) {
+    const drawer = document.getElementById('task-history-' + taskId);
+    if (!drawer) return;
+
+    if (!drawer.hidden) {
+        drawer.hidden = true;
+        button.setAttribute('aria-expanded', 'false');
+        return;
+    }
+    drawer.hidden = false;
+    button.setAttribute('aria-expanded', 'true');
+    drawer.textContent = 'Loading history...';
+
+    fetch('/schedule/history?id=' + encodeURIComponent(taskId))
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+            }
+            return response.json();
+        })
+        .then(runs => renderTaskHistory(drawer, runs))
+        .catch(error => {
+            console.error('Error loading task history:', error);
+            drawer.textContent = 'Could not load history: ' + error.message;
+        });
+};
+
+function renderTaskHistory(drawer, runs) {
+    drawer.textContent = '';
+    if (!runs || runs.length === 0) {
+        drawer.textContent = 'This task has not run yet.';
+        return;
+    }
+
+    const table = document.createElement('table');
+    const header = table.createTHead().insertRow();
+    ['Started', 'Took', 'Attempt', 'Status', 'Output'].forEach(label => {
+        const th = document.createElement('th');
+        th.textContent = label;
+        header.appendChild(th);
+    });
+
+    const body = table.createTBody();
+    runs.forEach(run => {
+        const row = body.insertRow();
+        const started = new Date(run.started_at);
+        const took = (new Date(run.ended_at) - started) / 1000;
+
+        row.insertCell().textContent = started.toLocaleString();
+        row.insertCell().textContent = took.toFixed(1) + 's';
+        row.insertCell().textContent = run.attempt;
+
+        const status = row.insertCell();
+        status.textContent = run.status;
+        status.className = 'status-' + run.status;
+
+        const output = row.insertCell();
+        if (run.error) {
+            output.textContent = run.error;
+            output.className = 'task-history-error';
+        } else if (run.charts) {
+            run.charts.split(' ').forEach(path => {
+                const link = document.createElement('a');
+                link.href = path;
+                link.target = '_blank';
+                link.textContent = 'chart ' + path.replace(/.*id=/, '#');
+                output.appendChild(link);
+                output.appendChild(document.createTextNode(' '));
+            });
+        }
+        if (run.result_id) {
+            output.appendChild(document.createTextNode((run.error ? ' ' : '') + '(result #' + run.result_id + ')'));
+        }
+    });
+
+    drawer.appendChild(table);
+}
+
+// The cron expression box only shows when "Custom (cron)" is the chosen
+// interval.
+function updateCronVisibility() {
+    const intervalSelect = document.getElementById('interval');
+    const cronGroup = document.getElementById('cron-group');
+    if (intervalSelect && cronGroup) {
+        cronGroup.style.display = intervalSelect.value === 'cron' ? 'block' : 'none';
+    }
+}
+
+// The retry settings only show when "Retry on Failure" is ticked.
+function updateRetryVisibility() {
+    const retryCheckbox = document.getElementById('retry');
+    const retryGroup = document.getElementById('retry-group');
+    if (retryCheckbox && retryGroup) {
+        retryGroup.style.display = retryCheckbox.checked ? 'block' : 'none';
+    }
+}
+
 function updateFieldVisibility() {
     const selectedRadio = document.querySelector('input[name="task_type"]:checked');
     if (!selectedRadio) return;
@@ -226,15 +352,31 @@ document.addEventListener('DOMContentLoaded', function() {
             event.preventDefault();
             
             const formData = new FormData(form);
+            const interval = formData.get('interval') || 'daily';
             
             const requestData = {
                 name: formData.get('name'),
                 datetime: new Date(formData.get('datetime')).toISOString(),
                 recurring: formData.get('recurring') === 'on',
-                interval: formData.get('interval') || 'daily',
+                interval: interval,
                 active: formData.get('active') === 'on'
             };
 
+            if (interval === 'cron') {
+                delete requestData.interval;
+                if (requestData.recurring) {
+                    requestData.cron = formData.get('cron');
+                }
+            }
+
+            if (formData.get('retry') === 'on') {
+                requestData.retry = {
+                    max_attempts: parseInt(formData.get('max_attempts')),
+                    backoff_seconds: parseInt(formData.get('backoff_seconds')) || 0,
+                    retry_on: [formData.get('retry_on')]
+                };
+            }
+
             const taskType = formData.get('task_type');
             if (taskType === 'test') {
                 requestData.test_type = formData.get('test_type');
@@ -259,9 +401,12 @@ document.addEventListener('DOMContentLoaded', function() {
                 },
                 body: JSON.stringify(requestData)
             })
-            .then(response => {
+            .then(async response => {
                 if (!response.ok) {
-                    throw new Error(`HTTP ${response.status}: ${response.statusText}`);
+                    // Validation errors (e.g. a bad cron expression) come back
+                    // as plain text worth showing as-is.
+                    const message = (await response.text()).trim();
+                    throw new Error(message || `HTTP ${response.status}: ${response.statusText}`);
                 }
                 // Both create and edit return JSON
                 return response.json();
@@ -291,6 +436,16 @@ document.addEventListener('DOMContentLoaded', function() {
         });
     }
 
+    const intervalSelect = document.getElementById('interval');
+    if (intervalSelect) {
+        intervalSelect.addEventListener('change', updateCronVisibility);
+    }
+
+    const retryCheckbox = document.getElementById('retry');
+    if (retryCheckbox) {
+        retryCheckbox.addEventListener('change', updateRetryVisibility);
+    }
+
     const taskTypeRadios = document.querySelectorAll('input[name="task_type"]');
     taskTypeRadios.forEach(radio => {
         radio.addEventListener('change', updateFieldVisibility);
@@ -47,40 +47,109 @@
 
   function setVal(id, val) {
     var el = document.getElementById(id);
-    if (el && val !== undefined && val !== null) el.value = val;
+    if (el && val !== undefined && val !== null) {
+      el.value = val;
+      if (el.tagName === "SELECT" && window.ThemedSelect) window.ThemedSelect.refresh(el);
+    }
   }
 
+  /* Last config fetched from the server. Saving merges the form over it so
+     settings that have no field in the modal aren't wiped on save. */
+  var loadedConfig = null;
+
   function loadConfigIntoModal(cfg) {
+    loadedConfig = cfg;
     if (cfg.dashboard) {
       setVal("cfg-recentDays", cfg.dashboard.recentDays);
     }
     if (cfg.tests) {
       var t = cfg.tests;
       if (t.icmp) {
+        setVal("cfg-icmp-targets", (t.icmp.targets || []).join("\n"));
+        setVal("cfg-icmp-mode", t.icmp.mode || "auto");
+        setVal("cfg-icmp-family", t.icmp.family || "v4");
         setVal("cfg-icmp-packetCount", t.icmp.packetCount);
         setVal("cfg-icmp-timeoutSeconds", t.icmp.timeoutSeconds);
       }
       if (t.speedTestURLs) {
         setVal("cfg-downloadUrls", (t.speedTestURLs.downloadUrls || []).join("\n"));
         setVal("cfg-uploadUrls", (t.speedTestURLs.uploadUrls || []).join("\n"));
+        setVal("cfg-speed-uploadSizeMB", t.speedTestURLs.uploadSizeMB);
+        setVal("cfg-speed-mode", t.speedTestURLs.mode || "duration");
+        setVal("cfg-speed-streams", t.speedTestURLs.streams);
+        setVal("cfg-speed-durationSeconds", t.speedTestURLs.durationSeconds);
+        setVal("cfg-speed-warmupSeconds", t.speedTestURLs.warmupSeconds);
+        setVal("cfg-speed-sampleIntervalMs", t.speedTestURLs.sampleIntervalMs);
       }
       if (t.routeTest) {
         setVal("cfg-route-target", t.routeTest.target);
+        setVal("cfg-route-family", t.routeTest.family || "v4");
         setVal("cfg-route-maxHops", t.routeTest.maxHops);
+        setVal("cfg-route-probesPerHop", t.routeTest.probesPerHop);
+        setVal("cfg-route-protocol", t.routeTest.protocol || "icmp");
+        setVal("cfg-route-port", t.routeTest.port || "");
+        setVal("cfg-route-asnFile", t.routeTest.asnFile);
         setVal("cfg-route-timeoutSeconds", t.routeTest.timeoutSeconds);
       }
       if (t.jitterTest) {
         setVal("cfg-jitter-target", t.jitterTest.target);
+        setVal("cfg-jitter-family", t.jitterTest.family || "v4");
         setVal("cfg-jitter-packetCount", t.jitterTest.packetCount);
+        setVal("cfg-jitter-intervalMs", t.jitterTest.intervalMs);
         setVal("cfg-jitter-timeoutSeconds", t.jitterTest.timeoutSeconds);
       }
       if (t.bandwidth) {
         setVal("cfg-bw-initialConnections", t.bandwidth.initialConnections);
         setVal("cfg-bw-maxConnections", t.bandwidth.maxConnections);
         setVal("cfg-bw-rampUpStep", t.bandwidth.rampUpStep);
-        setVal("cfg-bw-failThreshold", t.bandwidth.failThreshold);
+        setVal("cfg-bw-stepSeconds", t.bandwidth.stepSeconds);
+        setVal("cfg-bw-warmupSeconds", t.bandwidth.warmupSeconds);
+        setVal("cfg-bw-dropThreshold", t.bandwidth.dropThreshold);
         setVal("cfg-bw-downloadUrl", t.bandwidth.downloadUrl);
       }
+      if (t.dns) {
+        setVal("cfg-dns-hostnames", (t.dns.hostnames || []).join("\n"));
+        setVal("cfg-dns-resolvers", (t.dns.resolvers || []).join("\n"));
+        setVal("cfg-dns-queryType", t.dns.queryType);
+        setVal("cfg-dns-timeoutSeconds", t.dns.timeoutSeconds);
+      }
+      if (t.tcp) {
+        setVal("cfg-tcp-targets", (t.tcp.targets || []).join("\n"));
+        setVal("cfg-tcp-count", t.tcp.count);
+        setVal("cfg-tcp-timeoutSeconds", t.tcp.timeoutSeconds);
+      }
+      if (t.http) {
+        setVal("cfg-http-urls", (t.http.urls || []).join("\n"));
+        setVal("cfg-http-timeoutSeconds", t.http.timeoutSeconds);
+      }
+      if (t.tls) {
+        setVal("cfg-tls-endpoints", (t.tls.endpoints || []).join("\n"));
+        setVal("cfg-tls-timeoutSeconds", t.tls.timeoutSeconds);
+        setVal("cfg-tls-expiryWarningDays", t.tls.expiryWarningDays);
+      }
+      if (t.mtu) {
+        setVal("cfg-mtu-targets", (t.mtu.targets || []).join("\n"));
+        setVal("cfg-mtu-family", t.mtu.family || "v4");
+        setVal("cfg-mtu-minMTU", t.mtu.minMTU || "");
+        setVal("cfg-mtu-maxMTU", t.mtu.maxMTU);
+        setVal("cfg-mtu-attempts", t.mtu.attempts);
+        setVal("cfg-mtu-timeoutSeconds", t.mtu.timeoutSeconds);
+      }
+      if (t.loadedLatency) {
+        setVal("cfg-loadedLatency-downloadUrl", t.loadedLatency.downloadUrl);
+        setVal("cfg-loadedLatency-uploadUrl", t.loadedLatency.uploadUrl);
+        setVal("cfg-loadedLatency-pingCount", t.loadedLatency.pingCount);
+        setVal("cfg-loadedLatency-intervalMs", t.loadedLatency.intervalMs);
+        setVal("cfg-loadedLatency-warmupSeconds", t.loadedLatency.warmupSeconds);
+        setVal("cfg-loadedLatency-timeoutSeconds", t.loadedLatency.timeoutSeconds);
+      }
+      if (t.udp) {
+        setVal("cfg-udp-target", t.udp.target);
+        setVal("cfg-udp-bitrateKbps", t.udp.bitrateKbps);
+        setVal("cfg-udp-packetSize", t.udp.packetSize);
+        setVal("cfg-udp-durationSeconds", t.udp.durationSeconds);
+        setVal("cfg-udp-timeoutSeconds", t.udp.timeoutSeconds);
+      }
     }
   }
 
@@ -125,33 +194,100 @@
       dashboard: { recentDays: getInt("cfg-recentDays") },
       tests: {
         icmp: {
+          targets: getLines("cfg-icmp-targets"),
+          mode: getStr("cfg-icmp-mode"),
+          family: getStr("cfg-icmp-family"),
           packetCount: getInt("cfg-icmp-packetCount"),
           timeoutSeconds: getInt("cfg-icmp-timeoutSeconds")
         },
         speedTestURLs: {
           downloadUrls: getLines("cfg-downloadUrls"),
-          uploadUrls: getLines("cfg-uploadUrls")
+          uploadUrls: getLines("cfg-uploadUrls"),
+          uploadSizeMB: getInt("cfg-speed-uploadSizeMB"),
+          mode: getStr("cfg-speed-mode"),
+          streams: getInt("cfg-speed-streams"),
+          durationSeconds: getInt("cfg-speed-durationSeconds"),
+          warmupSeconds: getInt("cfg-speed-warmupSeconds"),
+          sampleIntervalMs: getInt("cfg-speed-sampleIntervalMs")
         },
         routeTest: {
           target: getStr("cfg-route-target"),
+          family: getStr("cfg-route-family"),
           maxHops: getInt("cfg-route-maxHops"),
+          probesPerHop: getInt("cfg-route-probesPerHop"),
+          protocol: getStr("cfg-route-protocol"),
+          port: getInt("cfg-route-port"),
+          asnFile: getStr("cfg-route-asnFile"),
           timeoutSeconds: getInt("cfg-route-timeoutSeconds")
         },
         jitterTest: {
           target: getStr("cfg-jitter-target"),
+          family: getStr("cfg-jitter-family"),
           packetCount: getInt("cfg-jitter-packetCount"),
+          intervalMs: getInt("cfg-jitter-intervalMs"),
           timeoutSeconds: getInt("cfg-jitter-timeoutSeconds")
         },
         bandwidth: {
           initialConnections: getInt("cfg-bw-initialConnections"),
           maxConnections: getInt("cfg-bw-maxConnections"),
           rampUpStep: getInt("cfg-bw-rampUpStep"),
-          failThreshold: getNum("cfg-bw-failThreshold"),
+          stepSeconds: getInt("cfg-bw-stepSeconds"),
+          warmupSeconds: getInt("cfg-bw-warmupSeconds"),
+          dropThreshold: getNum("cfg-bw-dropThreshold"),
           downloadUrl: getStr("cfg-bw-downloadUrl")
+        },
+        dns: {
+          hostnames: getLines("cfg-dns-hostnames"),
+          resolvers: getLines("cfg-dns-resolvers"),
+          queryType: getStr("cfg-dns-queryType"),
+          timeoutSeconds: getInt("cfg-dns-timeoutSeconds")
+        },
+        tcp: {
+          targets: getLines("cfg-tcp-targets"),
+          count: getInt("cfg-tcp-count"),
+          timeoutSeconds: getInt("cfg-tcp-timeoutSeconds")
+        },
+        http: {
+          urls: getLines("cfg-http-urls"),
+          timeoutSeconds: getInt("cfg-http-timeoutSeconds")
+        },
+        tls: {
+          endpoints: getLines("cfg-tls-endpoints"),
+          timeoutSeconds: getInt("cfg-tls-timeoutSeconds"),
+          expiryWarningDays: getInt("cfg-tls-expiryWarningDays")
+        },
+        mtu: {
+          targets: getLines("cfg-mtu-targets"),
+          family: getStr("cfg-mtu-family"),
+          minMTU: getInt("cfg-mtu-minMTU"),
+          maxMTU: getInt("cfg-mtu-maxMTU"),
+          attempts: getInt("cfg-mtu-attempts"),
+          timeoutSeconds: getInt("cfg-mtu-timeoutSeconds")
+        },
+        loadedLatency: {
+          downloadUrl: getStr("cfg-loadedLatency-downloadUrl"),
+          uploadUrl: getStr("cfg-loadedLatency-uploadUrl"),
+          pingCount: getInt("cfg-loadedLatency-pingCount"),
+          intervalMs: getInt("cfg-loadedLatency-intervalMs"),
+          warmupSeconds: getInt("cfg-loadedLatency-warmupSeconds"),
+          timeoutSeconds: getInt("cfg-loadedLatency-timeoutSeconds")
+        },
+        udp: {
+          target: getStr("cfg-udp-target"),
+          bitrateKbps: getInt("cfg-udp-bitrateKbps"),
+          packetSize: getInt("cfg-udp-packetSize"),
+          durationSeconds: getInt("cfg-udp-durationSeconds"),
+          timeoutSeconds: getInt("cfg-udp-timeoutSeconds")
         }
       }
     };
 
+    if (loadedConfig && loadedConfig.tests) {
+      Object.keys(loadedConfig.tests).forEach(function (key) {
+        payload.tests[key] = Object.assign({}, loadedConfig.tests[key], payload.tests[key]);
+      });
+    }
+
     fetch("/config", {
       method: "POST",
       headers: { "Content-Type": "application/json" },